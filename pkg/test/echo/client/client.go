@@ -70,3 +70,13 @@ func (c *Instance) ForwardEcho(ctx context.Context, request *proto.ForwardEchoRe
 
 	return parseForwardedResponse(resp), nil
 }
+
+// RequestCount returns the number of requests received by the server with the given value of the
+// common.RequestCountHeader header.
+func (c *Instance) RequestCount(ctx context.Context, key string) (int, error) {
+	resp, err := c.client.RequestCount(ctx, &proto.RequestCountRequest{Key: key})
+	if err != nil {
+		return 0, err
+	}
+	return int(resp.Count), nil
+}
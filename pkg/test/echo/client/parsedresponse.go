@@ -15,11 +15,19 @@
 package client
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 
@@ -27,6 +35,29 @@ import (
 	"istio.io/istio/pkg/test/echo/proto"
 )
 
+const (
+	// ExtAuthzHeader is the header that determines the decision of the example ext_authz server from
+	// the Istio samples. Requests carrying ExtAuthzAllow for the header are allowed, all others are
+	// denied.
+	ExtAuthzHeader = "X-Ext-Authz"
+	ExtAuthzAllow  = "allow"
+
+	// ExtAuthzCheckResultHeader is added by the example ext_authz server to allowed requests (as
+	// forwarded to the target) and to denied responses, with the value of the ExtAuthzDecision.
+	ExtAuthzCheckResultHeader = "X-Ext-Authz-Check-Result"
+)
+
+// ExtAuthzDecision is the decision made by an external authorization (ext_authz) server.
+type ExtAuthzDecision string
+
+const (
+	// ExtAuthzAllowed indicates that the request was allowed and forwarded to the target.
+	ExtAuthzAllowed ExtAuthzDecision = "allowed"
+
+	// ExtAuthzDenied indicates that the request was denied (403) without reaching the target.
+	ExtAuthzDenied ExtAuthzDecision = "denied"
+)
+
 var (
 	requestIDFieldRegex      = regexp.MustCompile("(?i)" + string(response.RequestIDField) + "=(.*)")
 	serviceVersionFieldRegex = regexp.MustCompile(string(response.ServiceVersionField) + "=(.*)")
@@ -34,6 +65,31 @@ var (
 	statusCodeFieldRegex     = regexp.MustCompile(string(response.StatusCodeField) + "=(.*)")
 	hostFieldRegex           = regexp.MustCompile(string(response.HostField) + "=(.*)")
 	hostnameFieldRegex       = regexp.MustCompile(string(response.HostnameField) + "=(.*)")
+	clusterFieldRegex        = regexp.MustCompile(string(response.ClusterField) + "=(.*)")
+	urlFieldRegex            = regexp.MustCompile(string(response.URLField) + "=(.*)")
+	remoteAddrFieldRegex     = regexp.MustCompile(string(response.RemoteAddrField) + "=(.*)")
+	clientCertFieldRegex     = regexp.MustCompile("(?i)" + string(response.ForwardedClientCertField) + "=(.*)")
+	contentEncodingRegex     = regexp.MustCompile(string(response.ContentEncodingField) + "=(.*)")
+	encodedBodySizeRegex     = regexp.MustCompile(string(response.EncodedBodySizeField) + "=(.*)")
+	decodedBodySizeRegex     = regexp.MustCompile(string(response.DecodedBodySizeField) + "=(.*)")
+	incompleteBodyRegex      = regexp.MustCompile(string(response.IncompleteBodyField) + "=(.*)")
+	responseProtocolRegex    = regexp.MustCompile(string(response.ResponseProtocolField) + "=(.*)")
+	alpnRegex                = regexp.MustCompile(string(response.ALPNField) + "=(.*)")
+	jwtClaimsRegex           = regexp.MustCompile(string(response.JWTClaimsField) + "=(.*)")
+	latencyRegex             = regexp.MustCompile(string(response.LatencyField) + "=(.*)")
+	timeToFirstByteRegex     = regexp.MustCompile(string(response.TimeToFirstByteField) + "=(.*)")
+	requestBodyRegex         = regexp.MustCompile(string(response.RequestBodyField) + "=(.*)")
+	requestHeaderRegex       = regexp.MustCompile(string(response.RequestHeaderField) + "=([^:]+):(.*)")
+	responseHeaderRegex      = regexp.MustCompile(string(response.ResponseHeaderField) + "=([^:]+):(.*)")
+	// Only match the check result header received by the server, rather than a response header. Header
+	// names are case-insensitive, and may be lowercased (e.g. when forwarded over HTTP/2).
+	extAuthzCheckResultRegex = regexp.MustCompile(`(?i)body\] ` + ExtAuthzCheckResultHeader + "=(.*)")
+	webSocketUpgradeRegex    = regexp.MustCompile(string(response.WebSocketUpgradeField) + "=(.*)")
+	// Only match the echo in the server's response body, rather than the message sent by the client.
+	echoRegex = regexp.MustCompile(`body\] ` + string(response.EchoField) + "=(.*)")
+	// Only match the host and authority received by the server, rather than the Host sent by the client.
+	authorityRegex  = regexp.MustCompile(`body\] ` + string(response.AuthorityField) + "=(.*)")
+	serverHostRegex = regexp.MustCompile(`body\] ` + string(response.HostField) + "=(.*)")
 )
 
 // ParsedResponse represents a response to a single echo request.
@@ -50,8 +106,66 @@ type ParsedResponse struct {
 	Code string
 	// Host is the host called by the request
 	Host string
+	// Authority received by the server. This is the :authority for HTTP/2 and gRPC requests, and
+	// the Host header for HTTP/1 requests (which have no separate authority).
+	Authority string
 	// Hostname is the host that responded to the request
 	Hostname string
+	// Cluster of the host that responded to the request. Empty if the server doesn't report its
+	// cluster (e.g. if it isn't configured, or for older echo images).
+	Cluster string
+	// Source is the hostname of the workload that made the request, if set by the caller (e.g. the
+	// test framework, which selects the workload that makes a call).
+	Source string
+	// URL received by the server (i.e. path and query). Only set for HTTP requests.
+	URL string
+	// Query parameters received by the server, parsed from URL.
+	Query url.Values
+	// ConnectionID identifies the connection on which the server received the request (i.e. the
+	// remote address seen by the server). With a sidecar, this is the connection from the server's
+	// sidecar. Empty if not reported by the server (e.g. older echo images).
+	ConnectionID string
+	// ForwardedClientCert is the X-Forwarded-Client-Cert header received by the server, which is
+	// set by the server's sidecar for (HTTP) requests received over mTLS.
+	ForwardedClientCert string
+	// ContentEncoding of the response body, if it was encoded (e.g. "gzip")
+	ContentEncoding string
+	// EncodedBodySize is the size of the encoded response body, as received on the wire. Only set
+	// if ContentEncoding is set and the body could be decoded by the client.
+	EncodedBodySize int
+	// DecodedBodySize is the size of the response body after decoding. Only set if ContentEncoding
+	// is set and the body could be decoded by the client.
+	DecodedBodySize int
+	// IncompleteBody indicates that the client did not receive the full response body (e.g. when
+	// reading slowly with a limited read rate). The fields are parsed from the part of the body that
+	// was received.
+	IncompleteBody bool
+	// Protocol of the response received by the client (e.g. "HTTP/1.0"). Only set for HTTP requests.
+	Protocol string
+	// ALPN protocol (e.g. "h2") negotiated by the client with TLS. Only set for HTTPS requests, if
+	// negotiated.
+	ALPN string
+	// WebSocketUpgraded indicates that the request was upgraded to a WebSocket connection.
+	WebSocketUpgraded bool
+	// Echo is the message echoed by the server, e.g. in the WebSocket frame received in response
+	// to the message sent.
+	Echo string
+	// Latency of the request, as measured by the client (i.e. the echo app making the call).
+	Latency time.Duration
+	// TimeToFirstByte of the (HTTP) response, as measured by the client.
+	TimeToFirstByte time.Duration
+	// RequestBody received by the server, if echoed (see echo.CallOptions.Body).
+	RequestBody []byte
+	// RequestHeaders received by the server (i.e. the HTTP headers or gRPC metadata), including any
+	// headers added or modified by the proxies along the way.
+	RequestHeaders http.Header
+	// ResponseHeaders of the (HTTP) response received by the client.
+	ResponseHeaders http.Header
+	// ExtAuthzCheckResult reported by the ext_authz server via the ExtAuthzCheckResultHeader, either in
+	// the request received by the server or in the response received by the client.
+	ExtAuthzCheckResult string
+	// JWTClaims of the JWT received by the server, by name. Non-string claims are JSON encoded.
+	JWTClaims map[string]string
 }
 
 // IsOK indicates whether or not the code indicates a successful request.
@@ -59,6 +173,34 @@ func (r *ParsedResponse) IsOK() bool {
 	return r.Code == response.StatusCodeOK
 }
 
+// IsRateLimited indicates whether the request was rejected by a rate limit (429).
+func (r *ParsedResponse) IsRateLimited() bool {
+	return r.Code == strconv.Itoa(http.StatusTooManyRequests)
+}
+
+// IsMTLS indicates whether the (HTTP) request was received by the server's sidecar over mTLS.
+func (r *ParsedResponse) IsMTLS() bool {
+	return r.ForwardedClientCert != ""
+}
+
+// SourcePrincipal returns the SPIFFE identity (e.g. "spiffe://cluster.local/ns/a/sa/default") of the
+// peer from which the server's sidecar received the (HTTP) request over mTLS, as reported via the
+// URI of the X-Forwarded-Client-Cert header. Empty if the request was not received over mTLS.
+func (r *ParsedResponse) SourcePrincipal() string {
+	return parseForwardedClientCert(r.ForwardedClientCert)["URI"]
+}
+
+// IsCompressed indicates whether the body of the response was compressed. This requires that the
+// response carried a compressing Content-Encoding, that the body was decoded by the client, and
+// that the body received on the wire was smaller than the decoded body.
+func (r *ParsedResponse) IsCompressed() bool {
+	switch r.ContentEncoding {
+	case "", "identity":
+		return false
+	}
+	return r.DecodedBodySize > 0 && r.EncodedBodySize < r.DecodedBodySize
+}
+
 // Count occurrences of the given text within the body of this response.
 func (r *ParsedResponse) Count(text string) int {
 	return strings.Count(r.Body, text)
@@ -124,6 +266,24 @@ func (r ParsedResponses) CheckHostOrFail(t testing.TB, expected string) ParsedRe
 	return r
 }
 
+// CheckAuthority checks that the server received the expected authority (i.e. the :authority for
+// HTTP/2 and gRPC, or the Host header for HTTP/1) in all responses.
+func (r ParsedResponses) CheckAuthority(expected string) error {
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if response.Authority != expected {
+			return fmt.Errorf("response[%d] Authority: expected %s, received %s", i, expected, response.Authority)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckAuthorityOrFail(t testing.TB, expected string) ParsedResponses {
+	if err := r.CheckAuthority(expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
 func (r ParsedResponses) CheckPort(expected int) error {
 	expectedStr := strconv.Itoa(expected)
 	return r.Check(func(i int, response *ParsedResponse) error {
@@ -141,6 +301,528 @@ func (r ParsedResponses) CheckPortOrFail(t testing.TB, expected int) ParsedRespo
 	return r
 }
 
+// CheckSourcePrincipal checks that the server's sidecar received all requests over mTLS from a peer
+// with the expected SPIFFE identity (e.g. echo.Config.Principal of the source).
+func (r ParsedResponses) CheckSourcePrincipal(expected string) error {
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if principal := response.SourcePrincipal(); principal != expected {
+			return fmt.Errorf("response[%d] source principal: expected %s, received %q", i, expected, principal)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckSourcePrincipalOrFail(t testing.TB, expected string) ParsedResponses {
+	if err := r.CheckSourcePrincipal(expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckTrustDomain checks that the server's sidecar received all requests over mTLS from a peer with
+// a SPIFFE identity in the expected trust domain.
+func (r ParsedResponses) CheckTrustDomain(expected string) error {
+	prefix := "spiffe://" + expected + "/"
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if principal := response.SourcePrincipal(); !strings.HasPrefix(principal, prefix) {
+			return fmt.Errorf("response[%d] trust domain: expected %s, received source principal %q",
+				i, expected, principal)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckTrustDomainOrFail(t testing.TB, expected string) ParsedResponses {
+	if err := r.CheckTrustDomain(expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckJWTAccepted checks that all requests were accepted and that the server received the claims
+// of a JWT.
+func (r ParsedResponses) CheckJWTAccepted() error {
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if !response.IsOK() {
+			return fmt.Errorf("response[%d] JWT not accepted: Status Code: %s", i, response.Code)
+		}
+		if len(response.JWTClaims) == 0 {
+			return fmt.Errorf("response[%d] JWT accepted, but no claims were received by the server", i)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckJWTAcceptedOrFail(t testing.TB) ParsedResponses {
+	if err := r.CheckJWTAccepted(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckJWTRejected checks that all requests were rejected, either as unauthenticated (401, e.g.
+// for an invalid JWT) or as unauthorized (403, e.g. for a missing JWT that is required by policy).
+func (r ParsedResponses) CheckJWTRejected() error {
+	return r.Check(func(i int, response *ParsedResponse) error {
+		switch response.Code {
+		case strconv.Itoa(http.StatusUnauthorized), strconv.Itoa(http.StatusForbidden):
+			return nil
+		default:
+			return fmt.Errorf("response[%d] JWT not rejected: Status Code: %s", i, response.Code)
+		}
+	})
+}
+
+func (r ParsedResponses) CheckJWTRejectedOrFail(t testing.TB) ParsedResponses {
+	if err := r.CheckJWTRejected(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckExtAuthzDecision checks that the expected decision was made for all requests by the ext_authz
+// server (e.g. as configured by a CUSTOM AuthorizationPolicy), as reported via the
+// ExtAuthzCheckResultHeader. Allowed requests must have reached the target (200), and denied
+// requests must have been rejected (403).
+func (r ParsedResponses) CheckExtAuthzDecision(expected ExtAuthzDecision) error {
+	code := response.StatusCodeOK
+	if expected == ExtAuthzDenied {
+		code = strconv.Itoa(http.StatusForbidden)
+	}
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if response.ExtAuthzCheckResult == "" {
+			return fmt.Errorf("response[%d] not checked by ext_authz: missing %s header (Status Code: %s)",
+				i, ExtAuthzCheckResultHeader, response.Code)
+		}
+		if response.ExtAuthzCheckResult != string(expected) {
+			return fmt.Errorf("response[%d] ext_authz decision: expected %s, received %s",
+				i, expected, response.ExtAuthzCheckResult)
+		}
+		if response.Code != code {
+			return fmt.Errorf("response[%d] Status Code for ext_authz decision %s: expected %s, received %s",
+				i, expected, code, response.Code)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckExtAuthzDecisionOrFail(t testing.TB, expected ExtAuthzDecision) ParsedResponses {
+	if err := r.CheckExtAuthzDecision(expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// ConnectionIDs returns the ConnectionID of each response.
+func (r ParsedResponses) ConnectionIDs() []string {
+	out := make([]string, 0, len(r))
+	for _, response := range r {
+		out = append(out, response.ConnectionID)
+	}
+	return out
+}
+
+// CheckConnectionReused checks that all requests were received by the server on the same connection.
+// This requires the requests to be made sequentially (e.g. by limiting the QPS of the call).
+func (r ParsedResponses) CheckConnectionReused() error {
+	if err := r.checkConnectionIDs(); err != nil {
+		return err
+	}
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if response.ConnectionID != r[0].ConnectionID {
+			return fmt.Errorf("response[%d] connection not reused: expected %s, received %s",
+				i, r[0].ConnectionID, response.ConnectionID)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckConnectionReusedOrFail(t testing.TB) ParsedResponses {
+	if err := r.CheckConnectionReused(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckConnectionNotReused checks that every request was received by the server on a different connection.
+func (r ParsedResponses) CheckConnectionNotReused() error {
+	if err := r.checkConnectionIDs(); err != nil {
+		return err
+	}
+	seen := make(map[string]int)
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if prev, ok := seen[response.ConnectionID]; ok {
+			return fmt.Errorf("response[%d] reused the connection %s of response[%d]", i, response.ConnectionID, prev)
+		}
+		seen[response.ConnectionID] = i
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckConnectionNotReusedOrFail(t testing.TB) ParsedResponses {
+	if err := r.CheckConnectionNotReused(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// checkConnectionIDs checks that the server reported the connection of every request.
+func (r ParsedResponses) checkConnectionIDs() error {
+	field := response.RemoteAddrField
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if response.ConnectionID == "" {
+			return fmt.Errorf("response[%d] has no %s field: the echo server does not report connections", i, field)
+		}
+		return nil
+	})
+}
+
+// CheckSameHostname checks that all responses were served by the same workload (e.g. all messages
+// of a stream, or all requests subject to session affinity).
+func (r ParsedResponses) CheckSameHostname() error {
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if response.Hostname != r[0].Hostname {
+			return fmt.Errorf("response[%d] served by %s, but response[0] by %s", i, response.Hostname, r[0].Hostname)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckSameHostnameOrFail(t testing.TB) ParsedResponses {
+	if err := r.CheckSameHostname(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// Clusters returns the clusters that served the responses, in order. Responses that don't report a
+// cluster are included as empty strings.
+func (r ParsedResponses) Clusters() []string {
+	out := make([]string, 0, len(r))
+	for _, resp := range r {
+		out = append(out, resp.Cluster)
+	}
+	return out
+}
+
+// CheckCluster checks that all responses were served by the expected cluster. Responses that don't
+// report a cluster fail the check.
+func (r ParsedResponses) CheckCluster(expected string) error {
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if response.Cluster == "" {
+			return fmt.Errorf("response[%d] from %s doesn't report a cluster (expected %s); the echo server may be too old or not configured with --cluster",
+				i, response.Hostname, expected)
+		}
+		if response.Cluster != expected {
+			return fmt.Errorf("response[%d] Cluster: expected %s, received %s", i, expected, response.Cluster)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckClusterOrFail(t testing.TB, expected string) ParsedResponses {
+	if err := r.CheckCluster(expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckWebSocketEcho checks that all requests were upgraded to a WebSocket connection, over which
+// the server echoed the given message.
+func (r ParsedResponses) CheckWebSocketEcho(message string) error {
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if !response.WebSocketUpgraded {
+			return fmt.Errorf("response[%d] not upgraded to WebSocket: Status Code: %s", i, response.Code)
+		}
+		if response.Echo != message {
+			return fmt.Errorf("response[%d] WebSocket echo: expected %q, received %q", i, message, response.Echo)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckWebSocketEchoOrFail(t testing.TB, message string) ParsedResponses {
+	if err := r.CheckWebSocketEcho(message); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckDelayInjected checks that the expected delay, within the given tolerance, was injected for
+// (approximately) the given percentage of requests, as configured by a VirtualService delay fault.
+// Requests that were not delayed must complete faster than the delay. For a percentage < 100, the
+// percentage of delayed requests is allowed to deviate from the expected value by three standard
+// deviations (of the binomial distribution), so Count should be large enough for a meaningful check.
+func (r ParsedResponses) CheckDelayInjected(expected, tolerance time.Duration, percentage float64) error {
+	if len(r) == 0 {
+		return errors.New("no responses received")
+	}
+
+	delayed := 0
+	for i, response := range r {
+		switch {
+		case response.Latency == 0:
+			return fmt.Errorf("response[%d] has no latency: the echo client does not report latency", i)
+		case response.Latency > expected+tolerance:
+			return fmt.Errorf("response[%d] latency %v exceeds the expected delay %v (tolerance %v)",
+				i, response.Latency, expected, tolerance)
+		case response.Latency >= expected-tolerance:
+			delayed++
+		}
+	}
+
+	observed := 100 * float64(delayed) / float64(len(r))
+	p := percentage / 100
+	margin := 300 * math.Sqrt(p*(1-p)/float64(len(r)))
+	if math.Abs(observed-percentage) > margin {
+		return fmt.Errorf("delay %v injected for %.1f%% of %d requests, expected %.1f%%",
+			expected, observed, len(r), percentage)
+	}
+	return nil
+}
+
+func (r ParsedResponses) CheckDelayInjectedOrFail(t testing.TB, expected, tolerance time.Duration, percentage float64) ParsedResponses {
+	if err := r.CheckDelayInjected(expected, tolerance, percentage); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// RateLimitedCount returns the number of requests that were rejected by a rate limit (429).
+func (r ParsedResponses) RateLimitedCount() int {
+	count := 0
+	for _, response := range r {
+		if response.IsRateLimited() {
+			count++
+		}
+	}
+	return count
+}
+
+// CheckRateLimited checks that the number of requests rejected by a rate limit (429) is within
+// tolerance of expectedLimited, and that all other requests succeeded. For a burst of Count requests
+// against a limit of N requests per fill interval, expectedLimited is Count - N.
+func (r ParsedResponses) CheckRateLimited(expectedLimited, tolerance int) error {
+	if len(r) == 0 {
+		return errors.New("no responses received")
+	}
+
+	limited := 0
+	for i, resp := range r {
+		switch {
+		case resp.IsRateLimited():
+			limited++
+		case !resp.IsOK():
+			return fmt.Errorf("response[%d] Status Code: expected %s or %d, received %s",
+				i, response.StatusCodeOK, http.StatusTooManyRequests, resp.Code)
+		}
+	}
+
+	if limited < expectedLimited-tolerance || limited > expectedLimited+tolerance {
+		return fmt.Errorf("%d of %d requests were rate limited, expected %d (tolerance %d)",
+			limited, len(r), expectedLimited, tolerance)
+	}
+	return nil
+}
+
+func (r ParsedResponses) CheckRateLimitedOrFail(t testing.TB, expectedLimited, tolerance int) ParsedResponses {
+	if err := r.CheckRateLimited(expectedLimited, tolerance); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckStreaming checks that all responses started arriving before they were fully produced, i.e.
+// that they were streamed (rather than buffered) on the way to the client. This requires a response
+// body that is produced over time, e.g. via CallOptions.ResponseChunks, that takes much longer than
+// the round trip. A response is considered streamed if its first byte arrived within the first half
+// of its total latency.
+func (r ParsedResponses) CheckStreaming() error {
+	return r.checkStreamed(true)
+}
+
+func (r ParsedResponses) CheckStreamingOrFail(t testing.TB) ParsedResponses {
+	if err := r.CheckStreaming(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckBuffered checks that all responses only started arriving after they were fully produced,
+// i.e. that they were buffered (e.g. by Envoy) on the way to the client. See CheckStreaming.
+func (r ParsedResponses) CheckBuffered() error {
+	return r.checkStreamed(false)
+}
+
+func (r ParsedResponses) CheckBufferedOrFail(t testing.TB) ParsedResponses {
+	if err := r.CheckBuffered(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func (r ParsedResponses) checkStreamed(expected bool) error {
+	if len(r) == 0 {
+		return errors.New("no responses received")
+	}
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if response.TimeToFirstByte == 0 || response.Latency == 0 {
+			return fmt.Errorf("response[%d] has no timing: the echo client does not report it", i)
+		}
+		streamed := response.TimeToFirstByte < response.Latency/2
+		if streamed != expected {
+			return fmt.Errorf("response[%d] streamed: expected %v, received first byte after %v of %v",
+				i, expected, response.TimeToFirstByte, response.Latency)
+		}
+		return nil
+	})
+}
+
+// CheckRequestTooLarge checks that all requests were rejected because the body was too large (413).
+func (r ParsedResponses) CheckRequestTooLarge() error {
+	return r.checkCode(http.StatusRequestEntityTooLarge)
+}
+
+func (r ParsedResponses) CheckRequestTooLargeOrFail(t testing.TB) ParsedResponses {
+	if err := r.CheckRequestTooLarge(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckHeadersTooLarge checks that all requests were rejected because the headers were too large (431).
+func (r ParsedResponses) CheckHeadersTooLarge() error {
+	return r.checkCode(http.StatusRequestHeaderFieldsTooLarge)
+}
+
+func (r ParsedResponses) CheckHeadersTooLargeOrFail(t testing.TB) ParsedResponses {
+	if err := r.CheckHeadersTooLarge(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckRequestBody checks that the server received the expected body with all requests (e.g. after
+// any transformation by the proxies), as echoed for calls with a Body.
+func (r ParsedResponses) CheckRequestBody(expected []byte) error {
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if !bytes.Equal(response.RequestBody, expected) {
+			return fmt.Errorf("response[%d] RequestBody: expected %d bytes, received %d bytes (which differ)",
+				i, len(expected), len(response.RequestBody))
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckRequestBodyOrFail(t testing.TB, expected []byte) ParsedResponses {
+	if err := r.CheckRequestBody(expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func (r ParsedResponses) checkCode(expected int) error {
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if response.Code != strconv.Itoa(expected) {
+			return fmt.Errorf("response[%d] Status Code: expected %d, received %s", i, expected, response.Code)
+		}
+		return nil
+	})
+}
+
+// CheckQueryParam checks that the server received the given value for the query parameter in all
+// responses.
+func (r ParsedResponses) CheckQueryParam(key, expected string) error {
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if actual := response.Query.Get(key); actual != expected {
+			return fmt.Errorf("response[%d] query parameter %s: expected %s, received %s", i, key, expected, actual)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckQueryParamOrFail(t testing.TB, key, expected string) ParsedResponses {
+	if err := r.CheckQueryParam(key, expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckProtocol checks that all responses were received with the given protocol (e.g. "HTTP/1.0").
+func (r ParsedResponses) CheckProtocol(expected string) error {
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if response.Protocol != expected {
+			return fmt.Errorf("response[%d] Protocol: expected %s, received %s", i, expected, response.Protocol)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckProtocolOrFail(t testing.TB, expected string) ParsedResponses {
+	if err := r.CheckProtocol(expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckALPN checks that all responses were received over TLS with the given negotiated ALPN
+// protocol (e.g. "h2").
+func (r ParsedResponses) CheckALPN(expected string) error {
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if response.ALPN != expected {
+			return fmt.Errorf("response[%d] ALPN: expected %s, received %s", i, expected, response.ALPN)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckALPNOrFail(t testing.TB, expected string) ParsedResponses {
+	if err := r.CheckALPN(expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckCompressed checks that all responses were compressed with the given encoding.
+func (r ParsedResponses) CheckCompressed(encoding string) error {
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if response.ContentEncoding != encoding {
+			return fmt.Errorf("response[%d] ContentEncoding: expected %s, received %s", i, encoding, response.ContentEncoding)
+		}
+		if !response.IsCompressed() {
+			return fmt.Errorf("response[%d] not compressed: encoded size %d, decoded size %d",
+				i, response.EncodedBodySize, response.DecodedBodySize)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckCompressedOrFail(t testing.TB, encoding string) ParsedResponses {
+	if err := r.CheckCompressed(encoding); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// Distribution returns the number of responses received from each responding host (i.e. workload),
+// keyed by Hostname.
+func (r ParsedResponses) Distribution() map[string]int {
+	out := make(map[string]int)
+	for _, resp := range r {
+		out[resp.Hostname]++
+	}
+	return out
+}
+
+// SourceDistribution returns the number of requests made by each source workload, keyed by Source.
+func (r ParsedResponses) SourceDistribution() map[string]int {
+	out := make(map[string]int)
+	for _, resp := range r {
+		out[resp.Source]++
+	}
+	return out
+}
+
 // Count occurrences of the given text within the bodies of all responses.
 func (r ParsedResponses) Count(text string) int {
 	count := 0
@@ -188,10 +870,172 @@ func parseResponse(output string) *ParsedResponse {
 		out.Host = match[1]
 	}
 
+	match = authorityRegex.FindStringSubmatch(output)
+	if match == nil {
+		match = serverHostRegex.FindStringSubmatch(output)
+	}
+	if match != nil {
+		out.Authority = match[1]
+	}
+
 	match = hostnameFieldRegex.FindStringSubmatch(output)
 	if match != nil {
 		out.Hostname = match[1]
 	}
 
+	match = clusterFieldRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.Cluster = match[1]
+	}
+
+	match = remoteAddrFieldRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.ConnectionID = match[1]
+	}
+
+	match = clientCertFieldRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.ForwardedClientCert = match[1]
+	}
+
+	match = urlFieldRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.URL = match[1]
+		if u, err := url.Parse(out.URL); err == nil {
+			out.Query = u.Query()
+		}
+	}
+
+	match = contentEncodingRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.ContentEncoding = match[1]
+	}
+
+	match = encodedBodySizeRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.EncodedBodySize, _ = strconv.Atoi(match[1])
+	}
+
+	match = decodedBodySizeRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.DecodedBodySize, _ = strconv.Atoi(match[1])
+	}
+
+	out.IncompleteBody = incompleteBodyRegex.MatchString(output)
+
+	match = responseProtocolRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.Protocol = match[1]
+	}
+
+	match = alpnRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.ALPN = match[1]
+	}
+
+	match = webSocketUpgradeRegex.FindStringSubmatch(output)
+	out.WebSocketUpgraded = match != nil && match[1] == strconv.Itoa(http.StatusSwitchingProtocols)
+
+	match = echoRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.Echo = match[1]
+	}
+
+	match = latencyRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.Latency, _ = time.ParseDuration(match[1])
+	}
+
+	match = timeToFirstByteRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.TimeToFirstByte, _ = time.ParseDuration(match[1])
+	}
+
+	match = requestBodyRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.RequestBody, _ = base64.StdEncoding.DecodeString(match[1])
+	}
+
+	match = jwtClaimsRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.JWTClaims = parseJWTClaims(match[1])
+	}
+
+	for _, header := range requestHeaderRegex.FindAllStringSubmatch(output, -1) {
+		if out.RequestHeaders == nil {
+			out.RequestHeaders = make(http.Header)
+		}
+		out.RequestHeaders.Add(header[1], header[2])
+	}
+
+	for _, header := range responseHeaderRegex.FindAllStringSubmatch(output, -1) {
+		if out.ResponseHeaders == nil {
+			out.ResponseHeaders = make(http.Header)
+		}
+		out.ResponseHeaders.Add(header[1], header[2])
+	}
+
+	match = extAuthzCheckResultRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.ExtAuthzCheckResult = match[1]
+	} else {
+		out.ExtAuthzCheckResult = out.ResponseHeaders.Get(ExtAuthzCheckResultHeader)
+	}
+
 	return &out
 }
+
+// parseForwardedClientCert parses the fields (e.g. "By", "Hash" and "URI") of the X-Forwarded-Client-Cert
+// header. If the header has multiple elements (one per proxy), the last one, which was added by the
+// server's sidecar, is parsed.
+func parseForwardedClientCert(xfcc string) map[string]string {
+	if xfcc == "" {
+		return nil
+	}
+	elements := splitUnquoted(xfcc, ',')
+	out := make(map[string]string)
+	for _, pair := range splitUnquoted(elements[len(elements)-1], ';') {
+		if i := strings.Index(pair, "="); i > 0 {
+			out[strings.TrimSpace(pair[:i])] = strings.Trim(pair[i+1:], `"`)
+		}
+	}
+	return out
+}
+
+// splitUnquoted splits s around each instance of sep that is not within double quotes.
+func splitUnquoted(s string, sep byte) []string {
+	var out []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				out = append(out, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(out, s[start:])
+}
+
+// parseJWTClaims parses the JSON claims reported by the server. String claims are returned as-is,
+// all others JSON encoded.
+func parseJWTClaims(claimsJSON string) map[string]string {
+	claims := make(map[string]json.RawMessage)
+	if err := json.Unmarshal([]byte(claimsJSON), &claims); err != nil {
+		return nil
+	}
+
+	out := make(map[string]string, len(claims))
+	for name, raw := range claims {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+		out[name] = value
+	}
+	return out
+}
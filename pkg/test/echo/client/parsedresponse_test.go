@@ -0,0 +1,213 @@
+//  Copyright 2019 Istio Authors
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package client
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseResponseExtAuthzCheckResult(t *testing.T) {
+	cases := []struct {
+		name     string
+		output   string
+		expected string
+	}{
+		{
+			name:     "request header",
+			output:   "[0] StatusCode=200\n[0 body] X-Ext-Authz-Check-Result=allowed\n",
+			expected: "allowed",
+		},
+		{
+			// Header names are lowercased when the request is forwarded over HTTP/2.
+			name:     "lowercase request header",
+			output:   "[0] StatusCode=200\n[0 body] x-ext-authz-check-result=allowed\n",
+			expected: "allowed",
+		},
+		{
+			name:     "response header",
+			output:   "[0] StatusCode=403\n[0] ResponseHeader=x-ext-authz-check-result:denied\n",
+			expected: "denied",
+		},
+		{
+			name:   "no check result",
+			output: "[0] StatusCode=200\n[0 body] X-Ext-Authz=allow\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if actual := parseResponse(c.output).ExtAuthzCheckResult; actual != c.expected {
+				t.Fatalf("expected check result %q, got %q", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCheckExtAuthzDecision(t *testing.T) {
+	allowed := &ParsedResponse{Code: "200", ExtAuthzCheckResult: "allowed"}
+	denied := &ParsedResponse{Code: "403", ExtAuthzCheckResult: "denied"}
+
+	cases := []struct {
+		name      string
+		responses ParsedResponses
+		expected  ExtAuthzDecision
+		err       string
+	}{
+		{name: "allowed", responses: ParsedResponses{allowed, allowed}, expected: ExtAuthzAllowed},
+		{name: "denied", responses: ParsedResponses{denied}, expected: ExtAuthzDenied},
+		{name: "unexpected decision", responses: ParsedResponses{allowed, denied}, expected: ExtAuthzAllowed,
+			err: "response[1] ext_authz decision: expected allowed, received denied"},
+		{name: "not checked", responses: ParsedResponses{{Code: "403"}}, expected: ExtAuthzDenied,
+			err: "not checked by ext_authz"},
+		// E.g. the request was allowed by ext_authz, but then rejected by the target.
+		{name: "allowed but rejected", responses: ParsedResponses{{Code: "403", ExtAuthzCheckResult: "allowed"}},
+			expected: ExtAuthzAllowed, err: "expected 200, received 403"},
+		{name: "denied but succeeded", responses: ParsedResponses{{Code: "200", ExtAuthzCheckResult: "denied"}},
+			expected: ExtAuthzDenied, err: "expected 403, received 200"},
+		{name: "no responses", expected: ExtAuthzAllowed, err: "no responses received"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			checkError(t, c.responses.CheckExtAuthzDecision(c.expected), c.err)
+		})
+	}
+}
+
+// checkError fails t unless err contains expected, or is nil if nothing is expected.
+func checkError(t *testing.T, err error, expected string) {
+	t.Helper()
+	if expected == "" {
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Fatalf("expected error containing %q, got: %v", expected, err)
+	}
+}
+
+func TestCheckRateLimited(t *testing.T) {
+	ok := &ParsedResponse{Code: "200"}
+	limited := &ParsedResponse{Code: "429"}
+
+	// A burst of 5 requests against a limit of 2 requests per fill interval.
+	burst := ParsedResponses{ok, ok, limited, limited, limited}
+	if count := burst.RateLimitedCount(); count != 3 {
+		t.Fatalf("expected 3 rate limited requests, got %d", count)
+	}
+
+	cases := []struct {
+		name      string
+		responses ParsedResponses
+		expected  int
+		tolerance int
+		err       string
+	}{
+		{name: "exact", responses: burst, expected: 3},
+		{name: "within tolerance", responses: burst, expected: 4, tolerance: 1},
+		{name: "too few limited", responses: burst, expected: 5, tolerance: 1,
+			err: "3 of 5 requests were rate limited, expected 5 (tolerance 1)"},
+		{name: "too many limited", responses: burst, expected: 1,
+			err: "3 of 5 requests were rate limited, expected 1 (tolerance 0)"},
+		{name: "failed request", responses: ParsedResponses{ok, {Code: "503"}, limited}, expected: 1,
+			err: "response[1] Status Code: expected 200 or 429, received 503"},
+		{name: "no responses", err: "no responses received"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			checkError(t, c.responses.CheckRateLimited(c.expected, c.tolerance), c.err)
+		})
+	}
+}
+
+func TestCheckCompressed(t *testing.T) {
+	r := parseResponse("[0] StatusCode=200\n[0] ContentEncoding=gzip\n[0] EncodedBodySize=120\n[0] DecodedBodySize=1100\n")
+	if r.ContentEncoding != "gzip" || r.EncodedBodySize != 120 || r.DecodedBodySize != 1100 || !r.IsCompressed() {
+		t.Fatalf("expected a gzip compressed response, got %+v", r)
+	}
+
+	cases := []struct {
+		name     string
+		response *ParsedResponse
+		encoding string
+		err      string
+	}{
+		{name: "compressed", response: r, encoding: "gzip"},
+		{name: "other encoding", response: r, encoding: "br", err: "ContentEncoding: expected br, received gzip"},
+		{name: "not encoded", response: &ParsedResponse{Code: "200"}, encoding: "gzip",
+			err: "ContentEncoding: expected gzip, received "},
+		{name: "identity", response: &ParsedResponse{ContentEncoding: "identity", EncodedBodySize: 10, DecodedBodySize: 100},
+			encoding: "identity", err: "not compressed"},
+		// Small bodies may grow when encoded.
+		{name: "larger when encoded", response: &ParsedResponse{ContentEncoding: "gzip", EncodedBodySize: 30, DecodedBodySize: 10},
+			encoding: "gzip", err: "not compressed: encoded size 30, decoded size 10"},
+		// The sizes aren't reported when the body could not be decoded.
+		{name: "not decoded", response: &ParsedResponse{ContentEncoding: "gzip"}, encoding: "gzip",
+			err: "not compressed: encoded size 0, decoded size 0"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			checkError(t, ParsedResponses{c.response}.CheckCompressed(c.encoding), c.err)
+		})
+	}
+}
+
+func TestParseResponseIncompleteBody(t *testing.T) {
+	// The output of a throttled read that failed part way through the body.
+	r := parseResponse("[0] StatusCode=200\n[0] IncompleteBody=34\n[0 error] context deadline exceeded\n" +
+		"[0 body] ServiceVersion=v1\n[0 body] Hostname=a-v1-0\n")
+	if !r.IncompleteBody {
+		t.Fatal("expected an incomplete body")
+	}
+	// The fields are parsed from the part of the body that was received.
+	if r.Code != "200" || r.Version != "v1" || r.Hostname != "a-v1-0" {
+		t.Fatalf("expected the fields of the received part of the body, got %+v", r)
+	}
+
+	if r := parseResponse("[0] StatusCode=200\n[0 body] ServiceVersion=v1\n"); r.IncompleteBody {
+		t.Fatal("expected a complete body")
+	}
+}
+
+func TestParseResponseALPN(t *testing.T) {
+	r := parseResponse("[0] StatusCode=200\n[0] ResponseProtocol=HTTP/2.0\n[0] ALPN=h2\n[0 body] ServiceVersion=v1\n")
+	if r.ALPN != "h2" || r.Protocol != "HTTP/2.0" {
+		t.Fatalf("expected ALPN h2 over HTTP/2.0, got ALPN %q over %q", r.ALPN, r.Protocol)
+	}
+
+	// Plaintext requests don't negotiate a protocol.
+	plaintext := parseResponse("[0] StatusCode=200\n[0] ResponseProtocol=HTTP/1.1\n")
+	if plaintext.ALPN != "" {
+		t.Fatalf("expected no ALPN, got %q", plaintext.ALPN)
+	}
+
+	cases := []struct {
+		name      string
+		responses ParsedResponses
+		expected  string
+		err       string
+	}{
+		{name: "negotiated", responses: ParsedResponses{r, r}, expected: "h2"},
+		{name: "other protocol", responses: ParsedResponses{r}, expected: "http/1.1", err: "ALPN: expected http/1.1, received h2"},
+		{name: "not negotiated", responses: ParsedResponses{r, plaintext}, expected: "h2",
+			err: "response[1] ALPN: expected h2, received "},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			checkError(t, c.responses.CheckALPN(c.expected), c.err)
+		})
+	}
+}
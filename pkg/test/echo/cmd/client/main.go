@@ -25,6 +25,9 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+
 	"istio.io/istio/pkg/cmd"
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/test/echo/common"
@@ -42,6 +45,8 @@ var (
 	headerVal string
 	headers   string
 	msg       string
+	health    bool
+	version   string
 
 	caFile string
 
@@ -58,6 +63,15 @@ where the network configuration doesn't support gRPC to the source pod.'
 `,
 		PersistentPreRunE: configureLogging,
 		Run: func(cmd *cobra.Command, args []string) {
+			if health {
+				// Only check the gRPC health of the server at the URL.
+				if err := checkHealth(); err != nil {
+					log.Fatalf("Health check failed: %v", err)
+					os.Exit(-1)
+				}
+				return
+			}
+
 			// Create a request from the flags.
 			request, err := getRequest()
 			if err != nil {
@@ -118,6 +132,10 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&caFile, "ca", "/cert.crt", "CA root cert file")
 	rootCmd.PersistentFlags().StringVar(&msg, "msg", "HelloWorld",
 		"message to send (for websockets)")
+	rootCmd.PersistentFlags().StringVar(&version, "http-version", common.HTTPVersion11,
+		"HTTP version for http:// and https:// requests (1.0, 1.1 or 2)")
+	rootCmd.PersistentFlags().BoolVar(&health, "health", false,
+		"perform a gRPC health check against the grpc:// URL, rather than sending requests")
 
 	loggingOptions.AttachCobraFlags(rootCmd)
 
@@ -131,6 +149,7 @@ func getRequest() (*proto.ForwardEchoRequest, error) {
 		Count:         int32(count),
 		Qps:           int32(qps),
 		Message:       msg,
+		HttpVersion:   version,
 	}
 
 	// Old http add header - deprecated
@@ -160,6 +179,32 @@ func getRequest() (*proto.ForwardEchoRequest, error) {
 	return request, nil
 }
 
+func checkHealth() error {
+	address := strings.TrimPrefix(url, "grpc://")
+	if address == url {
+		return fmt.Errorf("health check requires a grpc:// URL: %q", url)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, address, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	resp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+	if resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		return fmt.Errorf("server at %s is %s", address, resp.Status)
+	}
+	return nil
+}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(-1)
@@ -15,10 +15,14 @@
 package main
 
 import (
+	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -33,8 +37,11 @@ var (
 	grpcPorts []int
 	uds       string
 	version   string
+	cluster   string
 	crt       string
 	key       string
+	delay     time.Duration
+	statuses  []string
 
 	loggingOptions = log.DefaultOptions()
 
@@ -64,12 +71,21 @@ var (
 				portIndex++
 			}
 
+			pathStatuses, err := parsePathStatuses(statuses)
+			if err != nil {
+				log.Errora(err)
+				os.Exit(-1)
+			}
+
 			s := server.New(server.Config{
-				Ports:     ports,
-				TLSCert:   crt,
-				TLSKey:    key,
-				Version:   version,
-				UDSServer: uds,
+				Ports:        ports,
+				TLSCert:      crt,
+				TLSKey:       key,
+				Version:      version,
+				Cluster:      cluster,
+				UDSServer:    uds,
+				StartupDelay: delay,
+				PathStatuses: pathStatuses,
 			})
 
 			if err := s.Start(); err != nil {
@@ -88,6 +104,28 @@ var (
 	}
 )
 
+// parsePathStatuses parses the path statuses, in the form path=code.
+func parsePathStatuses(values []string) (map[string]int, error) {
+	out := make(map[string]int, len(values))
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid path status %q (want path=code)", v)
+		}
+		code, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid code in path status %q: %v", v, err)
+		}
+		if code < http.StatusOK || code >= 600 {
+			// Only final HTTP status codes. Codes without three digits would make the server panic,
+			// and 1xx codes are informational, so they can't be the status of a response.
+			return nil, fmt.Errorf("invalid code in path status %q: must be 200-599", v)
+		}
+		out[parts[0]] = code
+	}
+	return out, nil
+}
+
 func configureLogging(_ *cobra.Command, _ []string) error {
 	if err := log.Configure(loggingOptions); err != nil {
 		return err
@@ -100,8 +138,13 @@ func init() {
 	rootCmd.PersistentFlags().IntSliceVar(&grpcPorts, "grpc", []int{7070}, "GRPC ports")
 	rootCmd.PersistentFlags().StringVar(&uds, "uds", "", "HTTP server on unix domain socket")
 	rootCmd.PersistentFlags().StringVar(&version, "version", "", "Version string")
+	rootCmd.PersistentFlags().StringVar(&cluster, "cluster", "", "Cluster name reported in responses")
 	rootCmd.PersistentFlags().StringVar(&crt, "crt", "", "gRPC TLS server-side certificate")
 	rootCmd.PersistentFlags().StringVar(&key, "key", "", "gRPC TLS server-side key")
+	rootCmd.PersistentFlags().DurationVar(&delay, "startup-delay", 0,
+		"Delay before the server starts listening on its ports")
+	rootCmd.PersistentFlags().StringSliceVar(&statuses, "path-status", nil,
+		"Status code returned by HTTP ports for requests to a path, in the form path=code")
 
 	loggingOptions.AttachCobraFlags(rootCmd)
 
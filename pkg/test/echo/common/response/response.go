@@ -34,4 +34,48 @@ const (
 	StatusCodeField     Field = "StatusCode"
 	HostField           Field = "Host"
 	HostnameField       Field = "Hostname"
+	URLField            Field = "URL"
+
+	// AuthorityField is the :authority of an HTTP/2 (or gRPC) request received by the server. HTTP/1
+	// requests only carry the Host header (i.e. HostField).
+	AuthorityField Field = "Authority"
+
+	// RemoteAddrField is the remote address of the connection on which the server received the
+	// request, which identifies the connection.
+	RemoteAddrField Field = "RemoteAddr"
+
+	// ForwardedClientCertField is the X-Forwarded-Client-Cert header received by the server.
+	ForwardedClientCertField Field = "X-Forwarded-Client-Cert"
+
+	// ContentEncodingField is the Content-Encoding of a response received by the client.
+	ContentEncodingField Field = "ContentEncoding"
+	// EncodedBodySizeField is the size of the response body, as received on the wire.
+	EncodedBodySizeField Field = "EncodedBodySize"
+	// DecodedBodySizeField is the size of the response body after decoding (e.g. decompression).
+	DecodedBodySizeField Field = "DecodedBodySize"
+	// IncompleteBodyField is reported when the client failed to receive the full response body.
+	IncompleteBodyField Field = "IncompleteBody"
+	// ResponseProtocolField is the protocol (e.g. "HTTP/1.0") of a response received by the client.
+	ResponseProtocolField Field = "ResponseProtocol"
+	// ALPNField is the application protocol (e.g. "h2") negotiated via TLS ALPN by the client.
+	ALPNField Field = "ALPN"
+	// RequestBodyField is the (base64 encoded) body of a request received by the server, if requested
+	// via the echoBody query parameter.
+	RequestBodyField Field = "RequestBody"
+	// RequestHeaderField is a header (in the form name:value) of a request received by the server.
+	RequestHeaderField Field = "RequestHeader"
+	// ResponseHeaderField is a header (in the form name:value) of a response received by the client.
+	ResponseHeaderField Field = "ResponseHeader"
+	// TimeToFirstByteField is the time taken by the client to receive the first byte of an HTTP response.
+	TimeToFirstByteField Field = "TimeToFirstByte"
+	// LatencyField is the time taken by the client to complete a request (including reading the response).
+	LatencyField Field = "Latency"
+	// ClusterField is the name of the cluster of the server, if configured (e.g. via --cluster).
+	ClusterField Field = "Cluster"
+	// EchoField is the message echoed by the server (e.g. in a WebSocket frame or gRPC response).
+	EchoField Field = "Echo"
+	// WebSocketUpgradeField is the status code of the WebSocket upgrade response received by the client.
+	WebSocketUpgradeField Field = "WebSocketUpgrade"
+	// JWTClaimsField holds the claims (as JSON) of the JWT received by the server.
+	JWTClaimsField Field = "JWTClaims"
 )
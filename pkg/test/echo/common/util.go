@@ -27,6 +27,24 @@ const (
 	DefaultCount          = 1
 )
 
+// RequestCountHeader is the header used to identify requests to be counted by the echo server.
+// Requests with the same header value are counted together, and the count can be retrieved with
+// the RequestCount command.
+const RequestCountHeader = "X-Echo-Request-Count-Key"
+
+// HTTP versions supported for HTTP requests made by the client.
+const (
+	HTTPVersion10 = "1.0"
+	HTTPVersion11 = "1.1"
+	HTTPVersion2  = "2"
+
+	// HTTPVersion3 (HTTP over QUIC) is not supported by the client or the proxy, and is rejected
+	// with an error, so that tests relying on it fail clearly rather than falling back to TCP. Calls
+	// over QUIC would also need UDP ports served by the echo application, which only serves TCP, so
+	// only the reporting of the ALPN negotiated over TLS is available (see ParsedResponse.ALPN).
+	HTTPVersion3 = "3"
+)
+
 // FillInDefaults fills in the timeout and count if not specified in the given message.
 func FillInDefaults(request *proto.ForwardEchoRequest) {
 	request.TimeoutMicros = DurationToMicros(GetTimeout(request))
@@ -50,6 +68,14 @@ func GetCount(request *proto.ForwardEchoRequest) int {
 	return DefaultCount
 }
 
+// GetHTTPVersion returns the HTTP version or HTTPVersion11 if not set.
+func GetHTTPVersion(request *proto.ForwardEchoRequest) string {
+	if request.HttpVersion == "" {
+		return HTTPVersion11
+	}
+	return request.HttpVersion
+}
+
 // GetHeaders returns the headers for the message.
 func GetHeaders(request *proto.ForwardEchoRequest) http.Header {
 	headers := make(http.Header)
@@ -149,15 +149,28 @@ func (m *Header) GetValue() string {
 }
 
 type ForwardEchoRequest struct {
-	Count                int32     `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
-	Qps                  int32     `protobuf:"varint,2,opt,name=qps,proto3" json:"qps,omitempty"`
-	TimeoutMicros        int64     `protobuf:"varint,3,opt,name=timeout_micros,json=timeoutMicros,proto3" json:"timeout_micros,omitempty"`
-	Url                  string    `protobuf:"bytes,4,opt,name=url,proto3" json:"url,omitempty"`
-	Headers              []*Header `protobuf:"bytes,5,rep,name=headers,proto3" json:"headers,omitempty"`
-	Message              string    `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
-	XXX_unrecognized     []byte    `json:"-"`
-	XXX_sizecache        int32     `json:"-"`
+	Count         int32     `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	Qps           int32     `protobuf:"varint,2,opt,name=qps,proto3" json:"qps,omitempty"`
+	TimeoutMicros int64     `protobuf:"varint,3,opt,name=timeout_micros,json=timeoutMicros,proto3" json:"timeout_micros,omitempty"`
+	Url           string    `protobuf:"bytes,4,opt,name=url,proto3" json:"url,omitempty"`
+	Headers       []*Header `protobuf:"bytes,5,rep,name=headers,proto3" json:"headers,omitempty"`
+	Message       string    `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+	// If > 0, the rate (in bytes/second) at which response bodies are read.
+	ReadBytesPerSecond int64 `protobuf:"varint,7,opt,name=read_bytes_per_second,json=readBytesPerSecond,proto3" json:"read_bytes_per_second,omitempty"`
+	// The HTTP version used for HTTP requests: "1.0", "1.1" or "2". Defaults to "1.1".
+	HttpVersion string `protobuf:"bytes,8,opt,name=http_version,json=httpVersion,proto3" json:"http_version,omitempty"`
+	// If > 0, HTTP requests are sent as POST with a body of this many bytes.
+	BodySize int64 `protobuf:"varint,9,opt,name=body_size,json=bodySize,proto3" json:"body_size,omitempty"`
+	// If true, gRPC requests are sent as the messages of a single bidirectional stream (see
+	// EchoStream), rather than as separate calls. The timeout applies to the whole stream.
+	Stream bool `protobuf:"varint,10,opt,name=stream,proto3" json:"stream,omitempty"`
+	// If set, HTTP requests are sent as POST with this body (rather than one of body_size bytes).
+	Body []byte `protobuf:"bytes,11,opt,name=body,proto3" json:"body,omitempty"`
+	// If > 0, the maximum number of requests in flight at a time. Defaults to all of them.
+	Concurrency          int32    `protobuf:"varint,12,opt,name=concurrency,proto3" json:"concurrency,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *ForwardEchoRequest) Reset()         { *m = ForwardEchoRequest{} }
@@ -227,6 +240,48 @@ func (m *ForwardEchoRequest) GetMessage() string {
 	return ""
 }
 
+func (m *ForwardEchoRequest) GetReadBytesPerSecond() int64 {
+	if m != nil {
+		return m.ReadBytesPerSecond
+	}
+	return 0
+}
+
+func (m *ForwardEchoRequest) GetHttpVersion() string {
+	if m != nil {
+		return m.HttpVersion
+	}
+	return ""
+}
+
+func (m *ForwardEchoRequest) GetBodySize() int64 {
+	if m != nil {
+		return m.BodySize
+	}
+	return 0
+}
+
+func (m *ForwardEchoRequest) GetStream() bool {
+	if m != nil {
+		return m.Stream
+	}
+	return false
+}
+
+func (m *ForwardEchoRequest) GetBody() []byte {
+	if m != nil {
+		return m.Body
+	}
+	return nil
+}
+
+func (m *ForwardEchoRequest) GetConcurrency() int32 {
+	if m != nil {
+		return m.Concurrency
+	}
+	return 0
+}
+
 type ForwardEchoResponse struct {
 	Output               []string `protobuf:"bytes,1,rep,name=output,proto3" json:"output,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -266,37 +321,129 @@ func (m *ForwardEchoResponse) GetOutput() []string {
 	return nil
 }
 
+type RequestCountRequest struct {
+	// The value of the request count header, identifying the requests to be counted.
+	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RequestCountRequest) Reset()         { *m = RequestCountRequest{} }
+func (m *RequestCountRequest) String() string { return proto.CompactTextString(m) }
+func (*RequestCountRequest) ProtoMessage()    {}
+func (*RequestCountRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_08134aea513e0001, []int{5}
+}
+
+func (m *RequestCountRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RequestCountRequest.Unmarshal(m, b)
+}
+func (m *RequestCountRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RequestCountRequest.Marshal(b, m, deterministic)
+}
+func (m *RequestCountRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RequestCountRequest.Merge(m, src)
+}
+func (m *RequestCountRequest) XXX_Size() int {
+	return xxx_messageInfo_RequestCountRequest.Size(m)
+}
+func (m *RequestCountRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RequestCountRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RequestCountRequest proto.InternalMessageInfo
+
+func (m *RequestCountRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+type RequestCountResponse struct {
+	Count                int64    `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RequestCountResponse) Reset()         { *m = RequestCountResponse{} }
+func (m *RequestCountResponse) String() string { return proto.CompactTextString(m) }
+func (*RequestCountResponse) ProtoMessage()    {}
+func (*RequestCountResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_08134aea513e0001, []int{6}
+}
+
+func (m *RequestCountResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RequestCountResponse.Unmarshal(m, b)
+}
+func (m *RequestCountResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RequestCountResponse.Marshal(b, m, deterministic)
+}
+func (m *RequestCountResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RequestCountResponse.Merge(m, src)
+}
+func (m *RequestCountResponse) XXX_Size() int {
+	return xxx_messageInfo_RequestCountResponse.Size(m)
+}
+func (m *RequestCountResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_RequestCountResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RequestCountResponse proto.InternalMessageInfo
+
+func (m *RequestCountResponse) GetCount() int64 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*EchoRequest)(nil), "proto.EchoRequest")
 	proto.RegisterType((*EchoResponse)(nil), "proto.EchoResponse")
 	proto.RegisterType((*Header)(nil), "proto.Header")
 	proto.RegisterType((*ForwardEchoRequest)(nil), "proto.ForwardEchoRequest")
 	proto.RegisterType((*ForwardEchoResponse)(nil), "proto.ForwardEchoResponse")
+	proto.RegisterType((*RequestCountRequest)(nil), "proto.RequestCountRequest")
+	proto.RegisterType((*RequestCountResponse)(nil), "proto.RequestCountResponse")
 }
 
 func init() { proto.RegisterFile("echo.proto", fileDescriptor_08134aea513e0001) }
 
 var fileDescriptor_08134aea513e0001 = []byte{
-	// 301 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x50, 0xcb, 0x4a, 0xf3, 0x40,
-	0x18, 0x25, 0x7f, 0x3a, 0x29, 0xfd, 0xfa, 0x57, 0x65, 0x5a, 0x64, 0xec, 0xaa, 0x04, 0xa4, 0xd9,
-	0x58, 0xa5, 0xbe, 0x82, 0x8a, 0x1b, 0x37, 0xa3, 0x7b, 0x89, 0xe9, 0x87, 0x09, 0x26, 0x9d, 0x74,
-	0x2e, 0x15, 0xdf, 0xc0, 0x47, 0xf2, 0xf1, 0x64, 0x2e, 0x85, 0x04, 0xc5, 0x55, 0xe6, 0x5c, 0x72,
-	0xe6, 0xcc, 0x01, 0xc0, 0xa2, 0x14, 0xab, 0x56, 0x0a, 0x2d, 0x28, 0x71, 0x9f, 0x74, 0x09, 0xe3,
-	0xdb, 0xa2, 0x14, 0x1c, 0x77, 0x06, 0x95, 0xa6, 0x0c, 0x86, 0x0d, 0x2a, 0x95, 0xbf, 0x22, 0x8b,
-	0x16, 0x51, 0x36, 0xe2, 0x07, 0x98, 0x66, 0xf0, 0xdf, 0x1b, 0x55, 0x2b, 0xb6, 0x0a, 0xff, 0x70,
-	0x5e, 0x41, 0x72, 0x8f, 0xf9, 0x06, 0x25, 0x3d, 0x81, 0xf8, 0x0d, 0x3f, 0x82, 0x6e, 0x8f, 0x74,
-	0x06, 0x64, 0x9f, 0xd7, 0x06, 0xd9, 0x3f, 0xc7, 0x79, 0x90, 0x7e, 0x45, 0x40, 0xef, 0x84, 0x7c,
-	0xcf, 0xe5, 0xa6, 0x5b, 0x66, 0x06, 0xa4, 0x10, 0x66, 0xab, 0x5d, 0x00, 0xe1, 0x1e, 0xd8, 0xd0,
-	0x5d, 0xab, 0x5c, 0x00, 0xe1, 0xf6, 0x48, 0xcf, 0xe1, 0x48, 0x57, 0x0d, 0x0a, 0xa3, 0x9f, 0x9b,
-	0xaa, 0x90, 0x42, 0xb1, 0x78, 0x11, 0x65, 0x31, 0x9f, 0x04, 0xf6, 0xc1, 0x91, 0xf6, 0x47, 0x23,
-	0x6b, 0x36, 0xf0, 0x6d, 0x8c, 0xac, 0xe9, 0x12, 0x86, 0xa5, 0x6b, 0xaa, 0x18, 0x59, 0xc4, 0xd9,
-	0x78, 0x3d, 0xf1, 0xe3, 0xac, 0x7c, 0x7f, 0x7e, 0x50, 0xbb, 0x8f, 0x4d, 0xfa, 0x8f, 0xbd, 0x80,
-	0x69, 0xaf, 0x79, 0x58, 0xe7, 0x14, 0x12, 0x61, 0x74, 0x6b, 0x6c, 0xf7, 0x38, 0x1b, 0xf1, 0x80,
-	0xd6, 0x9f, 0x11, 0x1c, 0x5b, 0xe3, 0x13, 0x2a, 0xfd, 0x88, 0x72, 0x5f, 0x15, 0x48, 0x2f, 0x61,
-	0x60, 0x29, 0x4a, 0xc3, 0xe5, 0x9d, 0x09, 0xe6, 0xd3, 0x1e, 0x17, 0xc2, 0x6f, 0x60, 0xdc, 0xb9,
-	0x93, 0x9e, 0x05, 0xcf, 0xcf, 0x05, 0xe7, 0xf3, 0xdf, 0x24, 0x9f, 0xf2, 0x92, 0x38, 0xe9, 0xfa,
-	0x3b, 0x00, 0x00, 0xff, 0xff, 0x11, 0x07, 0xc8, 0x61, 0x15, 0x02, 0x00, 0x00,
+	// 470 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x52, 0x4d, 0x8f, 0xd3, 0x30,
+	0x10, 0x55, 0x36, 0x4d, 0xda, 0x4e, 0xb2, 0x80, 0xa6, 0x65, 0x65, 0xba, 0x97, 0x10, 0x09, 0x35,
+	0x07, 0x58, 0x96, 0xe5, 0xc4, 0x95, 0xef, 0x0b, 0x12, 0x4a, 0x11, 0xd7, 0x28, 0x4d, 0x46, 0x34,
+	0xa2, 0x8d, 0xb3, 0xb6, 0x53, 0xd4, 0xfd, 0x0d, 0xfc, 0x61, 0x6e, 0xc8, 0x8e, 0x2b, 0x25, 0xda,
+	0x0a, 0x71, 0xca, 0xcc, 0x7b, 0xe3, 0x97, 0xf1, 0x7b, 0x06, 0xa0, 0x62, 0xc3, 0xaf, 0x1a, 0xc1,
+	0x15, 0x47, 0xcf, 0x7c, 0xe2, 0x25, 0x04, 0x1f, 0x8a, 0x0d, 0x4f, 0xe9, 0xb6, 0x25, 0xa9, 0x90,
+	0xc1, 0x78, 0x47, 0x52, 0xe6, 0x3f, 0x88, 0x39, 0x91, 0x93, 0x4c, 0xd3, 0x63, 0x1b, 0x27, 0x10,
+	0x76, 0x83, 0xb2, 0xe1, 0xb5, 0xa4, 0x7f, 0x4c, 0x5e, 0x83, 0xff, 0x99, 0xf2, 0x92, 0x04, 0x3e,
+	0x02, 0xf7, 0x27, 0x1d, 0x2c, 0xaf, 0x4b, 0x9c, 0x83, 0xb7, 0xcf, 0xb7, 0x2d, 0xb1, 0x33, 0x83,
+	0x75, 0x4d, 0xfc, 0xe7, 0x0c, 0xf0, 0x23, 0x17, 0xbf, 0x72, 0x51, 0xf6, 0x97, 0x99, 0x83, 0x57,
+	0xf0, 0xb6, 0x56, 0x46, 0xc0, 0x4b, 0xbb, 0x46, 0x8b, 0xde, 0x36, 0xd2, 0x08, 0x78, 0xa9, 0x2e,
+	0xf1, 0x19, 0x3c, 0x50, 0xd5, 0x8e, 0x78, 0xab, 0xb2, 0x5d, 0x55, 0x08, 0x2e, 0x99, 0x1b, 0x39,
+	0x89, 0x9b, 0x9e, 0x5b, 0xf4, 0x8b, 0x01, 0xf5, 0xc1, 0x56, 0x6c, 0xd9, 0xa8, 0xdb, 0xa6, 0x15,
+	0x5b, 0x5c, 0xc2, 0x78, 0x63, 0x36, 0x95, 0xcc, 0x8b, 0xdc, 0x24, 0xb8, 0x39, 0xef, 0xcc, 0xb9,
+	0xea, 0xf6, 0x4f, 0x8f, 0x6c, 0xff, 0xb2, 0xfe, 0xe0, 0xb2, 0xf8, 0x0a, 0x1e, 0x0b, 0xca, 0xcb,
+	0x6c, 0x7d, 0x50, 0x24, 0xb3, 0x86, 0x44, 0x26, 0xa9, 0xe0, 0x75, 0xc9, 0xc6, 0x66, 0x05, 0xd4,
+	0xe4, 0x5b, 0xcd, 0x7d, 0x25, 0xb1, 0x32, 0x0c, 0x3e, 0x85, 0x70, 0xa3, 0x54, 0x93, 0xed, 0x49,
+	0xc8, 0x8a, 0xd7, 0x6c, 0x62, 0x14, 0x03, 0x8d, 0x7d, 0xef, 0x20, 0xbc, 0x84, 0xe9, 0x9a, 0x97,
+	0x87, 0x4c, 0x56, 0x77, 0xc4, 0xa6, 0x46, 0x69, 0xa2, 0x81, 0x55, 0x75, 0x47, 0x78, 0x01, 0xbe,
+	0x54, 0x82, 0xf2, 0x1d, 0x83, 0xc8, 0x49, 0x26, 0xa9, 0xed, 0x10, 0x61, 0xa4, 0x67, 0x58, 0x10,
+	0x39, 0x49, 0x98, 0x9a, 0x1a, 0x23, 0x08, 0x0a, 0x5e, 0x17, 0xad, 0x10, 0x54, 0x17, 0x07, 0x16,
+	0x1a, 0xd3, 0xfa, 0x50, 0xfc, 0x02, 0x66, 0x03, 0xeb, 0x6d, 0xbc, 0x17, 0xe0, 0xf3, 0x56, 0x35,
+	0xad, 0x36, 0xdf, 0x4d, 0xa6, 0xa9, 0xed, 0xe2, 0x25, 0xcc, 0x6c, 0x3c, 0xef, 0x74, 0x1a, 0xb6,
+	0xbe, 0x9f, 0x74, 0xfc, 0x1c, 0xe6, 0xc3, 0x41, 0x2b, 0x3c, 0x08, 0xd5, 0xb5, 0xa1, 0xde, 0xfc,
+	0x3e, 0x83, 0x87, 0xfa, 0xff, 0xdf, 0x48, 0xaa, 0x15, 0x89, 0x7d, 0x55, 0x10, 0xbe, 0x84, 0x91,
+	0x86, 0x10, 0x6d, 0x28, 0xbd, 0xa7, 0xb1, 0x98, 0x0d, 0x30, 0x2b, 0xfd, 0x06, 0x40, 0xf7, 0x2b,
+	0x6b, 0xc7, 0x7f, 0x1e, 0x4b, 0x9c, 0x6b, 0x07, 0xdf, 0x43, 0xd0, 0x73, 0x01, 0x9f, 0xd8, 0xb9,
+	0xfb, 0x8f, 0x72, 0xb1, 0x38, 0x45, 0xd9, 0x05, 0x3e, 0x41, 0xd8, 0xbf, 0x33, 0x1e, 0x67, 0x4f,
+	0x38, 0xb6, 0xb8, 0x3c, 0xc9, 0x75, 0x42, 0x6b, 0xdf, 0x70, 0xaf, 0xff, 0x0e, 0x00, 0x40, 0x3c,
+	0x40, 0x2b, 0xb1, 0x03, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -312,7 +459,10 @@ const _ = grpc.SupportPackageIsVersion4
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type EchoTestServiceClient interface {
 	Echo(ctx context.Context, in *EchoRequest, opts ...grpc.CallOption) (*EchoResponse, error)
+	// EchoStream responds to each message of the stream, as Echo does to each call.
+	EchoStream(ctx context.Context, opts ...grpc.CallOption) (EchoTestService_EchoStreamClient, error)
 	ForwardEcho(ctx context.Context, in *ForwardEchoRequest, opts ...grpc.CallOption) (*ForwardEchoResponse, error)
+	RequestCount(ctx context.Context, in *RequestCountRequest, opts ...grpc.CallOption) (*RequestCountResponse, error)
 }
 
 type echoTestServiceClient struct {
@@ -332,6 +482,37 @@ func (c *echoTestServiceClient) Echo(ctx context.Context, in *EchoRequest, opts
 	return out, nil
 }
 
+func (c *echoTestServiceClient) EchoStream(ctx context.Context, opts ...grpc.CallOption) (EchoTestService_EchoStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_EchoTestService_serviceDesc.Streams[0], "/proto.EchoTestService/EchoStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &echoTestServiceEchoStreamClient{stream}
+	return x, nil
+}
+
+type EchoTestService_EchoStreamClient interface {
+	Send(*EchoRequest) error
+	Recv() (*EchoResponse, error)
+	grpc.ClientStream
+}
+
+type echoTestServiceEchoStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *echoTestServiceEchoStreamClient) Send(m *EchoRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *echoTestServiceEchoStreamClient) Recv() (*EchoResponse, error) {
+	m := new(EchoResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *echoTestServiceClient) ForwardEcho(ctx context.Context, in *ForwardEchoRequest, opts ...grpc.CallOption) (*ForwardEchoResponse, error) {
 	out := new(ForwardEchoResponse)
 	err := c.cc.Invoke(ctx, "/proto.EchoTestService/ForwardEcho", in, out, opts...)
@@ -341,10 +522,22 @@ func (c *echoTestServiceClient) ForwardEcho(ctx context.Context, in *ForwardEcho
 	return out, nil
 }
 
+func (c *echoTestServiceClient) RequestCount(ctx context.Context, in *RequestCountRequest, opts ...grpc.CallOption) (*RequestCountResponse, error) {
+	out := new(RequestCountResponse)
+	err := c.cc.Invoke(ctx, "/proto.EchoTestService/RequestCount", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // EchoTestServiceServer is the server API for EchoTestService service.
 type EchoTestServiceServer interface {
 	Echo(context.Context, *EchoRequest) (*EchoResponse, error)
+	// EchoStream responds to each message of the stream, as Echo does to each call.
+	EchoStream(EchoTestService_EchoStreamServer) error
 	ForwardEcho(context.Context, *ForwardEchoRequest) (*ForwardEchoResponse, error)
+	RequestCount(context.Context, *RequestCountRequest) (*RequestCountResponse, error)
 }
 
 func RegisterEchoTestServiceServer(s *grpc.Server, srv EchoTestServiceServer) {
@@ -369,6 +562,32 @@ func _EchoTestService_Echo_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _EchoTestService_EchoStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(EchoTestServiceServer).EchoStream(&echoTestServiceEchoStreamServer{stream})
+}
+
+type EchoTestService_EchoStreamServer interface {
+	Send(*EchoResponse) error
+	Recv() (*EchoRequest, error)
+	grpc.ServerStream
+}
+
+type echoTestServiceEchoStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *echoTestServiceEchoStreamServer) Send(m *EchoResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *echoTestServiceEchoStreamServer) Recv() (*EchoRequest, error) {
+	m := new(EchoRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func _EchoTestService_ForwardEcho_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ForwardEchoRequest)
 	if err := dec(in); err != nil {
@@ -387,6 +606,24 @@ func _EchoTestService_ForwardEcho_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _EchoTestService_RequestCount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestCountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EchoTestServiceServer).RequestCount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.EchoTestService/RequestCount",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EchoTestServiceServer).RequestCount(ctx, req.(*RequestCountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _EchoTestService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "proto.EchoTestService",
 	HandlerType: (*EchoTestServiceServer)(nil),
@@ -399,7 +636,18 @@ var _EchoTestService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "ForwardEcho",
 			Handler:    _EchoTestService_ForwardEcho_Handler,
 		},
+		{
+			MethodName: "RequestCount",
+			Handler:    _EchoTestService_RequestCount_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "EchoStream",
+			Handler:       _EchoTestService_EchoStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "echo.proto",
 }
@@ -0,0 +1,53 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoint
+
+import (
+	"sync"
+)
+
+// RequestCounter counts the requests received by the endpoints of a server, keyed by the value of
+// the common.RequestCountHeader header. Requests without the header are not counted.
+type RequestCounter struct {
+	mutex  sync.Mutex
+	counts map[string]int64
+}
+
+// NewRequestCounter creates a new, empty RequestCounter.
+func NewRequestCounter() *RequestCounter {
+	return &RequestCounter{
+		counts: make(map[string]int64),
+	}
+}
+
+// Increment the count for the given key. Does nothing if the counter is nil or the key is empty.
+func (c *RequestCounter) Increment(key string) {
+	if c == nil || key == "" {
+		return
+	}
+	c.mutex.Lock()
+	c.counts[key]++
+	c.mutex.Unlock()
+}
+
+// Count returns the number of requests received with the given key.
+func (c *RequestCounter) Count(key string) int64 {
+	if c == nil {
+		return 0
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.counts[key]
+}
@@ -18,13 +18,19 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"strconv"
+	"strings"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/test/echo/common"
@@ -70,6 +76,9 @@ func (s *grpcInstance) Start(onReady OnReadyFunc) error {
 	proto.RegisterEchoTestServiceServer(s.server, &grpcHandler{
 		Config: s.Config,
 	})
+	grpc_health_v1.RegisterHealthServer(s.server, &grpcHealthHandler{
+		Config: s.Config,
+	})
 
 	// Start serving GRPC traffic.
 	go func() {
@@ -122,15 +131,45 @@ type grpcHandler struct {
 }
 
 func (h *grpcHandler) Echo(ctx context.Context, req *proto.EchoRequest) (*proto.EchoResponse, error) {
+	return h.echoResponse(ctx, req), nil
+}
+
+func (h *grpcHandler) EchoStream(stream proto.EchoTestService_EchoStreamServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(h.echoResponse(stream.Context(), req)); err != nil {
+			return err
+		}
+	}
+}
+
+// echoResponse returns the response to the request, which describes the request (e.g. its metadata)
+// and this server.
+func (h *grpcHandler) echoResponse(ctx context.Context, req *proto.EchoRequest) *proto.EchoResponse {
 	body := bytes.Buffer{}
 	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, key := range md.Get(common.RequestCountHeader) {
+			h.Counter.Increment(key)
+		}
 		for key, values := range md {
 			field := response.Field(key)
 			if key == ":authority" {
 				field = response.HostField
+				for _, value := range values {
+					writeField(&body, response.AuthorityField, value)
+				}
 			}
 			for _, value := range values {
 				writeField(&body, field, value)
+				if !strings.HasPrefix(key, ":") {
+					writeField(&body, response.RequestHeaderField, key+":"+value)
+				}
 			}
 		}
 	}
@@ -141,14 +180,20 @@ func (h *grpcHandler) Echo(ctx context.Context, req *proto.EchoRequest) (*proto.
 
 	writeField(&body, response.StatusCodeField, response.StatusCodeOK)
 	writeField(&body, response.ServiceVersionField, h.Version)
+	if h.Cluster != "" {
+		writeField(&body, response.ClusterField, h.Cluster)
+	}
 	writeField(&body, response.ServicePortField, strconv.Itoa(portNumber))
-	writeField(&body, response.Field("Echo"), req.GetMessage())
+	writeField(&body, response.EchoField, req.GetMessage())
+	if p, ok := peer.FromContext(ctx); ok {
+		writeField(&body, response.RemoteAddrField, p.Addr.String())
+	}
 
 	if hostname, err := os.Hostname(); err == nil {
 		writeField(&body, response.HostnameField, hostname)
 	}
 
-	return &proto.EchoResponse{Message: body.String()}, nil
+	return &proto.EchoResponse{Message: body.String()}
 }
 
 func (h *grpcHandler) ForwardEcho(ctx context.Context, req *proto.ForwardEchoRequest) (*proto.ForwardEchoResponse, error) {
@@ -163,3 +208,26 @@ func (h *grpcHandler) ForwardEcho(ctx context.Context, req *proto.ForwardEchoReq
 
 	return instance.Run(ctx)
 }
+
+func (h *grpcHandler) RequestCount(_ context.Context, req *proto.RequestCountRequest) (*proto.RequestCountResponse, error) {
+	return &proto.RequestCountResponse{Count: h.Counter.Count(req.Key)}, nil
+}
+
+var _ grpc_health_v1.HealthServer = &grpcHealthHandler{}
+
+// grpcHealthHandler implements the standard gRPC health service, reporting SERVING only once the
+// server (i.e. all endpoints) has become ready.
+type grpcHealthHandler struct {
+	Config
+}
+
+func (h *grpcHealthHandler) Check(context.Context, *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	if !h.IsServerReady() {
+		return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_NOT_SERVING}, nil
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func (h *grpcHealthHandler) Watch(*grpc_health_v1.HealthCheckRequest, grpc_health_v1.Health_WatchServer) error {
+	return status.Error(codes.Unimplemented, "health watch is not supported")
+}
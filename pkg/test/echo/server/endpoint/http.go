@@ -17,7 +17,9 @@ package endpoint
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"net"
 	"net/http"
@@ -37,6 +39,9 @@ import (
 const (
 	readyTimeout  = 10 * time.Second
 	readyInterval = 2 * time.Second
+
+	// chunkSize is the size of the padding in each chunk of a streamed response body.
+	chunkSize = 1024
 )
 
 var (
@@ -177,6 +182,8 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.Counter.Increment(r.Header.Get(common.RequestCountHeader))
+
 	if common.IsWebSocketRequest(r) {
 		h.webSocketEcho(w, r)
 	} else {
@@ -193,10 +200,23 @@ func writeError(out *bytes.Buffer, msg string) {
 func (h *httpHandler) echo(w http.ResponseWriter, r *http.Request) {
 	body := bytes.Buffer{}
 
+	// Read the request body before parsing the form, which may consume it.
+	requestBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(&body, "request body error: "+err.Error())
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(requestBody))
+
 	if err := r.ParseForm(); err != nil {
 		writeError(&body, "ParseForm() error: "+err.Error())
 	}
 
+	// If the request has form ?echoBody=true return the request body, encoded as base64 to preserve
+	// it verbatim in the (line-based) response.
+	if r.URL.Query().Get("echoBody") == "true" {
+		writeField(&body, response.RequestBodyField, base64.StdEncoding.EncodeToString(requestBody))
+	}
+
 	// If the request has form ?headers=name:value[,name:value]* return those headers in response
 	if err := setHeaderResponseFromHeaders(r, w); err != nil {
 		writeError(&body, "response headers error: "+err.Error())
@@ -205,19 +225,72 @@ func (h *httpHandler) echo(w http.ResponseWriter, r *http.Request) {
 	// If the request has form ?codes=code[:chance][,code[:chance]]* return those codes, rather than 200
 	// For example, ?codes=500:1,200:1 returns 500 1/2 times and 200 1/2 times
 	// For example, ?codes=500:90,200:10 returns 500 90% of times and 200 10% of times
-	if err := setResponseFromCodes(r, w); err != nil {
+	// The codes may be limited to specific hosts with ?hosts=hostname[,hostname]*, in which case
+	// all other hosts return 200. Otherwise, the code configured for the path (if any) is returned.
+	if code, ok := h.PathStatuses[r.URL.Path]; ok && r.FormValue("codes") == "" {
+		w.WriteHeader(code)
+	} else if err := setResponseFromCodes(r, w); err != nil {
 		writeError(&body, "codes error: "+err.Error())
 	}
 
+	// If the request has form ?chunks=count:delay (e.g. ?chunks=10:100ms) stream that many additional
+	// chunks of the response body, with the given delay before each chunk.
+	chunks, chunkDelay, err := parseChunks(r.FormValue("chunks"))
+	if err != nil {
+		writeError(&body, "chunks error: "+err.Error())
+	}
+
 	h.addResponsePayload(r, &body)
 
 	w.Header().Set("Content-Type", "application/text")
 	if _, err := w.Write(body.Bytes()); err != nil {
 		log.Warna(err)
 	}
+	if chunks > 0 {
+		writeChunks(w, chunks, chunkDelay)
+	}
 	log.Infof("Response Headers: %+v", w.Header())
 }
 
+// parseChunks parses the value of the chunks query parameter, in the form count:delay.
+func parseChunks(s string) (int, time.Duration, error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid %q (want count:delay)", s)
+	}
+	count, err := strconv.Atoi(parts[0])
+	if err != nil || count < 0 {
+		return 0, 0, fmt.Errorf("invalid count %q", parts[0])
+	}
+	delay, err := time.ParseDuration(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid delay %q: %v", parts[1], err)
+	}
+	return count, delay, nil
+}
+
+// writeChunks streams the given number of chunks of the response body, flushing the response before
+// each delay so that the client receives the body while it is being produced.
+func writeChunks(w http.ResponseWriter, count int, delay time.Duration) {
+	padding := strings.Repeat("a", chunkSize)
+	for i := 0; i < count; i++ {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		time.Sleep(delay)
+
+		chunk := bytes.Buffer{}
+		writeField(&chunk, response.Field("Chunk"), strconv.Itoa(i)+":"+padding)
+		if _, err := w.Write(chunk.Bytes()); err != nil {
+			log.Warna(err)
+			return
+		}
+	}
+}
+
 func (h *httpHandler) webSocketEcho(w http.ResponseWriter, r *http.Request) {
 	// adapted from https://github.com/gorilla/websocket/blob/master/examples/echo/server.go
 	// First send upgrade headers
@@ -238,7 +311,7 @@ func (h *httpHandler) webSocketEcho(w http.ResponseWriter, r *http.Request) {
 
 	body := bytes.Buffer{}
 	h.addResponsePayload(r, &body)
-	body.Write(message)
+	writeField(&body, response.EchoField, string(message))
 
 	writeField(&body, response.StatusCodeField, response.StatusCodeOK)
 
@@ -258,21 +331,30 @@ func (h *httpHandler) addResponsePayload(r *http.Request, body *bytes.Buffer) {
 	}
 
 	writeField(body, response.ServiceVersionField, h.Version)
+	if h.Cluster != "" {
+		writeField(body, response.ClusterField, h.Cluster)
+	}
 	writeField(body, response.ServicePortField, port)
 	writeField(body, response.HostField, r.Host)
+	if r.ProtoMajor >= 2 {
+		writeField(body, response.AuthorityField, r.Host)
+	}
 
 	writeField(body, response.Field("Method"), r.Method)
-	writeField(body, response.Field("URL"), r.URL.String())
+	writeField(body, response.URLField, r.URL.String())
 	writeField(body, response.Field("Proto"), r.Proto)
-	writeField(body, response.Field("RemoteAddr"), r.RemoteAddr)
+	writeField(body, response.RemoteAddrField, r.RemoteAddr)
 	writeField(body, response.Field("Method"), r.Method)
 
 	for name, values := range r.Header {
 		for _, value := range values {
 			writeField(body, response.Field(name), value)
+			writeField(body, response.RequestHeaderField, name+":"+value)
 		}
 	}
 
+	writeJWTClaims(body, r.Header)
+
 	if hostname, err := os.Hostname(); err == nil {
 		writeField(body, response.HostnameField, hostname)
 	}
@@ -299,6 +381,9 @@ func setHeaderResponseFromHeaders(request *http.Request, response http.ResponseW
 
 func setResponseFromCodes(request *http.Request, response http.ResponseWriter) error {
 	responseCodes := request.FormValue("codes")
+	if !isTargetedHost(request.FormValue("hosts")) {
+		responseCodes = ""
+	}
 
 	codes, err := validateCodes(responseCodes)
 	if err != nil {
@@ -383,3 +468,21 @@ func validateCodeAndSlices(codecount string) (codeAndSlices, error) {
 
 	return codeAndSlices{n, count}, nil
 }
+
+// isTargetedHost indicates whether this host is in the given comma-separated list of hostnames. An
+// empty list targets all hosts.
+func isTargetedHost(hosts string) bool {
+	if hosts == "" {
+		return true
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return false
+	}
+	for _, h := range strings.Split(hosts, ",") {
+		if h == hostname {
+			return true
+		}
+	}
+	return false
+}
@@ -32,11 +32,14 @@ type OnReadyFunc func()
 type Config struct {
 	IsServerReady IsServerReadyFunc
 	Version       string
+	Cluster       string
 	TLSCert       string
 	TLSKey        string
 	UDSServer     string
 	Dialer        common.Dialer
 	Port          *model.Port
+	Counter       *RequestCounter
+	PathStatuses  map[string]int
 }
 
 // Instance of an endpoint that serves the Echo application on a single port/protocol.
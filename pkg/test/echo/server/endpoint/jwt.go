@@ -0,0 +1,79 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoint
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"istio.io/istio/pkg/test/echo/common/response"
+)
+
+// istioPayloadHeader carries the (base64url encoded) payload of a JWT verified by the Istio
+// authentication filter, which may not forward the original token.
+const istioPayloadHeader = "sec-istio-auth-userinfo"
+
+// writeJWTClaims writes the claims of the JWT received with the request, if any. The JWT is taken
+// from the Authorization header or, if the token was not forwarded, from the payload header set by
+// Istio. The signature is not verified, since verification is the job of the proxy.
+func writeJWTClaims(body *bytes.Buffer, header http.Header) {
+	var payload string
+	if auth := header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		parts := strings.Split(strings.TrimPrefix(auth, "Bearer "), ".")
+		if len(parts) == 3 {
+			payload = parts[1]
+		}
+	}
+	if payload == "" {
+		payload = header.Get(istioPayloadHeader)
+	}
+	if payload == "" {
+		return
+	}
+
+	claims, err := parseJWTPayload(payload)
+	if err != nil {
+		writeError(body, "jwt error: "+err.Error())
+		return
+	}
+
+	// Claims are written on a single line, as (compact) JSON.
+	by, err := json.Marshal(claims)
+	if err != nil {
+		writeError(body, "jwt error: "+err.Error())
+		return
+	}
+	writeField(body, response.JWTClaimsField, string(by))
+}
+
+func parseJWTPayload(payload string) (map[string]interface{}, error) {
+	by, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(payload, "="))
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make(map[string]interface{})
+	if err := json.Unmarshal(by, &claims); err != nil {
+		return nil, err
+	}
+	if len(claims) == 0 {
+		return nil, errors.New("no claims found in JWT payload")
+	}
+	return claims, nil
+}
@@ -20,10 +20,13 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 
+	"istio.io/istio/pkg/test/echo/common/response"
 	"istio.io/istio/pkg/test/echo/proto"
 )
 
@@ -40,13 +43,7 @@ func (c *grpcProtocol) makeRequest(ctx context.Context, req *request) (string, e
 	defer cancel()
 
 	// Add headers to the request context.
-	outMD := make(metadata.MD)
-	for k, v := range req.Header {
-		// Exclude the Host header from the GRPC context.
-		if !strings.EqualFold(hostHeader, k) {
-			outMD.Set(k, v...)
-		}
-	}
+	outMD := outgoingMetadata(req)
 	outMD.Set("X-Request-Id", strconv.Itoa(req.RequestID))
 	ctx = metadata.NewOutgoingContext(ctx, outMD)
 
@@ -64,12 +61,91 @@ func (c *grpcProtocol) makeRequest(ctx context.Context, req *request) (string, e
 	// when the underlying HTTP2 request returns status 404, GRPC
 	// request does not return an error in grpc-go.
 	// instead it just returns an empty response
+	writeBody(&outBuffer, req.RequestID, resp)
+	return outBuffer.String(), nil
+}
+
+// makeStreamRequest sends the requests as the messages of a single stream, returning the output for
+// each response in order. Fails if the stream can't be opened or ends before all responses were
+// received. The headers and timeout of the first request apply to the whole stream.
+func (c *grpcProtocol) makeStreamRequest(ctx context.Context, reqs []*request, throttle *time.Ticker) ([]string, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, reqs[0].Timeout)
+	defer cancel()
+	ctx = metadata.NewOutgoingContext(ctx, outgoingMetadata(reqs[0]))
+
+	stream, err := c.client.EchoStream(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening stream: %v", err)
+	}
+
+	// Send the requests concurrently with receiving the responses, recording when each was sent.
+	sent := make([]time.Time, len(reqs))
+	var sentMux sync.Mutex
+	sendErr := make(chan error, 1)
+	go func() {
+		for _, req := range reqs {
+			if throttle != nil {
+				<-throttle.C
+			}
+			sentMux.Lock()
+			sent[req.RequestID] = time.Now()
+			sentMux.Unlock()
+			if err := stream.Send(&proto.EchoRequest{Message: fmt.Sprintf("request #%d", req.RequestID)}); err != nil {
+				sendErr <- err
+				return
+			}
+		}
+		sendErr <- stream.CloseSend()
+	}()
+
+	out := make([]string, 0, len(reqs))
+	for _, req := range reqs {
+		resp, err := stream.Recv()
+		if err != nil {
+			select {
+			case e := <-sendErr:
+				if e != nil {
+					err = e
+				}
+			default:
+			}
+			return nil, fmt.Errorf("stream ended after %d of %d responses: %v", len(out), len(reqs), err)
+		}
+
+		var outBuffer bytes.Buffer
+		outBuffer.WriteString(fmt.Sprintf("[%d] grpcecho.EchoStream(%v)\n", req.RequestID, req))
+		writeBody(&outBuffer, req.RequestID, resp)
+		sentMux.Lock()
+		latency := time.Since(sent[req.RequestID])
+		sentMux.Unlock()
+		outBuffer.WriteString(fmt.Sprintf("[%d] %s=%s\n", req.RequestID, response.LatencyField, latency))
+		out = append(out, outBuffer.String())
+	}
+	return out, nil
+}
+
+// outgoingMetadata returns the metadata for the headers of the request.
+func outgoingMetadata(req *request) metadata.MD {
+	outMD := make(metadata.MD)
+	for k, v := range req.Header {
+		// Exclude the Host header from the GRPC context.
+		if !strings.EqualFold(hostHeader, k) {
+			outMD.Set(k, v...)
+		}
+	}
+	return outMD
+}
+
+// writeBody writes the lines of the body of the response to the output for the request.
+func writeBody(out *bytes.Buffer, requestID int, resp *proto.EchoResponse) {
 	for _, line := range strings.Split(resp.GetMessage(), "\n") {
 		if line != "" {
-			outBuffer.WriteString(fmt.Sprintf("[%d body] %s\n", req.RequestID, line))
+			out.WriteString(fmt.Sprintf("[%d body] %s\n", requestID, line))
 		}
 	}
-	return outBuffer.String(), nil
 }
 
 func (c *grpcProtocol) Close() error {
@@ -0,0 +1,148 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forwarder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"istio.io/istio/pkg/test/echo/common/response"
+	"istio.io/istio/pkg/test/echo/proto"
+)
+
+// streamServer echoes the messages of a stream, ending it after limit messages if set.
+type streamServer struct {
+	proto.EchoTestServiceServer
+	limit int
+
+	mutex    sync.Mutex
+	received []string
+}
+
+func (s *streamServer) EchoStream(stream proto.EchoTestService_EchoStreamServer) error {
+	for i := 0; s.limit == 0 || i < s.limit; i++ {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.mutex.Lock()
+		s.received = append(s.received, req.GetMessage())
+		s.mutex.Unlock()
+		if err := stream.Send(&proto.EchoResponse{Message: "Echo=" + req.GetMessage()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newStreamProtocol returns a protocol connected to a server for s, and a function stopping both.
+func newStreamProtocol(t *testing.T, s *streamServer) (*grpcProtocol, func()) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	proto.RegisterEchoTestServiceServer(server, s)
+	go func() { _ = server.Serve(listener) }()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		server.Stop()
+		t.Fatal(err)
+	}
+	p := &grpcProtocol{conn: conn, client: proto.NewEchoTestServiceClient(conn)}
+	return p, func() {
+		_ = p.Close()
+		server.Stop()
+	}
+}
+
+func streamRequests(n int) []*request {
+	reqs := make([]*request, 0, n)
+	for i := 0; i < n; i++ {
+		reqs = append(reqs, &request{RequestID: i, Timeout: 10 * time.Second})
+	}
+	return reqs
+}
+
+func TestMakeStreamRequest(t *testing.T) {
+	cases := []struct {
+		name     string
+		throttle time.Duration
+	}{
+		{name: "unthrottled"},
+		{name: "throttled", throttle: time.Millisecond},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := &streamServer{}
+			p, stop := newStreamProtocol(t, s)
+			defer stop()
+
+			var throttle *time.Ticker
+			if c.throttle > 0 {
+				throttle = time.NewTicker(c.throttle)
+				defer throttle.Stop()
+			}
+			out, err := p.makeStreamRequest(context.Background(), streamRequests(5), throttle)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// All messages are sent over the one stream, in order, and each response is reported for
+			// its request.
+			if len(out) != 5 {
+				t.Fatalf("expected output for 5 requests, got %d:\n%s", len(out), strings.Join(out, ""))
+			}
+			for i, o := range out {
+				for _, expected := range []string{
+					fmt.Sprintf("[%d] grpcecho.EchoStream(", i),
+					fmt.Sprintf("[%d body] Echo=request #%d\n", i, i),
+					fmt.Sprintf("[%d] %s=", i, response.LatencyField),
+				} {
+					if !strings.Contains(o, expected) {
+						t.Fatalf("expected %q in output of request %d:\n%s", expected, i, o)
+					}
+				}
+			}
+			expected := "request #0,request #1,request #2,request #3,request #4"
+			if received := strings.Join(s.received, ","); received != expected {
+				t.Fatalf("expected messages %s, received %s", expected, received)
+			}
+		})
+	}
+}
+
+func TestMakeStreamRequestEnded(t *testing.T) {
+	p, stop := newStreamProtocol(t, &streamServer{limit: 2})
+	defer stop()
+
+	out, err := p.makeStreamRequest(context.Background(), streamRequests(5), nil)
+	if err == nil || !strings.Contains(err.Error(), "stream ended after 2 of 5 responses") {
+		t.Fatalf("expected the stream to end early, got: %v\n%s", err, strings.Join(out, ""))
+	}
+}
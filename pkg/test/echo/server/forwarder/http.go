@@ -16,11 +16,17 @@ package forwarder
 
 import (
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptrace"
 	"strings"
+	"time"
 
 	"istio.io/istio/pkg/test/echo/common"
 	"istio.io/istio/pkg/test/echo/common/response"
@@ -29,8 +35,9 @@ import (
 var _ protocol = &httpProtocol{}
 
 type httpProtocol struct {
-	client *http.Client
-	do     common.HTTPDoFunc
+	client    *http.Client
+	tlsConfig *tls.Config
+	do        common.HTTPDoFunc
 }
 
 func (c *httpProtocol) setHost(r *http.Request, host string) {
@@ -39,13 +46,18 @@ func (c *httpProtocol) setHost(r *http.Request, host string) {
 	if r.URL.Scheme == "https" {
 		// Set SNI value to be same as the request Host
 		// For use with SNI routing tests
-		httpTransport := c.client.Transport.(*http.Transport)
-		httpTransport.TLSClientConfig.ServerName = host
+		c.tlsConfig.ServerName = host
 	}
 }
 
 func (c *httpProtocol) makeRequest(ctx context.Context, req *request) (string, error) {
-	httpReq, err := http.NewRequest("GET", req.URL, nil)
+	method, body := "GET", io.Reader(nil)
+	if len(req.Body) > 0 {
+		method, body = "POST", bytes.NewReader(req.Body)
+	} else if req.BodySize > 0 {
+		method, body = "POST", bytes.NewReader(bytes.Repeat([]byte("a"), int(req.BodySize)))
+	}
+	httpReq, err := http.NewRequest(method, req.URL, body)
 	if err != nil {
 		return "", err
 	}
@@ -68,30 +80,76 @@ func (c *httpProtocol) makeRequest(ctx context.Context, req *request) (string, e
 
 	c.setHost(httpReq, host)
 
+	// Record the time to the first byte of the response, which distinguishes streamed responses from
+	// those buffered (e.g. by Envoy) before being forwarded.
+	start := time.Now()
+	var ttfb time.Duration
+	httpReq = httpReq.WithContext(httptrace.WithClientTrace(httpReq.Context(), &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			ttfb = time.Since(start)
+		},
+	}))
+
 	httpResp, err := c.do(c.client, httpReq)
 	if err != nil {
 		return outBuffer.String(), err
 	}
 
 	outBuffer.WriteString(fmt.Sprintf("[%d] %s=%d\n", req.RequestID, response.StatusCodeField, httpResp.StatusCode))
+	outBuffer.WriteString(fmt.Sprintf("[%d] %s=%s\n", req.RequestID, response.TimeToFirstByteField, ttfb))
+	outBuffer.WriteString(fmt.Sprintf("[%d] %s=%s\n", req.RequestID, response.ResponseProtocolField, httpResp.Proto))
+	if httpResp.TLS != nil && httpResp.TLS.NegotiatedProtocol != "" {
+		outBuffer.WriteString(fmt.Sprintf("[%d] %s=%s\n", req.RequestID, response.ALPNField, httpResp.TLS.NegotiatedProtocol))
+	}
 
 	for key, values := range httpResp.Header {
 		for _, value := range values {
-			outBuffer.WriteString(fmt.Sprintf("[%d] ResponseHeader=%s:%s\n", req.RequestID, key, value))
+			outBuffer.WriteString(fmt.Sprintf("[%d] %s=%s:%s\n", req.RequestID, response.ResponseHeaderField, key, value))
 		}
 	}
 
-	data, err := ioutil.ReadAll(httpResp.Body)
 	defer func() {
 		if err = httpResp.Body.Close(); err != nil {
 			outBuffer.WriteString(fmt.Sprintf("[%d error] %s\n", req.RequestID, err))
 		}
 	}()
 
-	if err != nil {
+	var data []byte
+	incomplete := false
+	if req.ReadBytesPerSecond > 0 {
+		// Simulate a slow consumer. Failing to read the full body is an expected outcome, so report
+		// it in the output, along with the part of the body that was received, rather than failing
+		// the request. A chunked body (ContentLength -1) has no expected size, so it is only
+		// incomplete if reading it failed (e.g. the stream was reset or the request timed out).
+		data, err = readThrottled(ctx, httpResp.Body, req.ReadBytesPerSecond)
+		if err != nil || (httpResp.ContentLength >= 0 && int64(len(data)) != httpResp.ContentLength) {
+			incomplete = true
+			outBuffer.WriteString(fmt.Sprintf("[%d] %s=%d\n", req.RequestID, response.IncompleteBodyField, len(data)))
+			if err != nil {
+				outBuffer.WriteString(fmt.Sprintf("[%d error] %s\n", req.RequestID, err))
+			}
+		}
+	} else if data, err = ioutil.ReadAll(httpResp.Body); err != nil {
 		return outBuffer.String(), err
 	}
 
+	// The transport does not decompress responses (see newHTTPTransport), so we decode here, recording the encoding and sizes so that compression can be verified. A partial
+	// body can't be decoded.
+	if encoding := httpResp.Header.Get("Content-Encoding"); encoding != "" && !incomplete {
+		outBuffer.WriteString(fmt.Sprintf("[%d] %s=%s\n", req.RequestID, response.ContentEncodingField, encoding))
+
+		decoded, err := decodeBody(encoding, data)
+		if err != nil {
+			// Without the sizes, the response isn't reported as compressed. Don't write the
+			// undecoded body either, since it can't be parsed.
+			outBuffer.WriteString(fmt.Sprintf("[%d error] %s\n", req.RequestID, err))
+			return outBuffer.String(), nil
+		}
+		outBuffer.WriteString(fmt.Sprintf("[%d] %s=%d\n", req.RequestID, response.EncodedBodySizeField, len(data)))
+		outBuffer.WriteString(fmt.Sprintf("[%d] %s=%d\n", req.RequestID, response.DecodedBodySizeField, len(decoded)))
+		data = decoded
+	}
+
 	for _, line := range strings.Split(string(data), "\n") {
 		if line != "" {
 			outBuffer.WriteString(fmt.Sprintf("[%d body] %s\n", req.RequestID, line))
@@ -101,6 +159,64 @@ func (c *httpProtocol) makeRequest(ctx context.Context, req *request) (string, e
 	return outBuffer.String(), nil
 }
 
+// readThrottled reads the body at (approximately) the given rate, in bytes/second.
+func readThrottled(ctx context.Context, body io.Reader, bytesPerSecond int64) ([]byte, error) {
+	const interval = 100 * time.Millisecond
+	chunkSize := bytesPerSecond * int64(interval) / int64(time.Second)
+	if chunkSize < 1 {
+		chunkSize = 1
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var out bytes.Buffer
+	for {
+		n, err := io.CopyN(&out, body, chunkSize)
+		if err == io.EOF {
+			return out.Bytes(), nil
+		}
+		if err != nil {
+			return out.Bytes(), err
+		}
+		if n < chunkSize {
+			return out.Bytes(), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return out.Bytes(), ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// decodeBody decodes the body of a response with the given Content-Encoding. An error is returned
+// for unsupported encodings (e.g. "br"), as well as for bodies that fail to decode.
+func decodeBody(encoding string, data []byte) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case "identity":
+		return data, nil
+	case "gzip":
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = r.Close() }()
+		return ioutil.ReadAll(r)
+	case "deflate":
+		// The "deflate" content coding is the zlib format (RFC 7230), not a raw deflate stream.
+		r, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = r.Close() }()
+		return ioutil.ReadAll(r)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q of the response body", encoding)
+	}
+}
+
 func (c *httpProtocol) Close() error {
 	return nil
 }
@@ -0,0 +1,337 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forwarder
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/echo/common"
+	"istio.io/istio/pkg/test/echo/common/response"
+)
+
+var testBody = []byte(strings.Repeat("hello echo\n", 100))
+
+func encode(t *testing.T, newWriter func(io.Writer) io.WriteCloser) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w := newWriter(&out)
+	if _, err := w.Write(testBody); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	gzipped := encode(t, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })
+	zlibbed := encode(t, func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) })
+	rawDeflate := encode(t, func(w io.Writer) io.WriteCloser {
+		// Only fails for an invalid level.
+		fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return fw
+	})
+
+	cases := []struct {
+		encoding string
+		data     []byte
+		err      bool
+	}{
+		{encoding: "gzip", data: gzipped},
+		{encoding: "GZIP", data: gzipped},
+		{encoding: "deflate", data: zlibbed},
+		{encoding: "identity", data: testBody},
+		// The "deflate" content coding has a zlib header.
+		{encoding: "deflate", data: rawDeflate, err: true},
+		{encoding: "gzip", data: testBody, err: true},
+		{encoding: "br", data: testBody, err: true},
+	}
+	for _, c := range cases {
+		t.Run(c.encoding, func(t *testing.T) {
+			decoded, err := decodeBody(c.encoding, c.data)
+			if c.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, testBody) {
+				t.Fatalf("unexpected decoded body: %q", decoded)
+			}
+		})
+	}
+}
+
+func TestMakeRequestContentEncoding(t *testing.T) {
+	gzipped := encode(t, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := r.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Encoding", encoding)
+		if encoding == "gzip" {
+			_, _ = w.Write(gzipped)
+			return
+		}
+		_, _ = w.Write(testBody)
+	}))
+	defer server.Close()
+
+	p := &httpProtocol{
+		client:    &http.Client{Transport: &http.Transport{DisableCompression: true}},
+		tlsConfig: &tls.Config{},
+		do:        common.DefaultHTTPDoFunc,
+	}
+	request := func(encoding string) string {
+		out, err := p.makeRequest(context.Background(), &request{
+			URL:     server.URL,
+			Header:  http.Header{"Accept-Encoding": []string{encoding}},
+			Timeout: 10 * time.Second,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	// The sizes on the wire and after decoding are reported, along with the decoded body.
+	out := request("gzip")
+	for _, expected := range []string{
+		fmt.Sprintf("%s=gzip", response.ContentEncodingField),
+		fmt.Sprintf("%s=%d", response.EncodedBodySizeField, len(gzipped)),
+		fmt.Sprintf("%s=%d", response.DecodedBodySizeField, len(testBody)),
+		"[0 body] hello echo",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected %q in output:\n%s", expected, out)
+		}
+	}
+
+	// The sizes aren't reported for a body that can't be decoded, so it isn't considered compressed.
+	out = request("br")
+	if !strings.Contains(out, fmt.Sprintf("%s=br", response.ContentEncodingField)) ||
+		!strings.Contains(out, `unsupported content encoding "br"`) {
+		t.Fatalf("expected unsupported encoding in output:\n%s", out)
+	}
+	for _, unexpected := range []response.Field{response.EncodedBodySizeField, response.DecodedBodySizeField} {
+		if strings.Contains(out, string(unexpected)) {
+			t.Fatalf("unexpected %s in output:\n%s", unexpected, out)
+		}
+	}
+}
+
+func TestMakeRequestWithoutAcceptEncoding(t *testing.T) {
+	gzipped := encode(t, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })
+	var received []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("Accept-Encoding"))
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			_, _ = w.Write(gzipped)
+			return
+		}
+		_, _ = w.Write(testBody)
+	})
+
+	for _, version := range []string{common.HTTPVersion11, common.HTTPVersion2} {
+		t.Run(version, func(t *testing.T) {
+			server := httptest.NewUnstartedServer(handler)
+			server.EnableHTTP2 = true
+			server.StartTLS()
+			defer server.Close()
+			received = nil
+
+			tlsConfig := &tls.Config{InsecureSkipVerify: true}
+			transport, err := newHTTPTransport(version, true, tlsConfig, nil, 10*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := &httpProtocol{
+				client:    &http.Client{Transport: transport},
+				tlsConfig: tlsConfig,
+				do:        common.DefaultHTTPDoFunc,
+			}
+			out, err := p.makeRequest(context.Background(), &request{
+				URL:     server.URL,
+				Header:  http.Header{},
+				Timeout: 10 * time.Second,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The transport doesn't request compression by itself, which it would then transparently
+			// decompress without it being reported.
+			if len(received) != 1 || received[0] != "" {
+				t.Fatalf("expected a request without Accept-Encoding, received %q", received)
+			}
+			if strings.Contains(out, string(response.ContentEncodingField)) || !strings.Contains(out, "[0 body] hello echo") {
+				t.Fatalf("expected an uncompressed body in output:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestMakeRequestIncompleteBody(t *testing.T) {
+	body := "ServiceVersion=v1\nHostname=a-v1-0\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			// Without a Content-Length, the body is chunked.
+			_, _ = w.Write([]byte(body))
+			w.(http.Flusher).Flush()
+			_, _ = w.Write([]byte(body))
+			return
+		}
+		// The connection is closed before the announced body is complete.
+		w.Header().Set("Content-Length", strconv.Itoa(2*len(body)))
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	p := &httpProtocol{
+		client:    &http.Client{},
+		tlsConfig: &tls.Config{},
+		do:        common.DefaultHTTPDoFunc,
+	}
+	request := func(path string) string {
+		out, err := p.makeRequest(context.Background(), &request{
+			URL:                server.URL + path,
+			Header:             http.Header{},
+			Timeout:            10 * time.Second,
+			ReadBytesPerSecond: 1 << 20,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	// The part of the body that was received is reported along with the incomplete body.
+	out := request("/")
+	for _, expected := range []string{
+		fmt.Sprintf("%s=%d", response.IncompleteBodyField, len(body)),
+		"[0 body] ServiceVersion=v1",
+		"[0 body] Hostname=a-v1-0",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected %q in output:\n%s", expected, out)
+		}
+	}
+
+	// A chunked body has no expected size, so it's complete once fully read.
+	out = request("/chunked")
+	if strings.Contains(out, string(response.IncompleteBodyField)) || strings.Count(out, "[0 body] Hostname=a-v1-0") != 2 {
+		t.Fatalf("expected complete chunked body in output:\n%s", out)
+	}
+}
+
+func TestHTTP10TransportBody(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	// Record the raw request, which is then answered with an empty HTTP/1.0 response.
+	requests := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			requests <- err.Error()
+			return
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		requests <- fmt.Sprintf("%s %d %v %s", req.Proto, req.ContentLength, req.TransferEncoding, body)
+		_, _ = conn.Write([]byte("HTTP/1.0 200 OK\r\nContent-Length: 0\r\n\r\n"))
+	}()
+
+	transport, err := newHTTPTransport(common.HTTPVersion10, false, &tls.Config{}, nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The length of the body is unknown, so it would be sent chunked by Request.Write.
+	req, err := http.NewRequest("POST", "http://"+listener.Addr().String(), ioutil.NopCloser(strings.NewReader("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	if received := <-requests; received != "HTTP/1.0 5 [] hello" {
+		t.Fatalf("expected an HTTP/1.0 request with a Content-Length of 5, received %q", received)
+	}
+}
+
+func TestHTTP2TransportDialTimeout(t *testing.T) {
+	// The dial only returns once it's canceled.
+	dialContext := func(ctx context.Context, _, _ string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	for _, secure := range []bool{false, true} {
+		transport, err := newHTTPTransport(common.HTTPVersion2, secure, &tls.Config{}, dialContext, 50*time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scheme := "http"
+		if secure {
+			scheme = "https"
+		}
+		req, err := http.NewRequest("GET", scheme+"://127.0.0.1:1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := transport.RoundTrip(req)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+				t.Fatalf("expected the dial to time out, got %v", err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("dial of %s connection was not bounded by the timeout", scheme)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forwarder
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"istio.io/istio/pkg/test/echo/common"
+)
+
+type dialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// newHTTPTransport creates a transport for HTTP requests that uses the given HTTP version. If set,
+// dialContext is used for creating all connections. The secure flag indicates whether the requests
+// use TLS (i.e. https). Dialing and the TLS handshake of HTTP/2 connections are bounded by the
+// timeout of the requests. The transports never add an Accept-Encoding header, nor transparently
+// decompress the responses, so that only the compression requested explicitly is used and reported.
+func newHTTPTransport(version string, secure bool, tlsConfig *tls.Config, dialContext dialContextFunc,
+	timeout time.Duration) (http.RoundTripper, error) {
+	if dialContext == nil {
+		dialContext = (&net.Dialer{}).DialContext
+	}
+
+	switch version {
+	case common.HTTPVersion10:
+		return &http10Transport{
+			tlsConfig:   tlsConfig,
+			dialContext: dialContext,
+		}, nil
+	case common.HTTPVersion11:
+		return &http.Transport{
+			TLSClientConfig:    tlsConfig,
+			DialContext:        dialContext,
+			DisableCompression: true,
+		}, nil
+	case common.HTTPVersion2:
+		return &http2.Transport{
+			TLSClientConfig: tlsConfig,
+			// Allow HTTP/2 with prior knowledge (h2c) for plaintext requests.
+			AllowHTTP:          true,
+			DisableCompression: true,
+			// The request context isn't passed to DialTLS (this version of x/net has no
+			// DialTLSContext), so the connection can't be canceled with it. Bound it by the
+			// request timeout instead.
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				defer cancel()
+				conn, err := dialContext(ctx, network, addr)
+				if err != nil {
+					return nil, err
+				}
+				if !secure {
+					return conn, nil
+				}
+				deadline, _ := ctx.Deadline()
+				_ = conn.SetDeadline(deadline)
+				if conn, err = dialTLS(conn, cfg); err != nil {
+					return nil, err
+				}
+				// The deadline only applies to the handshake, not to the requests on the connection.
+				_ = conn.SetDeadline(time.Time{})
+				return conn, nil
+			},
+		}, nil
+	case common.HTTPVersion3:
+		return nil, errors.New("HTTP/3 (QUIC) is not supported by the echo client")
+	default:
+		return nil, fmt.Errorf("unsupported HTTP version %q", version)
+	}
+}
+
+func dialTLS(conn net.Conn, cfg *tls.Config) (net.Conn, error) {
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.Handshake(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+var _ http.RoundTripper = &http10Transport{}
+
+// http10Transport is an http.RoundTripper that sends HTTP/1.0 requests. Since HTTP/1.0 has no
+// persistent connections, a new connection is used for every request and closed with the response.
+// HTTP/1.0 has no chunked transfer encoding either, so bodies of unknown length are buffered to
+// send them with a Content-Length.
+type http10Transport struct {
+	tlsConfig   *tls.Config
+	dialContext dialContextFunc
+}
+
+func (t *http10Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	host := req.URL.Host
+	if req.URL.Port() == "" {
+		port := "80"
+		if req.URL.Scheme == "https" {
+			port = "443"
+		}
+		host = net.JoinHostPort(req.URL.Hostname(), port)
+	}
+
+	conn, err := t.dialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if req.URL.Scheme == "https" {
+		if conn, err = dialTLS(conn, t.tlsConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	if req, err = withContentLength(req); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	// Request.Write always uses HTTP/1.1 in the request line, so rewrite it.
+	var buf bytes.Buffer
+	if err := req.Write(&buf); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	raw := buf.Bytes()
+	lineEnd := bytes.Index(raw, []byte("\r\n"))
+	requestLine := bytes.TrimSuffix(raw[:lineEnd], []byte("HTTP/1.1"))
+	if _, err := fmt.Fprintf(conn, "%sHTTP/1.0%s", requestLine, raw[lineEnd:]); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	resp.Body = &connClosingBody{ReadCloser: resp.Body, conn: conn}
+	return resp, nil
+}
+
+// withContentLength returns the request, or a copy of it with the body buffered if its length is
+// unknown, which Request.Write would otherwise send chunked.
+func withContentLength(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody || (req.ContentLength > 0 && len(req.TransferEncoding) == 0) {
+		return req, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed reading the request body: %v", err)
+	}
+
+	out := new(http.Request)
+	*out = *req
+	out.TransferEncoding = nil
+	out.ContentLength = int64(len(body))
+	out.Body = http.NoBody
+	if len(body) > 0 {
+		out.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return out, nil
+}
+
+// connClosingBody closes the underlying connection when the response body is closed.
+type connClosingBody struct {
+	io.ReadCloser
+	conn net.Conn
+}
+
+func (b *connClosingBody) Close() error {
+	err := b.ReadCloser.Close()
+	if cerr := b.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
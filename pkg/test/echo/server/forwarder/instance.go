@@ -16,14 +16,18 @@ package forwarder
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"net/http"
 	"time"
 
 	"github.com/golang/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/test/echo/common"
+	"istio.io/istio/pkg/test/echo/common/response"
 	"istio.io/istio/pkg/test/echo/proto"
 )
 
@@ -45,13 +49,20 @@ func (c Config) fillInDefaults() Config {
 
 // Instance processes a single proto.ForwardEchoRequest, sending individual echo requests to the destination URL.
 type Instance struct {
-	p       protocol
-	url     string
-	timeout time.Duration
-	count   int
-	qps     int
-	header  http.Header
-	message string
+	p        protocol
+	url      string
+	timeout  time.Duration
+	count    int
+	qps      int
+	header   http.Header
+	message  string
+	readBPS  int64
+	bodySize int64
+	body     []byte
+	stream   bool
+
+	// concurrency, if > 0, limits the number of requests in flight.
+	concurrency int
 }
 
 // New creates a new forwarder Instance.
@@ -71,6 +82,13 @@ func New(cfg Config) (*Instance, error) {
 		qps:     int(cfg.Request.Qps),
 		header:  common.GetHeaders(cfg.Request),
 		message: cfg.Request.Message,
+		readBPS: cfg.Request.ReadBytesPerSecond,
+
+		bodySize: cfg.Request.BodySize,
+		body:     cfg.Request.Body,
+		stream:   cfg.Request.Stream,
+
+		concurrency: int(cfg.Request.Concurrency),
 	}, nil
 }
 
@@ -87,6 +105,15 @@ func (i *Instance) Run(ctx context.Context) (*proto.ForwardEchoResponse, error)
 		throttle = time.NewTicker(sleepTime)
 	}
 
+	if i.stream {
+		return i.runStream(ctx, throttle)
+	}
+
+	var inFlight chan struct{}
+	if i.concurrency > 0 {
+		inFlight = make(chan struct{}, i.concurrency)
+	}
+
 	for reqIndex := 0; reqIndex < i.count; reqIndex++ {
 		r := request{
 			RequestID: reqIndex,
@@ -94,19 +121,30 @@ func (i *Instance) Run(ctx context.Context) (*proto.ForwardEchoResponse, error)
 			Message:   i.message,
 			Header:    i.header,
 			Timeout:   i.timeout,
+
+			ReadBytesPerSecond: i.readBPS,
+			BodySize:           i.bodySize,
+			Body:               i.body,
 		}
 
 		if throttle != nil {
 			<-throttle.C
 		}
 
-		// TODO(nmittler): Refactor this to limit the number of go routines.
+		if inFlight != nil {
+			inFlight <- struct{}{}
+		}
+
 		g.Go(func() error {
+			if inFlight != nil {
+				defer func() { <-inFlight }()
+			}
+			start := time.Now()
 			resp, err := i.p.makeRequest(ctx, &r)
 			if err != nil {
 				return err
 			}
-			responses[r.RequestID] = resp
+			responses[r.RequestID] = resp + fmt.Sprintf("[%d] %s=%s\n", r.RequestID, response.LatencyField, time.Since(start))
 			return nil
 		})
 	}
@@ -120,6 +158,34 @@ func (i *Instance) Run(ctx context.Context) (*proto.ForwardEchoResponse, error)
 	}, nil
 }
 
+// runStream sends the requests as the messages of a single gRPC stream.
+func (i *Instance) runStream(ctx context.Context, throttle *time.Ticker) (*proto.ForwardEchoResponse, error) {
+	p, ok := i.p.(*grpcProtocol)
+	if !ok {
+		return nil, fmt.Errorf("streaming is only supported for gRPC, not %s", i.url)
+	}
+
+	reqs := make([]*request, 0, i.count)
+	for reqIndex := 0; reqIndex < i.count; reqIndex++ {
+		reqs = append(reqs, &request{
+			RequestID: reqIndex,
+			URL:       i.url,
+			Message:   i.message,
+			Header:    i.header,
+			Timeout:   i.timeout,
+		})
+	}
+
+	responses, err := p.makeStreamRequest(ctx, reqs, throttle)
+	if err != nil {
+		// Distinguish failures of the stream from those of the forwarder itself.
+		return nil, status.Errorf(codes.Aborted, "stream failed: %v", err)
+	}
+	return &proto.ForwardEchoResponse{
+		Output: responses,
+	}, nil
+}
+
 func (i *Instance) Close() error {
 	return i.p.Close()
 }
@@ -42,6 +42,12 @@ type request struct {
 	RequestID int
 	Message   string
 	Timeout   time.Duration
+	// ReadBytesPerSecond if > 0, limits the rate at which the response body is read.
+	ReadBytesPerSecond int64
+	// BodySize if > 0, is the size of the body sent with (HTTP) requests.
+	BodySize int64
+	// Body if set, is sent with (HTTP) requests instead of one of BodySize bytes.
+	Body []byte
 }
 
 type protocol interface {
@@ -50,7 +56,7 @@ type protocol interface {
 }
 
 func newProtocol(cfg Config) (protocol, error) {
-	var httpDialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+	var httpDialContext dialContextFunc
 	var wsDialContext func(network, addr string) (net.Conn, error)
 	if len(cfg.UDS) > 0 {
 		httpDialContext = func(_ context.Context, _, _ string) (net.Conn, error) {
@@ -73,17 +79,21 @@ func newProtocol(cfg Config) (protocol, error) {
 
 	switch scheme.Instance(u.Scheme) {
 	case scheme.HTTP, scheme.HTTPS:
+		tlsConfig := &tls.Config{
+			InsecureSkipVerify: true,
+		}
+		transport, err := newHTTPTransport(common.GetHTTPVersion(cfg.Request),
+			scheme.Instance(u.Scheme) == scheme.HTTPS, tlsConfig, httpDialContext, timeout)
+		if err != nil {
+			return nil, err
+		}
 		return &httpProtocol{
 			client: &http.Client{
-				Transport: &http.Transport{
-					TLSClientConfig: &tls.Config{
-						InsecureSkipVerify: true,
-					},
-					DialContext: httpDialContext,
-				},
-				Timeout: timeout,
+				Transport: transport,
+				Timeout:   timeout,
 			},
-			do: cfg.Dialer.HTTP,
+			tlsConfig: tlsConfig,
+			do:        cfg.Dialer.HTTP,
 		}, nil
 	case scheme.GRPC, scheme.GRPCS:
 		// grpc-go sets incorrect authority header
@@ -24,6 +24,7 @@ import (
 	"github.com/gorilla/websocket"
 
 	"istio.io/istio/pkg/test/echo/common"
+	"istio.io/istio/pkg/test/echo/common/response"
 )
 
 var _ protocol = &websocketProtocol{}
@@ -46,11 +47,17 @@ func (c *websocketProtocol) makeRequest(ctx context.Context, req *request) (stri
 		outBuffer.WriteString(fmt.Sprintf("[%d] Echo=%s\n", req.RequestID, req.Message))
 	}
 
-	conn, _, err := c.dialer.Dial(req.URL, wsReq)
+	conn, resp, err := c.dialer.Dial(req.URL, wsReq)
+	if err == websocket.ErrBadHandshake && resp != nil {
+		// The upgrade was rejected (e.g. by policy). Report the status, so it can be verified.
+		outBuffer.WriteString(fmt.Sprintf("[%d] %s=%d\n", req.RequestID, response.StatusCodeField, resp.StatusCode))
+		return outBuffer.String(), nil
+	}
 	if err != nil {
-		// timeout or bad handshake
+		// timeout
 		return outBuffer.String(), err
 	}
+	outBuffer.WriteString(fmt.Sprintf("[%d] %s=%d\n", req.RequestID, response.WebSocketUpgradeField, resp.StatusCode))
 	defer func() {
 		_ = conn.Close()
 	}()
@@ -73,12 +80,12 @@ func (c *websocketProtocol) makeRequest(ctx context.Context, req *request) (stri
 		return outBuffer.String(), err
 	}
 
-	_, resp, err := conn.ReadMessage()
+	_, message, err := conn.ReadMessage()
 	if err != nil {
 		return outBuffer.String(), err
 	}
 
-	for _, line := range strings.Split(string(resp), "\n") {
+	for _, line := range strings.Split(string(message), "\n") {
 		if line != "" {
 			outBuffer.WriteString(fmt.Sprintf("[%d body] %s\n", req.RequestID, line))
 		}
@@ -19,6 +19,7 @@ import (
 	"io"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 
@@ -36,6 +37,16 @@ type Config struct {
 	Version   string
 	UDSServer string
 	Dialer    common.Dialer
+
+	// Cluster of the server, reported in the responses (if not empty).
+	Cluster string
+
+	// StartupDelay before the endpoints start listening, to simulate a slow starting application.
+	StartupDelay time.Duration
+
+	// PathStatuses are the status codes returned by HTTP endpoints for requests to the given paths,
+	// unless overridden by the "codes" query parameter of the request.
+	PathStatuses map[string]int
 }
 
 var _ io.Closer = &Instance{}
@@ -45,6 +56,7 @@ type Instance struct {
 	Config
 
 	endpoints []endpoint.Instance
+	counter   *endpoint.RequestCounter
 	ready     uint32
 }
 
@@ -53,7 +65,8 @@ func New(config Config) *Instance {
 	config.Dialer = config.Dialer.FillInDefaults()
 
 	return &Instance{
-		Config: config,
+		Config:  config,
+		counter: endpoint.NewRequestCounter(),
 	}
 }
 
@@ -69,6 +82,11 @@ func (s *Instance) Start() (err error) {
 		return err
 	}
 
+	if s.StartupDelay > 0 {
+		log.Infof("Delaying startup by %v", s.StartupDelay)
+		time.Sleep(s.StartupDelay)
+	}
+
 	s.endpoints = make([]endpoint.Instance, 0)
 	for _, p := range s.Ports {
 		ep, err := s.newEndpoint(p, "")
@@ -105,9 +123,12 @@ func (s *Instance) newEndpoint(port *model.Port, udsServer string) (endpoint.Ins
 		UDSServer:     udsServer,
 		IsServerReady: s.isReady,
 		Version:       s.Version,
+		Cluster:       s.Cluster,
 		TLSCert:       s.TLSCert,
 		TLSKey:        s.TLSKey,
 		Dialer:        s.Dialer,
+		Counter:       s.counter,
+		PathStatuses:  s.PathStatuses,
 	})
 }
 
@@ -16,6 +16,7 @@ package envoy
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -89,6 +90,51 @@ func GetConfigDump(adminPort int) (*envoyAdmin.ConfigDump, error) {
 	return msg, nil
 }
 
+// GetClusters polls Envoy admin port for the status of the clusters, including their hosts, and returns the response.
+func GetClusters(adminPort int) (*envoyAdmin.Clusters, error) {
+	buffer, err := doEnvoyGet("clusters?format=json", adminPort)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &envoyAdmin.Clusters{}
+	if err := jsonpb.Unmarshal(buffer, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// GetStats polls Envoy admin port for the counter and gauge stats and returns them by name.
+func GetStats(adminPort int) (map[string]int64, error) {
+	buffer, err := doEnvoyGet("stats?format=json", adminPort)
+	if err != nil {
+		return nil, err
+	}
+	return ParseStats(buffer)
+}
+
+// ParseStats parses the JSON output of Envoy's /stats?format=json admin endpoint, returning the
+// counter and gauge values by name. Histograms are ignored.
+func ParseStats(r io.Reader) (map[string]int64, error) {
+	var parsed struct {
+		Stats []struct {
+			Name  string `json:"name"`
+			Value *int64 `json:"value"`
+		} `json:"stats"`
+	}
+	if err := json.NewDecoder(r).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed parsing Envoy stats: %v", err)
+	}
+
+	out := make(map[string]int64, len(parsed.Stats))
+	for _, stat := range parsed.Stats {
+		if stat.Value != nil {
+			out[stat.Name] = *stat.Value
+		}
+	}
+	return out, nil
+}
+
 func doEnvoyGet(path string, adminPort int) (*bytes.Buffer, error) {
 	requestURL := fmt.Sprintf("http://127.0.0.1:%d/%s", adminPort, path)
 	buffer, err := doHTTPGet(requestURL)
@@ -0,0 +1,107 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const (
+	defaultAccessLogTimeout = 30 * time.Second
+	defaultAccessLogDelay   = time.Second
+)
+
+// AccessLogSource returns the logs of a workload in which access log lines are searched.
+type AccessLogSource func(w Workload) (string, error)
+
+// SidecarAccessLogs is the default AccessLogSource, returning the logs of the workload's sidecar.
+func SidecarAccessLogs(w Workload) (string, error) {
+	if w.Sidecar() == nil {
+		return "", errors.New("workload has no sidecar")
+	}
+	return w.Sidecar().Logs()
+}
+
+// AccessLogOptions for WaitForAccessLog.
+type AccessLogOptions struct {
+	// Source of the logs. Defaults to SidecarAccessLogs.
+	Source AccessLogSource
+
+	// Timeout for the log line to appear. Defaults to 30s.
+	Timeout time.Duration
+
+	// Delay between polls of the logs. Defaults to 1s.
+	Delay time.Duration
+}
+
+// WaitForAccessLog polls the logs of the target's workloads until a line containing the request ID
+// (e.g. ParsedResponse.ID, as reported for a call) and accepted by the matcher appears, and returns
+// that line. A nil matcher accepts any line with the request ID. An error is returned if no such
+// line appears within the timeout.
+func WaitForAccessLog(target Instance, requestID string, matcher func(line string) bool, opts AccessLogOptions) (string, error) {
+	if requestID == "" {
+		return "", errors.New("waitForAccessLog: missing request ID")
+	}
+	if opts.Source == nil {
+		opts.Source = SidecarAccessLogs
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultAccessLogTimeout
+	}
+	if opts.Delay <= 0 {
+		opts.Delay = defaultAccessLogDelay
+	}
+
+	workloads, err := target.Workloads()
+	if err != nil {
+		return "", err
+	}
+
+	var found string
+	err = retry.UntilSuccess(func() error {
+		for _, w := range workloads {
+			logs, err := opts.Source(w)
+			if err != nil {
+				return err
+			}
+			for _, line := range strings.Split(logs, "\n") {
+				if strings.Contains(line, requestID) && (matcher == nil || matcher(line)) {
+					found = line
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("no matching access log line found for request %s", requestID)
+	}, retry.Timeout(opts.Timeout), retry.Delay(opts.Delay))
+	if err != nil {
+		return "", fmt.Errorf("waiting for access log of %s: %v", target.Config().Service, err)
+	}
+	return found, nil
+}
+
+// WaitForAccessLogOrFail calls WaitForAccessLog and fails t if an error occurs.
+func WaitForAccessLogOrFail(t testing.TB, target Instance, requestID string, matcher func(line string) bool, opts AccessLogOptions) string {
+	line, err := WaitForAccessLog(target, requestID, matcher, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return line
+}
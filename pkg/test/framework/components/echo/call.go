@@ -16,40 +16,248 @@ package echo
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
+	"istio.io/istio/pkg/test/echo/client"
 	"istio.io/istio/pkg/test/echo/common/scheme"
 )
 
+// WorkloadSelector selects the workload of the source Instance that makes a call.
+type WorkloadSelector string
+
+const (
+	// WorkloadSelectorRoundRobin rotates through the workloads with each call. This is the default.
+	WorkloadSelectorRoundRobin WorkloadSelector = "RoundRobin"
+
+	// WorkloadSelectorRandom selects a random workload for each call.
+	WorkloadSelectorRandom WorkloadSelector = "Random"
+
+	// WorkloadSelectorPinned always selects the workload with the SourceWorkloadIndex.
+	WorkloadSelectorPinned WorkloadSelector = "Pinned"
+)
+
 // CallOptions defines options for calling a Endpoint.
 type CallOptions struct {
 	// Target instance of the call. Required.
 	Target Instance
 
-	// Port on the target Instance. Either Port or PortName must be specified.
+	// SourceWorkload selects the workload of the source Instance that makes the call (k8s only). If
+	// not provided, WorkloadSelectorRoundRobin is used. If the selected workload is no longer
+	// available (e.g. the pod was deleted), the call is made by the next available workload, unless
+	// pinned. The workload that made the call is reported via ParsedResponse.Source.
+	SourceWorkload WorkloadSelector
+
+	// SourceWorkloadIndex in Workloads() of the source workload, for WorkloadSelectorPinned.
+	SourceWorkloadIndex int
+
+	// TargetWorkload, if set, is the workload of the Target to which the call is sent directly, via
+	// its address and the InstancePort of the Port, rather than via the service (e.g. the ClusterIP).
+	// Used to test traffic between specific pods, such as the mTLS mode of a single pod. Must not be
+	// combined with Host.
+	TargetWorkload Workload
+
+	// Port on the target Instance. Either Port or PortName must be specified. A copy of a port
+	// without its MTLSMode may be given, in which case the outcome of the call isn't checked against
+	// the MTLSMode of the port.
 	Port *Port
 
 	// PortName of the port on the target Instance. Either Port or PortName must be specified.
 	PortName string
 
 	// Scheme to be used when making the call. If not provided, an appropriate default for the
-	// port will be used (if feasible).
+	// port will be used (if feasible). Use scheme.WebSocket (or scheme.WebSocketS) to upgrade the
+	// request on an HTTP (or HTTPS) port to a WebSocket connection, over which Message is sent in a
+	// frame and echoed by the server. Verify the exchange via ParsedResponses.CheckWebSocketEcho.
 	Scheme scheme.Instance
 
 	// Host specifies the host to be used on the request. If not provided, an appropriate
 	// default is chosen for the target Instance.
 	Host string
 
+	// Authority of the request, independent of the Host used to reach the target. This is sent as
+	// the :authority for HTTP/2 and gRPC requests, and as the Host header for HTTP/1 requests (which
+	// have no separate authority). Overrides any Host header in Headers. If not provided, the target
+	// service name is used. The authority received by the server is reported via
+	// ParsedResponse.Authority.
+	Authority string
+
 	// Path specifies the URL path for the request.
 	Path string
 
+	// QueryParams are added to the query of the request URL (after any query included in Path).
+	// Keys and values are encoded as necessary. The query received by the server is reported via
+	// ParsedResponse.Query.
+	QueryParams map[string]string
+
 	// Count indicates the number of exchanges that should be made with the service endpoint.
 	// If Count <= 0, defaults to 1.
 	Count int
 
-	// Headers indicates headers that should be sent in the request. Ignored for WebSocket calls.
+	// Concurrency, if > 0, limits the number of the Count requests that are in flight at a time, e.g.
+	// to send 100 requests 10 at a time. By default, all requests are made concurrently. The results
+	// of all requests are returned, which can be checked in aggregate via ParsedResponses.CheckOK and
+	// ParsedResponses.Distribution.
+	Concurrency int
+
+	// QPS, if > 0, limits the rate at which the Count requests are started. By default, all requests
+	// are made concurrently. Limiting the rate allows requests to be made sequentially, e.g. to verify
+	// connection reuse via ParsedResponses.CheckConnectionReused.
+	QPS int
+
+	// Headers indicates headers that should be sent in the request. For WebSocket calls, the headers
+	// are sent with the upgrade request. The headers received by the server are reported via
+	// ParsedResponse.RequestHeaders, e.g. to verify header-based routing or header rewrites.
 	Headers http.Header
 
+	// Message sent in the frame of WebSocket calls. If not provided, an empty message is sent.
+	Message string
+
+	// JWT, if set, is sent as a bearer token in the Authorization header of the request. Tokens can be
+	// generated with a JWTIssuer. The claims received by the server are reported via
+	// ParsedResponse.JWTClaims. Must not be combined with an Authorization header in Headers.
+	JWT string
+
 	// Timeout used for each individual request. Must be > 0, otherwise 30 seconds is used.
 	Timeout time.Duration
+
+	// AcceptEncoding, if set, is sent as the Accept-Encoding header of HTTP requests (e.g. "gzip").
+	// Encoded responses are decoded by the client, with the encoding and body sizes reported in
+	// the responses.
+	AcceptEncoding string
+
+	// ResponseChunks, if > 0, is the number of additional chunks (of 1KiB each) streamed by the target
+	// in the HTTP response body, waiting ResponseChunkDelay before each chunk. Use with
+	// ParsedResponses.CheckStreaming or CheckBuffered to verify whether the response was buffered.
+	ResponseChunks int
+
+	// ResponseChunkDelay is the delay before each of the ResponseChunks.
+	ResponseChunkDelay time.Duration
+
+	// ReadBytesPerSecond, if > 0, limits the rate at which the client reads HTTP response bodies, in
+	// order to simulate a slow consumer. Responses that were not fully received are reported via
+	// ParsedResponse.IncompleteBody. By default, responses are read at full speed.
+	ReadBytesPerSecond int64
+
+	// HTTPVersion used for HTTP requests: "1.0", "1.1" or "2". HTTP/1.0 requests do not use
+	// persistent connections. HTTP/3 ("3") is not supported by the echo client and the proxy, so
+	// such calls fail with an error. The protocol of the received responses is reported via
+	// ParsedResponse.Protocol, and the protocol negotiated via TLS ALPN (e.g. "h2") via
+	// ParsedResponse.ALPN. If not provided, HTTP/1.1 is used.
+	HTTPVersion string
+
+	// PaddingHeaders, if > 0, is the number of extra headers (X-Echo-Padding-<n>) added to HTTP
+	// requests, each with a value of PaddingHeaderSize bytes. Used to exceed the request header
+	// limits of a proxy, which is verified via ParsedResponses.CheckHeadersTooLarge. The headers are
+	// not limited by the client.
+	PaddingHeaders    int
+	PaddingHeaderSize int
+
+	// BodySize, if > 0, causes HTTP requests to be sent as POST with a body of this many bytes. Used
+	// to exceed the request body limits of a proxy, which is verified via
+	// ParsedResponses.CheckRequestTooLarge.
+	BodySize int
+
+	// Body, if set, causes HTTP requests to be sent as POST with this body, which is echoed verbatim
+	// by the server (see ParsedResponses.CheckRequestBody), e.g. to verify body transformations. The
+	// echoed bodies of all Count requests are returned together, so the Body times Count must not
+	// exceed 2MB. Must not be combined with BodySize.
+	Body []byte
+
+	// GRPCStream, if set, sends the Count requests as the messages of a single bidirectional gRPC
+	// stream, rather than as separate calls, so that all of them are served by the same workload
+	// (see ParsedResponses.CheckSameHostname). Requires the gRPC scheme. The Timeout applies to the
+	// whole stream. If the stream fails (e.g. it can't be opened, or ends before all responses were
+	// received), the call fails with a *StreamError. The result of each message is checked via its
+	// response, as for separate calls.
+	GRPCStream bool
+
+	// Retry policy for the call. By default, calls that fail or receive a non-2xx response are
+	// retried (see CallRetry), e.g. while the proxies are still being configured after a deployment.
+	// Calls that are expected to be rejected (e.g. plaintext calls to a STRICT port) aren't retried.
+	Retry CallRetry
+}
+
+// CallRetry is the policy for retrying a call. Each attempt re-selects the workload that makes the
+// call, and repeats all of the Count requests. The result of the last attempt is returned.
+type CallRetry struct {
+	// Disabled, if set, makes a single attempt, e.g. for calls that are expected to fail or whose
+	// requests are counted.
+	Disabled bool
+
+	// MaxAttempts of the call, including the first. If not provided, 3 attempts are made.
+	MaxAttempts int
+
+	// InitialBackoff before the second attempt, doubled for each subsequent attempt up to
+	// MaxBackoff. If not provided, 100ms and 1s are used.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// RetryIf decides whether the call is retried, given the result of an attempt. If not provided,
+	// DefaultRetryIf is used.
+	RetryIf func(responses client.ParsedResponses, err error) bool
+}
+
+// DefaultRetryIf retries calls that failed (e.g. with a transport error) or received a non-2xx
+// HTTP status code, other than the rejections that tests expect deterministically, e.g. by
+// CheckJWTRejected (401, 403), CheckRequestTooLarge (413), CheckRateLimited (429) or
+// CheckHeadersTooLarge (431). Retrying those would only repeat the rejection, or in the case of a
+// rate limit, consume more of the limit.
+func DefaultRetryIf(responses client.ParsedResponses, err error) bool {
+	if err != nil {
+		return true
+	}
+	for _, r := range responses {
+		code, err := strconv.Atoi(r.Code)
+		if err == nil && (code < 200 || code >= 300) && !expectedRejections[code] {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedRejections are the HTTP status codes that aren't retried by DefaultRetryIf.
+var expectedRejections = map[int]bool{
+	http.StatusUnauthorized:                true,
+	http.StatusForbidden:                   true,
+	http.StatusRequestEntityTooLarge:       true,
+	http.StatusTooManyRequests:             true,
+	http.StatusRequestHeaderFieldsTooLarge: true,
+}
+
+// StreamError is the error of a call with GRPCStream set, for failures of the stream as a whole.
+type StreamError struct {
+	Err error
+}
+
+// Error implements error
+func (e *StreamError) Error() string {
+	return e.Err.Error()
+}
+
+// CallResult of Instance.CallWithResult.
+type CallResult struct {
+	// Responses to the call.
+	Responses client.ParsedResponses
+
+	// Duration of the call, as measured by the framework (i.e. including the request to the source
+	// workload to make the call). Doesn't include any wait for readiness.
+	Duration time.Duration
+
+	// Source workload that made the call.
+	Source Workload
+}
+
+// targetPort returns the Port of the options, looking it up by PortName in the Target if necessary.
+// Returns nil if not found.
+func targetPort(opts CallOptions) *Port {
+	if opts.Port != nil || opts.Target == nil {
+		return opts.Port
+	}
+	for _, p := range opts.Target.Config().Ports {
+		if p.Name == opts.PortName {
+			return &p
+		}
+	}
+	return nil
 }
@@ -0,0 +1,38 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net"
+
+	"istio.io/istio/pkg/test/framework/components/echo"
+)
+
+// AddressOfFamily returns the address if it's an IP address of the family selected by the address
+// type (i.e. AddressTypeClusterIPv4 or AddressTypeClusterIPv6), or "" otherwise.
+func AddressOfFamily(address string, addressType echo.AddressType) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return ""
+	}
+	isIPv4 := ip.To4() != nil
+	switch {
+	case addressType == echo.AddressTypeClusterIPv4 && isIPv4,
+		addressType == echo.AddressTypeClusterIPv6 && !isIPv4:
+		return address
+	default:
+		return ""
+	}
+}
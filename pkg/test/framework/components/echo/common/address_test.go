@@ -0,0 +1,44 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common_test
+
+import (
+	"testing"
+
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/common"
+)
+
+func TestAddressOfFamily(t *testing.T) {
+	cases := []struct {
+		address string
+		v4      string
+		v6      string
+	}{
+		{address: "10.0.0.1", v4: "10.0.0.1"},
+		{address: "fd00::1", v6: "fd00::1"},
+		{address: "::ffff:10.0.0.1", v4: "::ffff:10.0.0.1"},
+		{address: ""},
+		{address: "None"},
+	}
+	for _, c := range cases {
+		if actual := common.AddressOfFamily(c.address, echo.AddressTypeClusterIPv4); actual != c.v4 {
+			t.Errorf("IPv4 address of %q: expected %q, got %q", c.address, c.v4, actual)
+		}
+		if actual := common.AddressOfFamily(c.address, echo.AddressTypeClusterIPv6); actual != c.v6 {
+			t.Errorf("IPv6 address of %q: expected %q, got %q", c.address, c.v6, actual)
+		}
+	}
+}
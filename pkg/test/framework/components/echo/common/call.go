@@ -23,6 +23,10 @@ import (
 	"net/url"
 	"reflect"
 	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/test/echo/client"
@@ -32,6 +36,18 @@ import (
 	"istio.io/istio/pkg/test/framework/components/echo"
 )
 
+const (
+	paddingHeaderPrefix = "X-Echo-Padding-"
+
+	// maxPaddingBytes is the maximum total size of the padding headers. The headers are sent to the
+	// echo app within the gRPC request, which is limited to 4MB.
+	maxPaddingBytes = 3 * 1024 * 1024
+
+	// maxEchoedBodyBytes is the maximum total size of the bodies echoed for all requests. The bodies
+	// are returned (base64 encoded) within the gRPC response of the echo app, which is limited to 4MB.
+	maxEchoedBodyBytes = 2 * 1024 * 1024
+)
+
 var (
 	// IdentityOutboundPortSelector is an OutboundPortSelectorFunc that always returns the original service port.
 	IdentityOutboundPortSelector OutboundPortSelectorFunc = func(servicePort int) (int, error) {
@@ -43,24 +59,53 @@ var (
 // requests to a target service.
 type OutboundPortSelectorFunc func(servicePort int) (int, error)
 
-func CallEcho(c *client.Instance, opts *echo.CallOptions, outboundPortSelector OutboundPortSelectorFunc) (client.ParsedResponses, error) {
-	if err := fillInCallOptions(opts); err != nil {
+func CallEcho(ctx context.Context, c *client.Instance, opts *echo.CallOptions,
+	outboundPortSelector OutboundPortSelectorFunc) (client.ParsedResponses, error) {
+	err := fillInCallOptions(opts)
+	if err != nil {
 		return nil, err
 	}
 
-	port, err := outboundPortSelector(opts.Port.ServicePort)
-	if err != nil {
-		return nil, err
+	port := opts.Port.InstancePort
+	if opts.TargetWorkload == nil {
+		if port, err = outboundPortSelector(opts.Port.ServicePort); err != nil {
+			return nil, err
+		}
 	}
 
-	// Forward a request from 'this' service to the destination service.
+	// Forward a request from 'this' service to the destination service. The path may carry a query.
+	path, query := opts.Path, ""
+	if i := strings.Index(path, "?"); i >= 0 {
+		path, query = path[:i], path[i+1:]
+	}
+	if len(opts.QueryParams) > 0 || opts.ResponseChunks > 0 || len(opts.Body) > 0 {
+		params := make(url.Values)
+		for k, v := range opts.QueryParams {
+			params.Set(k, v)
+		}
+		if opts.ResponseChunks > 0 {
+			// Handled by the echo server, see endpoint.parseChunks.
+			params.Set("chunks", fmt.Sprintf("%d:%s", opts.ResponseChunks, opts.ResponseChunkDelay))
+		}
+		if len(opts.Body) > 0 {
+			// Make the echo server return the body it received.
+			params.Set("echoBody", "true")
+		}
+		if query != "" {
+			query += "&"
+		}
+		query += params.Encode()
+	}
 	targetURL := &url.URL{
-		Scheme: string(opts.Scheme),
-		Host:   net.JoinHostPort(opts.Host, strconv.Itoa(port)),
-		Path:   opts.Path,
+		Scheme:   string(opts.Scheme),
+		Host:     net.JoinHostPort(opts.Host, strconv.Itoa(port)),
+		Path:     path,
+		RawQuery: query,
 	}
 	targetService := opts.Target.Config().Service
 
+	// The forwarder applies the Host header as the Host of HTTP/1 requests and the :authority of
+	// HTTP/2 and gRPC requests.
 	protoHeaders := []*proto.Header{
 		{
 			Key:   "Host",
@@ -70,18 +115,49 @@ func CallEcho(c *client.Instance, opts *echo.CallOptions, outboundPortSelector O
 	// Add headers in opts.Headers, e.g., authorization header, etc.
 	// If host header is set, it will override targetService.
 	for k := range opts.Headers {
+		if opts.Authority != "" && http.CanonicalHeaderKey(k) == "Host" {
+			continue
+		}
 		protoHeaders = append(protoHeaders, &proto.Header{Key: k, Value: opts.Headers.Get(k)})
 	}
+	if opts.Authority != "" {
+		protoHeaders[0].Value = opts.Authority
+	}
+	if opts.JWT != "" {
+		protoHeaders = append(protoHeaders, &proto.Header{Key: "Authorization", Value: "Bearer " + opts.JWT})
+	}
+	if opts.AcceptEncoding != "" {
+		protoHeaders = append(protoHeaders, &proto.Header{Key: "Accept-Encoding", Value: opts.AcceptEncoding})
+	}
+	if opts.PaddingHeaders > 0 {
+		padding := strings.Repeat("a", opts.PaddingHeaderSize)
+		for i := 0; i < opts.PaddingHeaders; i++ {
+			protoHeaders = append(protoHeaders, &proto.Header{Key: paddingHeaderPrefix + strconv.Itoa(i), Value: padding})
+		}
+	}
 
 	req := &proto.ForwardEchoRequest{
 		Url:           targetURL.String(),
 		Count:         int32(opts.Count),
+		Message:       opts.Message,
+		Qps:           int32(opts.QPS),
+		Concurrency:   int32(opts.Concurrency),
 		Headers:       protoHeaders,
 		TimeoutMicros: common.DurationToMicros(opts.Timeout),
+
+		ReadBytesPerSecond: opts.ReadBytesPerSecond,
+		HttpVersion:        opts.HTTPVersion,
+		BodySize:           int64(opts.BodySize),
+		Body:               opts.Body,
+		Stream:             opts.GRPCStream,
 	}
 
-	resp, err := c.ForwardEcho(context.Background(), req)
+	resp, err := c.ForwardEcho(ctx, req)
 	if err != nil {
+		if opts.GRPCStream && status.Code(err) == codes.Aborted {
+			// The forwarder reports failures of the stream as aborted.
+			return nil, &echo.StreamError{Err: err}
+		}
 		return nil, err
 	}
 
@@ -91,6 +167,46 @@ func CallEcho(c *client.Instance, opts *echo.CallOptions, outboundPortSelector O
 	return resp, err
 }
 
+// CallError wraps an error for a call from the source Instance with the given options, naming the
+// target service, port and path.
+func CallError(source echo.Instance, opts *echo.CallOptions, err error) error {
+	if opts.Target == nil {
+		// E.g. the missing Target error of the options, which can't be attributed to a target service.
+		return err
+	}
+	port := opts.Port
+	if port == nil {
+		// The call may have failed before the port was filled in (e.g. waiting for readiness).
+		for _, p := range opts.Target.Config().Ports {
+			if p.Name == opts.PortName {
+				port = &p
+				break
+			}
+		}
+	}
+	if port == nil {
+		return err
+	}
+	target := net.JoinHostPort(opts.Target.Config().Service, strconv.Itoa(port.ServicePort))
+	workload := ""
+	if w := opts.TargetWorkload; w != nil {
+		// Identify the workload that was called directly.
+		target = net.JoinHostPort(w.Address(), strconv.Itoa(port.InstancePort))
+		workload = fmt.Sprintf(" (workload %s of %s)", w.Hostname(), opts.Target)
+	}
+	out := fmt.Errorf("failed calling %s->'%s://%s/%s'%s: %v",
+		source,
+		strings.ToLower(string(port.Protocol)),
+		target,
+		opts.Path,
+		workload,
+		err)
+	if _, ok := err.(*echo.StreamError); ok {
+		return &echo.StreamError{Err: out}
+	}
+	return out
+}
+
 func fillInCallOptions(opts *echo.CallOptions) error {
 	if opts.Target == nil {
 		return errors.New("callOptions: missing Target")
@@ -104,9 +220,13 @@ func fillInCallOptions(opts *echo.CallOptions) error {
 			return errors.New("callOptions: PortName or Port must be provided")
 		}
 
-		// Check the specified port for a match against the Target Instance
+		// Check the specified port for a match against the Target Instance. A copy of a Target port
+		// without its MTLSMode also matches, so that the call isn't checked by CheckMTLSMode.
 		found := false
 		for _, port := range targetPorts {
+			if opts.Port.MTLSMode == "" {
+				port.MTLSMode = ""
+			}
 			if reflect.DeepEqual(port, *opts.Port) {
 				found = true
 				break
@@ -130,6 +250,10 @@ func fillInCallOptions(opts *echo.CallOptions) error {
 		}
 	}
 
+	if opts.Port.Protocol == model.ProtocolUDP {
+		return fmt.Errorf("callOptions: UDP calls are not supported via the echo client (port %s)", opts.Port.Name)
+	}
+
 	if opts.Scheme == "" {
 		// No protocol, fill it in.
 		var err error
@@ -138,10 +262,76 @@ func fillInCallOptions(opts *echo.CallOptions) error {
 		}
 	}
 
+	switch opts.Scheme {
+	case scheme.WebSocket:
+		if opts.Port.Protocol != model.ProtocolHTTP {
+			return fmt.Errorf("callOptions: WebSocket calls require an HTTP port, but port %s is %s",
+				opts.Port.Name, opts.Port.Protocol)
+		}
+	case scheme.WebSocketS:
+		if opts.Port.Protocol != model.ProtocolHTTPS {
+			return fmt.Errorf("callOptions: secure WebSocket calls require an HTTPS port, but port %s is %s",
+				opts.Port.Name, opts.Port.Protocol)
+		}
+	}
+
+	if opts.GRPCStream && opts.Scheme != scheme.GRPC && opts.Scheme != scheme.GRPCS {
+		return fmt.Errorf("callOptions: GRPCStream requires the gRPC scheme, but the scheme is %s", opts.Scheme)
+	}
+
+	if opts.Concurrency < 0 {
+		return errors.New("callOptions: Concurrency must not be negative")
+	}
+	if opts.GRPCStream && opts.Concurrency > 1 {
+		return errors.New("callOptions: the messages of a GRPCStream are sent one at a time, so Concurrency must not be > 1")
+	}
+
 	if opts.Headers == nil {
 		opts.Headers = make(http.Header)
 	}
 
+	if opts.JWT != "" && opts.Headers.Get("Authorization") != "" {
+		return errors.New("callOptions: JWT and Authorization header are mutually exclusive")
+	}
+
+	switch opts.HTTPVersion {
+	case "":
+		opts.HTTPVersion = common.HTTPVersion11
+	case common.HTTPVersion10, common.HTTPVersion11, common.HTTPVersion2:
+	case common.HTTPVersion3:
+		return errors.New("callOptions: HTTP/3 (QUIC) is not supported by the echo client/proxy")
+	default:
+		return fmt.Errorf("callOptions: unsupported HTTPVersion %q", opts.HTTPVersion)
+	}
+
+	if opts.ResponseChunks < 0 || opts.ResponseChunkDelay < 0 {
+		return errors.New("callOptions: ResponseChunks and ResponseChunkDelay must not be negative")
+	}
+	if opts.PaddingHeaders < 0 || opts.PaddingHeaderSize < 0 || opts.BodySize < 0 {
+		return errors.New("callOptions: PaddingHeaders, PaddingHeaderSize and BodySize must not be negative")
+	}
+	if opts.PaddingHeaders*opts.PaddingHeaderSize > maxPaddingBytes {
+		return fmt.Errorf("callOptions: padding headers exceed the maximum total size of %d bytes", maxPaddingBytes)
+	}
+	if len(opts.Body) > 0 {
+		if opts.BodySize > 0 {
+			return errors.New("callOptions: Body and BodySize are mutually exclusive")
+		}
+		if opts.Scheme != scheme.HTTP && opts.Scheme != scheme.HTTPS {
+			return fmt.Errorf("callOptions: Body requires the HTTP or HTTPS scheme, but the scheme is %s", opts.Scheme)
+		}
+	}
+
+	if opts.TargetWorkload != nil {
+		// The options may already have been filled in (e.g. by RetryCall), with the Host set here.
+		if opts.Host != "" && opts.Host != opts.TargetWorkload.Address() {
+			return errors.New("callOptions: TargetWorkload and Host are mutually exclusive")
+		}
+		if err := checkTargetWorkload(opts); err != nil {
+			return err
+		}
+		opts.Host = opts.TargetWorkload.Address()
+	}
 	if opts.Host == "" {
 		// No host specified, use the fully qualified domain name for the service.
 		opts.Host = opts.Target.Config().FQDN()
@@ -155,9 +345,29 @@ func fillInCallOptions(opts *echo.CallOptions) error {
 		opts.Count = common.DefaultCount
 	}
 
+	if len(opts.Body)*opts.Count > maxEchoedBodyBytes {
+		return fmt.Errorf("callOptions: Body of %d bytes for %d requests exceeds the maximum total size of %d bytes",
+			len(opts.Body), opts.Count, maxEchoedBodyBytes)
+	}
+
 	return nil
 }
 
+// checkTargetWorkload verifies that the TargetWorkload of the options is a workload of the Target.
+func checkTargetWorkload(opts *echo.CallOptions) error {
+	workloads, err := opts.Target.Workloads()
+	if err != nil {
+		return fmt.Errorf("callOptions: failed getting the workloads of the Target: %v", err)
+	}
+	for _, w := range workloads {
+		if w == opts.TargetWorkload {
+			return nil
+		}
+	}
+	return fmt.Errorf("callOptions: TargetWorkload %s is not a workload of the Target %s",
+		opts.TargetWorkload.Hostname(), opts.Target)
+}
+
 func schemeForPort(port *echo.Port) (scheme.Instance, error) {
 	switch port.Protocol {
 	case model.ProtocolGRPC, model.ProtocolGRPCWeb, model.ProtocolHTTP2:
@@ -0,0 +1,296 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/echo/server"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/common"
+)
+
+func TestCallEchoHeaders(t *testing.T) {
+	c, target, closeFn := newEchoServer(t)
+	defer closeFn()
+
+	headers := make(http.Header)
+	headers.Set("X-Request-Id", "0123456789")
+	headers.Set("X-Custom", "custom")
+	opts := echo.CallOptions{
+		Target:  target,
+		Port:    &target.Config().Ports[0],
+		Host:    "127.0.0.1",
+		Headers: headers,
+	}
+
+	responses, err := common.CallEcho(context.Background(), c, &opts, common.IdentityOutboundPortSelector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	responses.CheckOKOrFail(t)
+	r := responses[0]
+	if r.ID != "0123456789" || r.RequestHeaders.Get("X-Request-Id") != "0123456789" {
+		t.Fatalf("X-Request-Id was not received by the server: %s", r.Body)
+	}
+	if r.RequestHeaders.Get("X-Custom") != "custom" {
+		t.Fatalf("X-Custom was not received by the server: %v", r.RequestHeaders)
+	}
+	// The Host header is the target service, regardless of the Host used to reach it.
+	if r.Host != "b" {
+		t.Fatalf("expected Host b, received %s", r.Host)
+	}
+}
+
+func TestCallEchoBody(t *testing.T) {
+	c, target, closeFn := newEchoServer(t)
+	defer closeFn()
+
+	// The body is echoed verbatim, including binary data and newlines.
+	body := make([]byte, 0, 1024*1024)
+	for i := 0; i < cap(body); i++ {
+		body = append(body, byte(i%256))
+	}
+	opts := echo.CallOptions{
+		Target: target,
+		Port:   &target.Config().Ports[0],
+		Host:   "127.0.0.1",
+		Body:   body,
+	}
+	responses, err := common.CallEcho(context.Background(), c, &opts, common.IdentityOutboundPortSelector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	responses.CheckOKOrFail(t).CheckRequestBodyOrFail(t, body)
+
+	// The echoed bodies must fit in the response of the echo app.
+	opts.Count = 3
+	if _, err := common.CallEcho(context.Background(), c, &opts, common.IdentityOutboundPortSelector); err == nil {
+		t.Fatal("expected error for bodies exceeding the maximum size")
+	}
+}
+
+func TestCallEchoConcurrency(t *testing.T) {
+	c, target, closeFn := newEchoServer(t)
+	defer closeFn()
+
+	// Each response is delayed, so that 2 rounds of 2 concurrent requests take at least 2 delays.
+	const delay = 100 * time.Millisecond
+	opts := echo.CallOptions{
+		Target:             target,
+		Port:               &target.Config().Ports[0],
+		Host:               "127.0.0.1",
+		Count:              4,
+		Concurrency:        2,
+		ResponseChunks:     1,
+		ResponseChunkDelay: delay,
+	}
+	start := time.Now()
+	responses, err := common.CallEcho(context.Background(), c, &opts, common.IdentityOutboundPortSelector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 2*delay {
+		t.Fatalf("expected at most 2 requests in flight, but the call took only %v", elapsed)
+	}
+	if responses.Len() != 4 {
+		t.Fatalf("expected 4 responses, got %d", responses.Len())
+	}
+	responses.CheckOKOrFail(t)
+
+	opts.Concurrency = -1
+	if _, err := common.CallEcho(context.Background(), c, &opts, common.IdentityOutboundPortSelector); err == nil {
+		t.Fatal("expected error for negative Concurrency")
+	}
+}
+
+func TestCallEchoTargetWorkload(t *testing.T) {
+	c, target, closeFn := newEchoServer(t)
+	defer closeFn()
+
+	// The call is sent to the address and instance port of the workload, bypassing the service port.
+	target.address = "127.0.0.1"
+	target.instancePort = target.servicePort
+	target.servicePort = 1
+	opts := echo.CallOptions{
+		Target:         target,
+		TargetWorkload: target,
+		Port:           &target.Config().Ports[0],
+	}
+	responses, err := common.CallEcho(context.Background(), c, &opts, common.IdentityOutboundPortSelector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	responses.CheckOKOrFail(t)
+
+	// Failures identify the workload.
+	err = common.CallError(target, &opts, errors.New("refused"))
+	if err == nil || !strings.Contains(err.Error(), "workload b-0 of ns/b") {
+		t.Fatalf("expected the workload in the error, got %v", err)
+	}
+
+	// The filled-in options are reused by each attempt of a retried call.
+	opts = echo.CallOptions{
+		Target:         target,
+		TargetWorkload: target,
+		Port:           &target.Config().Ports[0],
+	}
+	responses, err = common.RetryCall(context.Background(), &opts, false, func() (client.ParsedResponses, error) {
+		return common.CallEcho(context.Background(), c, &opts, common.IdentityOutboundPortSelector)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	responses.CheckOKOrFail(t)
+
+	opts.Host = "b"
+	if _, err := common.CallEcho(context.Background(), c, &opts, common.IdentityOutboundPortSelector); err == nil {
+		t.Fatal("expected error for TargetWorkload with Host")
+	}
+
+	// The workload must belong to the Target.
+	other := *target
+	opts = echo.CallOptions{
+		Target:         target,
+		TargetWorkload: &other,
+		Port:           &target.Config().Ports[0],
+	}
+	if _, err := common.CallEcho(context.Background(), c, &opts, common.IdentityOutboundPortSelector); err == nil {
+		t.Fatal("expected error for a TargetWorkload of another Target")
+	}
+}
+
+func TestCallEchoCluster(t *testing.T) {
+	call := func(c *client.Instance, target *config) client.ParsedResponses {
+		t.Helper()
+		opts := echo.CallOptions{
+			Target: target,
+			Port:   &target.Config().Ports[0],
+			Host:   "127.0.0.1",
+			Count:  2,
+		}
+		responses, err := common.CallEcho(context.Background(), c, &opts, common.IdentityOutboundPortSelector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return responses
+	}
+
+	c, target, closeFn := newEchoServerWithConfig(t, server.Config{Cluster: "cluster-1"})
+	defer closeFn()
+	responses := call(c, target)
+	responses.CheckClusterOrFail(t, "cluster-1")
+	if clusters := responses.Clusters(); len(clusters) != 2 || clusters[1] != "cluster-1" {
+		t.Fatalf("unexpected clusters: %v", clusters)
+	}
+	if err := responses.CheckCluster("cluster-2"); err == nil {
+		t.Fatal("expected error for the wrong cluster")
+	}
+
+	// Servers that don't report a cluster never match.
+	c, target, closeUnnamed := newEchoServer(t)
+	defer closeUnnamed()
+	if err := call(c, target).CheckCluster(""); err == nil || !strings.Contains(err.Error(), "doesn't report a cluster") {
+		t.Fatalf("expected error for responses without a cluster, got %v", err)
+	}
+}
+
+func TestCallError(t *testing.T) {
+	source := &config{service: "a", namespace: "ns"}
+	target := &config{protocol: model.ProtocolHTTP, service: "b", namespace: "ns", servicePort: 8080}
+	refused := errors.New("refused")
+
+	opts := echo.CallOptions{Target: target, Path: "/path"}
+	err := common.CallError(source, &opts, refused)
+	if err == nil || !strings.Contains(err.Error(), "'http://b:8080//path'") || !strings.Contains(err.Error(), "refused") {
+		t.Fatalf("expected the target service, port and path in the error, got %v", err)
+	}
+
+	// Errors that can't be attributed to a port of the target service are returned as is.
+	for _, opts := range []echo.CallOptions{
+		{Port: &echo.Port{Name: "http", ServicePort: 8080}},
+		{Target: target, PortName: "grpc"},
+	} {
+		if err := common.CallError(source, &opts, refused); err != refused {
+			t.Fatalf("expected the error to be returned as is for options %+v, got %v", opts, err)
+		}
+	}
+}
+
+// newEchoServer runs an echo server in-process, and returns a client to forward calls from it along
+// with a target for its own HTTP port, and a function to close them.
+func newEchoServer(t *testing.T) (*client.Instance, *config, func()) {
+	t.Helper()
+	return newEchoServerWithConfig(t, server.Config{})
+}
+
+// newEchoServerWithConfig is like newEchoServer, but runs the echo server with the given config. The
+// ports are always the same.
+func newEchoServerWithConfig(t *testing.T, cfg server.Config) (*client.Instance, *config, func()) {
+	t.Helper()
+	cfg.Ports = model.PortList{
+		{Name: "grpc", Protocol: model.ProtocolGRPC},
+		{Name: "http", Protocol: model.ProtocolHTTP},
+	}
+	s := server.New(cfg)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := client.New(fmt.Sprintf("127.0.0.1:%d", s.Ports[0].Port))
+	if err != nil {
+		_ = s.Close()
+		t.Fatal(err)
+	}
+	closeFn := func() {
+		_ = c.Close()
+		_ = s.Close()
+	}
+	return c, &config{
+		protocol:    model.ProtocolHTTP,
+		service:     "b",
+		namespace:   "ns",
+		domain:      "svc.cluster.local",
+		servicePort: s.Ports[1].Port,
+	}, closeFn
+}
+
+func TestCallEchoUnsupportedOptions(t *testing.T) {
+	c, target, closeFn := newEchoServer(t)
+	defer closeFn()
+
+	udpTarget := &config{protocol: model.ProtocolUDP, service: "b", namespace: "ns", servicePort: 53}
+	for _, tc := range []struct {
+		opts echo.CallOptions
+		err  string
+	}{
+		{echo.CallOptions{Target: target, Port: &target.Config().Ports[0], HTTPVersion: "3"}, "HTTP/3 (QUIC) is not supported"},
+		{echo.CallOptions{Target: target, Port: &target.Config().Ports[0], HTTPVersion: "4"}, "unsupported HTTPVersion"},
+		{echo.CallOptions{Target: udpTarget, Port: &udpTarget.Config().Ports[0]}, "UDP calls are not supported via the echo client"},
+	} {
+		_, err := common.CallEcho(context.Background(), c, &tc.opts, common.IdentityOutboundPortSelector)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("expected error %q for unsupported options %+v, got %v", tc.err, tc.opts, err)
+		}
+	}
+}
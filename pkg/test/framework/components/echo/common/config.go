@@ -16,6 +16,7 @@ package common
 
 import (
 	"fmt"
+	"strings"
 
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/test/framework/components/echo"
@@ -27,6 +28,8 @@ const (
 	defaultService   = "echo"
 	defaultVersion   = "v1"
 	defaultNamespace = "echo"
+
+	defaultTrustDomain = "cluster.local"
 )
 
 func FillInDefaults(ctx resource.Context, defaultDomain string, c *echo.Config) (err error) {
@@ -41,6 +44,31 @@ func FillInDefaults(ctx resource.Context, defaultDomain string, c *echo.Config)
 	if c.Domain == "" {
 		c.Domain = defaultDomain
 	}
+	if strings.HasPrefix(c.Domain, ".") || strings.HasSuffix(c.Domain, ".") {
+		return fmt.Errorf("domain %q of service %s must not start or end with a dot", c.Domain, c.Service)
+	}
+
+	if c.TrustDomain == "" {
+		c.TrustDomain = defaultTrustDomain
+	}
+
+	switch c.ServiceType {
+	case "":
+		c.ServiceType = echo.ServiceTypeClusterIP
+		if c.Headless {
+			c.ServiceType = echo.ServiceTypeHeadless
+		}
+	case echo.ServiceTypeHeadless:
+		c.Headless = true
+	}
+
+	if c.DeploymentKind == "" {
+		c.DeploymentKind = echo.DeploymentKindDeployment
+	}
+
+	if c.Replicas <= 0 {
+		c.Replicas = 1
+	}
 
 	// If no namespace was provided, use the default.
 	if c.Namespace == nil {
@@ -53,9 +81,13 @@ func FillInDefaults(ctx resource.Context, defaultDomain string, c *echo.Config)
 	// Instances share the same underlying ports array.
 	c.Ports = append([]echo.Port{}, c.Ports...)
 
+	if err = validateControlPort(c); err != nil {
+		return err
+	}
+
 	// Append a gRPC port, if none was provided. This is needed
-	// for controlling the app.
-	if GetGRPCPort(c) == nil {
+	// for controlling the app, which isn't deployed for gateways.
+	if !c.Gateway && GetGRPCPort(c) == nil {
 		c.Ports = append([]echo.Port{
 			{
 				Name:     "grpc",
@@ -102,7 +134,14 @@ func FillInDefaults(ctx resource.Context, defaultDomain string, c *echo.Config)
 	return nil
 }
 
+// GetGRPCPort returns the gRPC port used to control the echo application, i.e. the ControlPort, if
+// marked, or otherwise the first gRPC port. Returns nil if there is none.
 func GetGRPCPort(c *echo.Config) *echo.Port {
+	for _, p := range c.Ports {
+		if p.ControlPort {
+			return &p
+		}
+	}
 	for _, p := range c.Ports {
 		if p.Protocol == model.ProtocolGRPC {
 			return &p
@@ -110,3 +149,22 @@ func GetGRPCPort(c *echo.Config) *echo.Port {
 	}
 	return nil
 }
+
+// validateControlPort verifies that at most one gRPC port of the configuration is marked as the
+// ControlPort.
+func validateControlPort(c *echo.Config) error {
+	var control *echo.Port
+	for i, p := range c.Ports {
+		if !p.ControlPort {
+			continue
+		}
+		if control != nil {
+			return fmt.Errorf("multiple control ports for service %s: %s and %s", c.Service, control.Name, p.Name)
+		}
+		if p.Protocol != model.ProtocolGRPC {
+			return fmt.Errorf("control port %s for service %s must be GRPC, but is %s", p.Name, c.Service, p.Protocol)
+		}
+		control = &c.Ports[i]
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"istio.io/istio/pkg/test/framework/components/echo"
+)
+
+// WaitForReadinessDependencies waits until each of the ReadinessDependencies of the given Instance is
+// ready, and returns them so that the caller can wait for the corresponding outbound configuration.
+// If the deadline isn't zero, each wait is bounded by it. An error is returned if the dependencies
+// are circular.
+func WaitForReadinessDependencies(instance echo.Instance, deadline time.Time) ([]echo.Instance, error) {
+	deps, err := ReadinessDependencies(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dep := range deps {
+		if deadline.IsZero() {
+			err = dep.WaitUntilReady()
+		} else {
+			err = dep.WaitUntilReadyWithin(UntilDeadline(deadline))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("readiness dependency %s of %s not ready: %v",
+				dep, instance, err)
+		}
+	}
+	return deps, nil
+}
+
+// UntilDeadline returns the time remaining until the (non-zero) deadline. Once the deadline has
+// passed, the smallest positive duration is returned, so that a wait bounded by it makes a single
+// attempt rather than waiting without a limit.
+func UntilDeadline(deadline time.Time) time.Duration {
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return time.Nanosecond
+	}
+	return remaining
+}
+
+// ReadinessDependencies returns the ReadinessDependencies of the given Instance, without waiting for
+// them. An error is returned if the dependencies are circular.
+func ReadinessDependencies(instance echo.Instance) ([]echo.Instance, error) {
+	if err := checkDependencyCycle(instance, nil); err != nil {
+		return nil, err
+	}
+	return instance.Config().ReadinessDependencies, nil
+}
+
+// checkDependencyCycle walks the ReadinessDependencies of the instance, which is reached via the
+// given path of dependents, returning an error if the instance depends on itself.
+func checkDependencyCycle(instance echo.Instance, path []echo.Instance) error {
+	for i, dependent := range path {
+		if dependent == instance {
+			names := make([]string, 0, len(path)-i+1)
+			for _, p := range append(path[i:], instance) {
+				names = append(names, p.Config().Service)
+			}
+			return fmt.Errorf("circular readiness dependencies: %s", strings.Join(names, " -> "))
+		}
+	}
+
+	path = append(path, instance)
+	for _, dep := range instance.Config().ReadinessDependencies {
+		if err := checkDependencyCycle(dep, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common_test
+
+import (
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/common"
+)
+
+// dependency records how it was waited for.
+type dependency struct {
+	echo.Instance
+	cfg echo.Config
+
+	unbounded bool
+	timeout   time.Duration
+}
+
+func (d *dependency) Config() echo.Config {
+	return d.cfg
+}
+
+func (d *dependency) String() string {
+	return d.cfg.Service
+}
+
+func (d *dependency) WaitUntilReady(...echo.Instance) error {
+	d.unbounded = true
+	return nil
+}
+
+func (d *dependency) WaitUntilReadyWithin(timeout time.Duration, _ ...echo.Instance) error {
+	d.timeout = timeout
+	return nil
+}
+
+func TestWaitForReadinessDependencies(t *testing.T) {
+	dep := &dependency{cfg: echo.Config{Service: "b"}}
+	instance := &dependency{cfg: echo.Config{Service: "a", ReadinessDependencies: []echo.Instance{dep}}}
+
+	// Without a deadline, the wait isn't bounded.
+	deps, err := common.WaitForReadinessDependencies(instance, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deps) != 1 || deps[0] != dep || !dep.unbounded {
+		t.Fatalf("expected unbounded wait for the dependency, got %v (unbounded: %v)", deps, dep.unbounded)
+	}
+
+	// The wait is bounded by the deadline.
+	*dep = dependency{cfg: dep.cfg}
+	if _, err := common.WaitForReadinessDependencies(instance, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if dep.unbounded || dep.timeout <= 0 || dep.timeout > time.Minute {
+		t.Fatalf("expected wait bounded by the deadline, got %v (unbounded: %v)", dep.timeout, dep.unbounded)
+	}
+
+	// A passed deadline still bounds the wait, rather than disabling the timeout.
+	*dep = dependency{cfg: dep.cfg}
+	if _, err := common.WaitForReadinessDependencies(instance, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if dep.unbounded || dep.timeout != time.Nanosecond {
+		t.Fatalf("expected a single attempt, got %v (unbounded: %v)", dep.timeout, dep.unbounded)
+	}
+}
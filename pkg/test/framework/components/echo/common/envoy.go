@@ -22,6 +22,7 @@ import (
 	envoyAdmin "github.com/envoyproxy/go-control-plane/envoy/admin/v2alpha"
 	"github.com/gogo/protobuf/jsonpb"
 
+	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/test/framework/components/echo"
 	"istio.io/istio/pkg/test/util/retry"
 	"istio.io/istio/pkg/test/util/structpath"
@@ -84,14 +85,31 @@ func WaitForConfig(fetch ConfigFetchFunc, accept ConfigAcceptFunc, options ...re
 	return nil
 }
 
+// CheckConfig fetches the Envoy configuration once, and indicates whether it's accepted. An error of
+// the accept function, which WaitForConfig would retry, means that the config isn't accepted (yet).
+func CheckConfig(fetch ConfigFetchFunc, accept ConfigAcceptFunc) (bool, error) {
+	cfg, err := fetch()
+	if err != nil {
+		return false, err
+	}
+	accepted, err := accept(cfg)
+	return accepted && err == nil, nil
+}
+
 // OutboundConfigAcceptFunc returns a function that accepts Envoy configuration if it contains
-// outbound configuration for all of the given instances.
+// outbound configuration for all of the given instances. The function is stateless, so it may be
+// used concurrently (e.g. for all workloads of an Instance).
 func OutboundConfigAcceptFunc(outboundInstances ...echo.Instance) ConfigAcceptFunc {
 	return func(cfg *envoyAdmin.ConfigDump) (bool, error) {
 		validator := structpath.ForProto(cfg)
 
 		for _, target := range outboundInstances {
 			for _, port := range target.Config().Ports {
+				if port.Unexposed || port.Protocol == model.ProtocolUDP {
+					// No outbound configuration is generated for ports not exposed by the Service, or
+					// for UDP ports, which aren't supported by the proxy.
+					continue
+				}
 				// Ensure that we have an outbound configuration for the target port.
 				if err := CheckOutboundConfig(target, port, validator); err != nil {
 					return false, err
@@ -16,9 +16,12 @@ package common_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"testing"
+	"time"
 
 	envoyAdmin "github.com/envoyproxy/go-control-plane/envoy/admin/v2alpha"
 
@@ -105,16 +108,64 @@ func TestCheckOutboundConfig(t *testing.T) {
 	}
 }
 
+func TestCheckConfig(t *testing.T) {
+	cfg := &envoyAdmin.ConfigDump{}
+	fetch := func() (*envoyAdmin.ConfigDump, error) {
+		return cfg, nil
+	}
+
+	cases := []struct {
+		name     string
+		fetch    common.ConfigFetchFunc
+		accept   common.ConfigAcceptFunc
+		expected bool
+		err      bool
+	}{
+		{
+			name:     "accepted",
+			fetch:    fetch,
+			accept:   func(*envoyAdmin.ConfigDump) (bool, error) { return true, nil },
+			expected: true,
+		},
+		{
+			name:   "rejected",
+			fetch:  fetch,
+			accept: func(*envoyAdmin.ConfigDump) (bool, error) { return false, nil },
+		},
+		{
+			name:   "not accepted yet",
+			fetch:  fetch,
+			accept: func(*envoyAdmin.ConfigDump) (bool, error) { return true, errors.New("missing cluster") },
+		},
+		{
+			name:   "fetch failed",
+			fetch:  func() (*envoyAdmin.ConfigDump, error) { return nil, errors.New("exec failed") },
+			accept: func(*envoyAdmin.ConfigDump) (bool, error) { return true, nil },
+			err:    true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			accepted, err := common.CheckConfig(c.fetch, c.accept)
+			if accepted != c.expected || (err != nil) != c.err {
+				t.Fatalf("expected (%v, error: %v), got (%v, %v)", c.expected, c.err, accepted, err)
+			}
+		})
+	}
+}
+
 var _ echo.Instance = &config{}
 var _ echo.Workload = &config{}
 
 type config struct {
-	protocol    model.Protocol
-	servicePort int
-	address     string
-	service     string
-	domain      string
-	namespace   string
+	protocol     model.Protocol
+	servicePort  int
+	instancePort int
+	address      string
+	service      string
+	domain       string
+	namespace    string
+	mtlsMode     echo.MTLSMode
 }
 
 func (e *config) Owner() echo.Instance {
@@ -132,6 +183,18 @@ func (e *config) Address() string {
 	return e.address
 }
 
+func (e *config) Addresses() ([]echo.ServiceAddress, error) {
+	panic("not implemented")
+}
+
+func (e *config) AddressesOrFail(testing.TB) []echo.ServiceAddress {
+	panic("not implemented")
+}
+
+func (e *config) AddressOf(echo.AddressType) string {
+	panic("not implemented")
+}
+
 func (e *config) Config() echo.Config {
 	return echo.Config{
 		Service: e.service,
@@ -141,8 +204,10 @@ func (e *config) Config() echo.Config {
 		Domain: e.domain,
 		Ports: []echo.Port{
 			{
-				ServicePort: e.servicePort,
-				Protocol:    e.protocol,
+				ServicePort:  e.servicePort,
+				InstancePort: e.instancePort,
+				Protocol:     e.protocol,
+				MTLSMode:     e.mtlsMode,
 			},
 		},
 	}
@@ -156,14 +221,50 @@ func (e *config) ID() resource.ID {
 	panic("not implemented")
 }
 
+func (e *config) String() string {
+	return e.Config().NamespacedName()
+}
+
+func (e *config) Equals(echo.Instance) bool {
+	panic("not implemented")
+}
+
 func (e *config) WorkloadsOrFail(t testing.TB) []echo.Workload {
 	panic("not implemented")
 }
 
+func (e *config) WorkloadsByVersion() (map[string][]echo.Workload, error) {
+	panic("not implemented")
+}
+
+func (e *config) WorkloadsByVersionOrFail(testing.TB) map[string][]echo.Workload {
+	panic("not implemented")
+}
+
+func (e *config) Sidecars() ([]echo.Sidecar, error) {
+	panic("not implemented")
+}
+
+func (e *config) SidecarsOrFail(testing.TB) []echo.Sidecar {
+	panic("not implemented")
+}
+
 func (e *config) WaitUntilReady(_ ...echo.Instance) error {
 	panic("not implemented")
 }
 
+func (e *config) WaitUntilNotReady(time.Duration) error {
+	panic("not implemented")
+}
+
+func (e *config) WaitUntilReadyWithin(time.Duration, ...echo.Instance) error {
+	panic("not implemented")
+}
+
+func (e *config) IsReady(...echo.Instance) (bool, error) {
+	panic("not implemented")
+}
+
 func (e *config) WaitUntilReadyOrFail(_ testing.TB, _ ...echo.Instance) {
 	panic("not implemented")
 }
@@ -176,6 +277,90 @@ func (e *config) CallOrFail(_ testing.TB, _ echo.CallOptions) client.ParsedRespo
 	panic("not implemented")
 }
 
+func (e *config) CallWithResult(echo.CallOptions) (echo.CallResult, error) {
+	panic("not implemented")
+}
+
+func (e *config) CallContext(context.Context, echo.CallOptions) (client.ParsedResponses, error) {
+	panic("not implemented")
+}
+
+func (e *config) HealthSummary() (echo.HealthSummary, error) {
+	panic("not implemented")
+}
+
+func (e *config) LabelWorkload(int, string, string) error {
+	panic("not implemented")
+}
+
+func (e *config) Restart() error {
+	panic("not implemented")
+}
+
+func (e *config) Scale(int) error {
+	panic("not implemented")
+}
+
+func (e *config) Delete() error {
+	panic("not implemented")
+}
+
+func (e *config) WorkloadLabels(int) (map[string]string, error) {
+	panic("not implemented")
+}
+
+func (e *config) ExecInWorkload(int, []string) (string, string, error) {
+	panic("not implemented")
+}
+
+func (e *config) ForwardPort(uint16) (uint16, func(), error) {
+	panic("not implemented")
+}
+
+func (e *config) Logs(string) (string, error) {
+	panic("not implemented")
+}
+
+func (e *config) LogsOrFail(testing.TB, string) string {
+	panic("not implemented")
+}
+
+func (e *config) DiagnosticBundle(string) error {
+	panic("not implemented")
+}
+
+func (e *config) Hostname() string {
+	return e.service + "-0"
+}
+
+func (e *config) SecondaryAddresses() []string {
+	panic("not implemented")
+}
+
+func (e *config) PodName() string {
+	panic("not implemented")
+}
+
+func (e *config) NodeName() string {
+	panic("not implemented")
+}
+
+func (e *config) Version() string {
+	panic("not implemented")
+}
+
+func (e *config) PodFQDN() string {
+	panic("not implemented")
+}
+
+func (e *config) RequestCount(string) (int, error) {
+	panic("not implemented")
+}
+
+func (e *config) Exec([]string) (string, string, error) {
+	panic("not implemented")
+}
+
 func (e *config) Sidecar() echo.Sidecar {
 	panic("not implemented")
 }
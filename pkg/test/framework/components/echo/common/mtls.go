@@ -0,0 +1,92 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/framework/components/echo"
+)
+
+// CheckMTLSMode verifies the outcome of a call against the MTLSMode expected for the target port.
+// Calls from a source without a sidecar to a STRICT port are expected to be rejected, i.e. the
+// connection is reset, refused or closed by the target, or a non-2xx response is received, in which
+// case no error is returned. Other errors (e.g. invalid options, or a timeout) are returned as is.
+// For HTTP-based ports, the use of mTLS is verified via the
+// X-Forwarded-Client-Cert header received by the target. The (possibly updated) call error is
+// returned.
+func CheckMTLSMode(sourceSidecar bool, port *echo.Port, responses client.ParsedResponses, callErr error) error {
+	if port == nil || port.MTLSMode == "" {
+		return callErr
+	}
+
+	if port.MTLSMode == echo.MTLSModeStrict && !sourceSidecar {
+		if callErr != nil {
+			if isConnectionRejected(callErr) {
+				// Rejected, as expected.
+				return nil
+			}
+			return callErr
+		}
+		if responses.CheckOK() != nil {
+			// Rejected, as expected.
+			return nil
+		}
+		return fmt.Errorf("plaintext call to port %s was accepted, but the port is %s", port.Name, port.MTLSMode)
+	}
+	if callErr != nil {
+		return callErr
+	}
+
+	switch port.Protocol {
+	case model.ProtocolTCP, model.ProtocolHTTPS, model.ProtocolTLS:
+		// The target's sidecar doesn't forward the client certificate details.
+		return nil
+	}
+
+	expectMTLS := sourceSidecar && port.MTLSMode != echo.MTLSModeDisable
+	return responses.Check(func(i int, response *client.ParsedResponse) error {
+		if response.IsMTLS() != expectMTLS {
+			return fmt.Errorf("response[%d] mTLS to port %s (%s): expected %v, received %v",
+				i, port.Name, port.MTLSMode, expectMTLS, response.IsMTLS())
+		}
+		return nil
+	})
+}
+
+// connectionRejections are the (transport) errors of a connection rejected by the target.
+var connectionRejections = []string{
+	"connection reset",
+	"connection refused",
+	"connection closed",
+	"broken pipe",
+	"transport is closing",
+	"EOF",
+}
+
+// isConnectionRejected indicates whether the call error is due to the target rejecting the
+// connection, rather than a failure to make the request.
+func isConnectionRejected(err error) bool {
+	msg := err.Error()
+	for _, rejection := range connectionRejections {
+		if strings.Contains(msg, rejection) {
+			return true
+		}
+	}
+	return false
+}
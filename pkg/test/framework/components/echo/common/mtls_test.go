@@ -0,0 +1,95 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/common"
+)
+
+func TestCheckMTLSMode(t *testing.T) {
+	plaintext := client.ParsedResponses{{Code: "200"}}
+	mtls := client.ParsedResponses{{Code: "200", ForwardedClientCert: "By=spiffe://cluster.local/ns/ns/sa/b"}}
+	denied := client.ParsedResponses{{Code: "503"}}
+
+	cases := []struct {
+		name      string
+		sidecar   bool
+		mode      echo.MTLSMode
+		protocol  model.Protocol
+		responses client.ParsedResponses
+		callErr   error
+		err       string
+	}{
+		{name: "no mode", mode: "", responses: plaintext},
+		{name: "no mode call error", mode: "", callErr: errors.New("failed"), err: "failed"},
+
+		// Plaintext calls to a STRICT port.
+		{name: "strict reset", mode: echo.MTLSModeStrict, callErr: errors.New("read: connection reset by peer")},
+		{name: "strict refused", mode: echo.MTLSModeStrict, callErr: errors.New("dial tcp: connection refused")},
+		{name: "strict closed", mode: echo.MTLSModeStrict, callErr: errors.New(`Get "http://b:80": EOF`)},
+		{name: "strict non-2xx", mode: echo.MTLSModeStrict, responses: denied},
+		{name: "strict accepted", mode: echo.MTLSModeStrict, responses: plaintext,
+			err: "plaintext call to port http was accepted, but the port is STRICT"},
+		// Errors raised before the request is sent aren't rejections.
+		{name: "strict invalid options", mode: echo.MTLSModeStrict,
+			callErr: errors.New("callOptions: UDP calls are not supported via the echo client (port http)"),
+			err:     "UDP calls are not supported"},
+		{name: "strict timeout", mode: echo.MTLSModeStrict, callErr: errors.New("context deadline exceeded"),
+			err: "context deadline exceeded"},
+		{name: "strict no app", mode: echo.MTLSModeStrict, callErr: errors.New("workload 10.0.0.1 of a has no echo app to make calls"),
+			err: "no echo app"},
+
+		// Calls from a sidecar.
+		{name: "strict mTLS", sidecar: true, mode: echo.MTLSModeStrict, responses: mtls},
+		{name: "strict call error", sidecar: true, mode: echo.MTLSModeStrict, callErr: errors.New("connection reset"),
+			err: "connection reset"},
+		{name: "strict no mTLS", sidecar: true, mode: echo.MTLSModeStrict, responses: plaintext,
+			err: "response[0] mTLS to port http (STRICT): expected true, received false"},
+		{name: "permissive mTLS", sidecar: true, mode: echo.MTLSModePermissive, responses: mtls},
+		{name: "permissive plaintext", mode: echo.MTLSModePermissive, responses: plaintext},
+		{name: "disable", sidecar: true, mode: echo.MTLSModeDisable, responses: plaintext},
+		{name: "disable mTLS", sidecar: true, mode: echo.MTLSModeDisable, responses: mtls,
+			err: "expected false, received true"},
+		// The use of mTLS isn't visible to TCP ports.
+		{name: "tcp", sidecar: true, mode: echo.MTLSModeStrict, protocol: model.ProtocolTCP, responses: plaintext},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			protocol := c.protocol
+			if protocol == "" {
+				protocol = model.ProtocolHTTP
+			}
+			port := &echo.Port{Name: "http", Protocol: protocol, ServicePort: 80, MTLSMode: c.mode}
+
+			err := common.CheckMTLSMode(c.sidecar, port, c.responses, c.callErr)
+			if c.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("expected error containing %q, got: %v", c.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"time"
+
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/framework/components/echo"
+)
+
+const (
+	defaultCallAttempts       = 3
+	defaultCallInitialBackoff = 100 * time.Millisecond
+	defaultCallMaxBackoff     = time.Second
+)
+
+// RetryCall makes a call with the options via the given function, retrying according to the Retry
+// policy of the options until the context is done. Invalid options aren't retried, nor are calls
+// from a source without a sidecar that are expected to be rejected (see CheckMTLSMode). Returns the
+// result of the last attempt.
+func RetryCall(ctx context.Context, opts *echo.CallOptions, sourceSidecar bool,
+	call func() (client.ParsedResponses, error)) (client.ParsedResponses, error) {
+	if err := fillInCallOptions(opts); err != nil {
+		return nil, err
+	}
+
+	policy := opts.Retry
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = defaultCallAttempts
+	}
+	// Retrying an expected rejection would only repeat it until the attempts run out.
+	expectRejected := opts.Port != nil && opts.Port.MTLSMode == echo.MTLSModeStrict && !sourceSidecar
+	if policy.Disabled || expectRejected {
+		policy.MaxAttempts = 1
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = defaultCallInitialBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = defaultCallMaxBackoff
+	}
+	if policy.RetryIf == nil {
+		policy.RetryIf = echo.DefaultRetryIf
+	}
+
+	backoff := policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		responses, err := call()
+		if attempt >= policy.MaxAttempts || !policy.RetryIf(responses, err) {
+			return responses, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return responses, err
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/common"
+)
+
+func TestRetryCall(t *testing.T) {
+	errCall := errors.New("call failed")
+	ok := client.ParsedResponses{{Code: "200"}}
+	unavailable := client.ParsedResponses{{Code: "503"}}
+
+	cases := []struct {
+		name             string
+		retry            echo.CallRetry
+		mtlsMode         echo.MTLSMode
+		results          []client.ParsedResponses
+		expectedAttempts int
+		expectErr        bool
+
+		// check of the final responses, if any.
+		check func(client.ParsedResponses) error
+	}{
+		{
+			name:             "success",
+			results:          []client.ParsedResponses{ok},
+			expectedAttempts: 1,
+		},
+		{
+			name:             "retried until success",
+			results:          []client.ParsedResponses{nil, unavailable, ok},
+			expectedAttempts: 3,
+		},
+		{
+			name:             "default max attempts",
+			results:          []client.ParsedResponses{nil, nil, nil, ok},
+			expectedAttempts: 3,
+			expectErr:        true,
+		},
+		{
+			name:             "max attempts",
+			retry:            echo.CallRetry{MaxAttempts: 4},
+			results:          []client.ParsedResponses{nil, nil, nil, ok},
+			expectedAttempts: 4,
+		},
+		{
+			name:             "disabled",
+			retry:            echo.CallRetry{Disabled: true, MaxAttempts: 4},
+			results:          []client.ParsedResponses{nil, ok},
+			expectedAttempts: 1,
+			expectErr:        true,
+		},
+		{
+			name: "retry if",
+			retry: echo.CallRetry{RetryIf: func(client.ParsedResponses, error) bool {
+				return false
+			}},
+			results:          []client.ParsedResponses{unavailable, ok},
+			expectedAttempts: 1,
+		},
+		{
+			name:             "JWT unauthenticated not retried",
+			results:          []client.ParsedResponses{{{Code: "401"}}, ok},
+			expectedAttempts: 1,
+			check:            client.ParsedResponses.CheckJWTRejected,
+		},
+		{
+			name:             "denied not retried",
+			results:          []client.ParsedResponses{{{Code: "403", ExtAuthzCheckResult: "denied"}}, ok},
+			expectedAttempts: 1,
+			check: func(r client.ParsedResponses) error {
+				return r.CheckExtAuthzDecision(client.ExtAuthzDenied)
+			},
+		},
+		{
+			name:             "request too large not retried",
+			results:          []client.ParsedResponses{{{Code: "413"}}, ok},
+			expectedAttempts: 1,
+			check:            client.ParsedResponses.CheckRequestTooLarge,
+		},
+		{
+			name:             "rate limited not retried",
+			results:          []client.ParsedResponses{{ok[0], {Code: "429"}}, ok},
+			expectedAttempts: 1,
+			check: func(r client.ParsedResponses) error {
+				return r.CheckRateLimited(1, 0)
+			},
+		},
+		{
+			name:             "headers too large not retried",
+			results:          []client.ParsedResponses{{{Code: "431"}}, ok},
+			expectedAttempts: 1,
+			check:            client.ParsedResponses.CheckHeadersTooLarge,
+		},
+		{
+			name:             "retried along with an expected rejection",
+			results:          []client.ParsedResponses{{{Code: "429"}, {Code: "503"}}, ok},
+			expectedAttempts: 2,
+		},
+		{
+			name:             "expected rejection",
+			mtlsMode:         echo.MTLSModeStrict,
+			results:          []client.ParsedResponses{nil, ok},
+			expectedAttempts: 1,
+			expectErr:        true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			target := &config{
+				protocol:    model.ProtocolHTTP,
+				service:     "b",
+				namespace:   "ns",
+				domain:      "svc.cluster.local",
+				servicePort: 80,
+				mtlsMode:    c.mtlsMode,
+			}
+			retry := c.retry
+			retry.InitialBackoff = time.Millisecond
+			opts := echo.CallOptions{
+				Target: target,
+				Port:   &target.Config().Ports[0],
+				Retry:  retry,
+			}
+
+			attempts := 0
+			responses, err := common.RetryCall(context.Background(), &opts, false, func() (client.ParsedResponses, error) {
+				r := c.results[attempts]
+				attempts++
+				if r == nil {
+					return nil, errCall
+				}
+				return r, nil
+			})
+			if attempts != c.expectedAttempts {
+				t.Fatalf("expected %d attempts, made %d", c.expectedAttempts, attempts)
+			}
+			if (err != nil) != c.expectErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.check != nil {
+				if err := c.check(responses); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
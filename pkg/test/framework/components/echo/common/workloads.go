@@ -0,0 +1,29 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"istio.io/istio/pkg/test/framework/components/echo"
+)
+
+// WorkloadsByVersion groups the workloads by their Version, in order. Workloads without a version
+// are grouped under "".
+func WorkloadsByVersion(workloads []echo.Workload) map[string][]echo.Workload {
+	out := make(map[string][]echo.Workload)
+	for _, w := range workloads {
+		out[w.Version()] = append(out[w.Version()], w)
+	}
+	return out
+}
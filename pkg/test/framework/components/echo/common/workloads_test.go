@@ -0,0 +1,49 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common_test
+
+import (
+	"reflect"
+	"testing"
+
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/common"
+)
+
+func TestWorkloadsByVersion(t *testing.T) {
+	v1a := &versionedWorkload{version: "v1"}
+	v1b := &versionedWorkload{version: "v1"}
+	v2 := &versionedWorkload{version: "v2"}
+	unlabeled := &versionedWorkload{}
+
+	actual := common.WorkloadsByVersion([]echo.Workload{v1a, v2, unlabeled, v1b})
+	expected := map[string][]echo.Workload{
+		"v1": {v1a, v1b},
+		"v2": {v2},
+		"":   {unlabeled},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+}
+
+type versionedWorkload struct {
+	echo.Workload
+	version string
+}
+
+func (w *versionedWorkload) Version() string {
+	return w.version
+}
@@ -16,10 +16,14 @@ package echo
 
 import (
 	"fmt"
+	"time"
 
+	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/test/framework/components/galley"
 	"istio.io/istio/pkg/test/framework/components/namespace"
 	"istio.io/istio/pkg/test/framework/components/pilot"
+
+	kubeCore "k8s.io/api/core/v1"
 )
 
 // Config defines the options for creating an Echo component.
@@ -28,12 +32,23 @@ type Config struct {
 	// Namespace of the echo Instance. If not provided, a default namespace "apps" is used.
 	Namespace namespace.Instance
 
-	// Domain of the echo Instance. If not provided, a default will be selected.
+	// Cluster name reported by the echo application in its responses (see ParsedResponse.Cluster),
+	// e.g. to verify which cluster served a request. If not provided, no cluster is reported.
+	Cluster string
+
+	// Domain of the echo Instance, appended to the FQDN of the service (e.g. "svc.cluster.internal"
+	// for clusters with a custom cluster domain). Must not start or end with a dot. If not provided,
+	// the default of the environment is used (on Kubernetes, "svc.cluster.local").
 	Domain string
 
 	// Galley component (may be required, depending on the environment/configuration).
 	Galley galley.Instance
 
+	// RequireGalley (k8s only), if set, fails the creation of the Instance if Galley isn't provided.
+	// Galley isn't used in k8s currently, but will be once Pilot gets all resources from Galley, so
+	// this allows tests to verify that they are forward-compatible. Otherwise, a warning is logged.
+	RequireGalley bool
+
 	// Pilot component reference (may be required, depending on the environment/configuration).
 	Pilot pilot.Instance
 
@@ -46,19 +61,305 @@ type Config struct {
 	// Locality (k8s only) indicates the locality of the deployed app.
 	Locality string
 
-	// Headless (k8s only) indicates that no ClusterIP should be specified.
+	// Headless (k8s only) indicates that no ClusterIP should be specified. Equivalent to a
+	// ServiceType of ServiceTypeHeadless.
 	Headless bool
 
-	// Sidecar indicates that no Envoy sidecar should be created for the instance.
+	// ServiceType (k8s only) of the Service for the echo app. If not provided, ServiceTypeHeadless
+	// is used if Headless is set, and ServiceTypeClusterIP otherwise.
+	ServiceType ServiceType
+
+	// Sidecar indicates that an Envoy sidecar should be injected into the workloads. Otherwise (k8s),
+	// injection is disabled for the pods, so that they are outside of the mesh even in namespaces
+	// with automatic injection, and the workloads have no Sidecar.
 	Sidecar bool
 
 	// ServiceAccount (k8s only) indicates that a service account should be created
 	// for the deployment.
 	ServiceAccount bool
 
+	// TrustDomain expected in the SPIFFE identity of the workloads, as configured for the mesh (e.g.
+	// via the trustDomain of the mesh config). This does not change the identity of the workloads.
+	// If not provided, "cluster.local" is used.
+	TrustDomain string
+
 	// Ports for this application. Port numbers may or may not be used, depending
 	// on the implementation.
 	Ports []Port
+
+	// ReadinessGRPCPort (k8s only) is the name of a gRPC port in Ports on which readiness is checked
+	// via the gRPC health service. If not provided, a separate HTTP readiness port is used.
+	ReadinessGRPCPort string
+
+	// ReadinessPort (k8s only) is the number of the HTTP port on which readiness is checked, unless
+	// ReadinessGRPCPort is provided. If not provided, 8080 is used.
+	ReadinessPort int
+
+	// HealthPort (k8s only) is the number of the TCP port on which liveness is checked. If not
+	// provided, 3333 is used.
+	HealthPort int
+
+	// HealthCheckProtocol (k8s only) of the readiness and liveness probes: model.ProtocolTCP
+	// (tcpSocket probes) or model.ProtocolHTTP (httpGet probes). If not provided, readiness is
+	// checked via HTTP and liveness via TCP. Readiness via ReadinessGRPCPort takes precedence.
+	HealthCheckProtocol model.Protocol
+
+	// VolumeClaim (k8s only), if provided, causes a PersistentVolumeClaim to be created and mounted
+	// into the echo application container. The claim is deleted when the Instance is closed.
+	VolumeClaim *VolumeClaim
+
+	// BootstrapOverride (k8s only) is the name of a ConfigMap in the echo Namespace containing a
+	// custom Envoy bootstrap for the sidecar. The ConfigMap must already exist. Requires Sidecar.
+	BootstrapOverride string
+
+	// SidecarVolumes (k8s only) are mounted into the sidecar container (rather than the application),
+	// e.g. to provide a custom CA to the proxy or Lua scripts for an EnvoyFilter. The referenced
+	// Secrets and ConfigMaps must already exist in the echo Namespace. Requires Sidecar.
+	SidecarVolumes []SidecarVolume
+
+	// CertSource (k8s only) selects how certificates are provided to the instance. If not provided,
+	// CertSourceSDS is used.
+	CertSource CertSource
+
+	// CertSecret (k8s only) is the name of a Secret in the echo Namespace containing cert-chain.pem,
+	// key.pem and root-cert.pem. Required for, and only allowed with, CertSourceFile.
+	CertSecret string
+
+	// StartupDelay before the echo application starts listening on its ports, to simulate a slow
+	// starting application. For k8s, the delay should be shorter than the time allowed by the
+	// liveness probe (~100 seconds).
+	StartupDelay time.Duration
+
+	// PathStatuses are the status codes returned by the HTTP ports of the echo application for
+	// requests to the given paths (e.g. {"/error": 500}), rather than 200. A "codes" query parameter
+	// in the request takes precedence.
+	PathStatuses map[string]int
+
+	// NetworkAttachments (k8s only) are secondary networks attached to the echo pods via Multus, in
+	// the form [namespace/]name[@interface]. Requires Multus CNI, and the referenced
+	// NetworkAttachmentDefinitions, to be installed in the cluster. The IPs of the secondary
+	// interfaces are reported by Workload.SecondaryAddresses.
+	NetworkAttachments []string
+
+	// Gateway (k8s only) deploys a gateway instead of the echo app: istio-proxy runs as a router in
+	// the main container, serving the Ports as configured by Gateway resources. Gateway resources
+	// select the pods via the label "istio: <Service>" and use the InstancePorts as server ports.
+	// The workloads of a gateway can be inspected (e.g. via their Sidecar), but can't make calls.
+	// Sidecar is ignored.
+	Gateway bool
+
+	// ReadinessDependencies are Instances that must be ready before this Instance is considered
+	// ready. WaitUntilReady first waits for each dependency (and, in turn, its dependencies) to be
+	// ready, and then for the outbound configuration for each dependency to be received by the
+	// sidecar of this Instance. Circular dependencies are reported as errors by WaitUntilReady.
+	ReadinessDependencies []Instance
+
+	// SchedulerName (k8s only) of the scheduler for the echo pods. If empty, the default scheduler is used.
+	SchedulerName string
+
+	// Image (k8s only) of the echo application container, e.g. a locally-built echo app or one from a
+	// private registry. If empty, the app image of the hub and tag from the command line is used.
+	Image string
+
+	// ImagePullPolicy (k8s only) of the echo application container: "Always", "IfNotPresent" or
+	// "Never". If empty, the pull policy from the command line is used.
+	ImagePullPolicy string
+
+	// ImagePullSecrets (k8s only) are the names of Secrets in the echo Namespace used to pull the
+	// Image. Requires a custom Image.
+	ImagePullSecrets []string
+
+	// RunAsUser (k8s only) is the user ID of the echo application container. If nil, the user of the
+	// image is used.
+	RunAsUser *int64
+
+	// FSGroup (k8s only) is the securityContext.fsGroup of the echo pods, which is applied to mounted
+	// volumes (e.g. the CertSecret or VolumeClaim) so that they are readable by a non-root RunAsUser.
+	// Omitted if nil.
+	FSGroup *int64
+
+	// ExtendedResources (k8s only) requested by the echo application container, by resource name
+	// (e.g. "example.com/device": "1"), so that the echo pods are scheduled onto nodes advertising
+	// the resources. Each quantity is used as both the request and the limit.
+	ExtendedResources map[string]string
+
+	// Resources (k8s only) requested by the echo application container (e.g. CPU and memory), to
+	// control the scheduling and the QoS class of the echo pods. Limits must not be smaller than the
+	// corresponding requests. If empty, no resources are requested (other than ExtendedResources).
+	Resources kubeCore.ResourceRequirements
+
+	// Replicas (k8s only) of the echo Deployment. If not provided, a single replica is deployed.
+	Replicas int
+
+	// DeploymentKind (k8s only) of the workload resource for the echo pods. If not provided,
+	// DeploymentKindDeployment is used.
+	DeploymentKind DeploymentKind
+
+	// DNSCapture (k8s only), if set, enables or disables the proxy's capture of DNS requests (i.e. DNS
+	// proxying) for the echo pods, via the DNS_CAPTURE proxy metadata (as set by
+	// ISTIO_META_DNS_CAPTURE). If not set, the mesh setting applies. Requires Sidecar.
+	DNSCapture *bool
+
+	// ProxyResourceProfile (k8s only) selects the resources requested for the sidecar proxy. If not
+	// provided, the injector's defaults are used (unless ProxyResources is set). Requires Sidecar.
+	ProxyResourceProfile ProxyResourceProfile
+
+	// ProxyResources (k8s only) overrides the resources requested for the sidecar proxy by the
+	// ProxyResourceProfile. Fields that are empty are taken from the profile.
+	ProxyResources *ProxyResources
+
+	// PodAnnotations (k8s only) are added to the echo pods. Annotations set by the framework (e.g.
+	// for the sidecar) take precedence.
+	PodAnnotations map[string]string
+
+	// PodLabels (k8s only) are added to the echo pods. Labels set by the framework (e.g. app and
+	// version) take precedence.
+	PodLabels map[string]string
+}
+
+// ProxyResourceProfile is a built-in set of proxy resource requests.
+type ProxyResourceProfile string
+
+const (
+	// ProxyResourceProfileSmall is suited to small topologies.
+	ProxyResourceProfileSmall ProxyResourceProfile = "small"
+
+	// ProxyResourceProfileMedium is suited to topologies of a few dozen workloads.
+	ProxyResourceProfileMedium ProxyResourceProfile = "medium"
+
+	// ProxyResourceProfileLarge is suited to large (e.g. performance test) topologies.
+	ProxyResourceProfileLarge ProxyResourceProfile = "large"
+
+	// ProxyResourceProfileAuto selects the small, medium or large profile based on the Replicas.
+	ProxyResourceProfileAuto ProxyResourceProfile = "auto"
+)
+
+var proxyResourceProfiles = map[ProxyResourceProfile]ProxyResources{
+	ProxyResourceProfileSmall:  {CPU: "10m", Memory: "40Mi"},
+	ProxyResourceProfileMedium: {CPU: "100m", Memory: "128Mi"},
+	ProxyResourceProfileLarge:  {CPU: "500m", Memory: "512Mi"},
+}
+
+// ProxyResources requested for the sidecar proxy, in Kubernetes quantities (e.g. "100m", "128Mi").
+type ProxyResources struct {
+	CPU    string
+	Memory string
+}
+
+// EffectiveProxyResources returns the proxy resources for the configuration, derived from the
+// ProxyResourceProfile and ProxyResources. Returns nil if neither is set.
+func (c Config) EffectiveProxyResources() (*ProxyResources, error) {
+	profile := c.ProxyResourceProfile
+	if profile == ProxyResourceProfileAuto {
+		switch {
+		case c.Replicas < 5:
+			profile = ProxyResourceProfileSmall
+		case c.Replicas < 20:
+			profile = ProxyResourceProfileMedium
+		default:
+			profile = ProxyResourceProfileLarge
+		}
+	}
+
+	var out ProxyResources
+	if profile != "" {
+		var ok bool
+		if out, ok = proxyResourceProfiles[profile]; !ok {
+			return nil, fmt.Errorf("unsupported proxy resource profile %q for service %s", profile, c.Service)
+		}
+	} else if c.ProxyResources == nil {
+		return nil, nil
+	}
+
+	if c.ProxyResources != nil {
+		if c.ProxyResources.CPU != "" {
+			out.CPU = c.ProxyResources.CPU
+		}
+		if c.ProxyResources.Memory != "" {
+			out.Memory = c.ProxyResources.Memory
+		}
+	}
+	return &out, nil
+}
+
+// ServiceType of the Service for an echo Instance.
+type ServiceType string
+
+const (
+	// ServiceTypeClusterIP exposes the Instance on a cluster-internal IP.
+	ServiceTypeClusterIP ServiceType = "ClusterIP"
+
+	// ServiceTypeNodePort additionally exposes the Instance on a port of each node. The allocated
+	// ports are available via the NodePort of the Ports.
+	ServiceTypeNodePort ServiceType = "NodePort"
+
+	// ServiceTypeLoadBalancer additionally exposes the Instance via an external load balancer, whose
+	// address is available via AddressOf(AddressTypeExternal).
+	ServiceTypeLoadBalancer ServiceType = "LoadBalancer"
+
+	// ServiceTypeHeadless exposes the Instance without a ClusterIP, i.e. via the addresses of the
+	// workloads. Equivalent to setting Headless.
+	ServiceTypeHeadless ServiceType = "Headless"
+)
+
+// DeploymentKind is the kind of k8s resource that deploys the pods of an echo Instance.
+type DeploymentKind string
+
+const (
+	// DeploymentKindDeployment deploys the pods via a Deployment.
+	DeploymentKindDeployment DeploymentKind = "Deployment"
+
+	// DeploymentKindStatefulSet deploys the pods via a StatefulSet, which gives them stable names
+	// (e.g. "<Service>-<Version>-0") and per-pod DNS names (see Workload.PodFQDN). The Service is
+	// made headless, as required to govern the StatefulSet, so the ServiceType must be ClusterIP or
+	// Headless. Workloads are ordered by the ordinal of their pod. Restart and Scale aren't supported.
+	DeploymentKindStatefulSet DeploymentKind = "StatefulSet"
+)
+
+// CertSource indicates how certificates are provided to an echo Instance.
+type CertSource string
+
+const (
+	// CertSourceSDS uses the standard path, where the sidecar obtains its certificates via SDS (or
+	// from the Citadel-provided secret, if SDS is disabled for the mesh).
+	CertSourceSDS CertSource = "sds"
+
+	// CertSourceFile mounts the certificates in CertSecret as files at /etc/certs/custom, into the
+	// application container and (with Sidecar) the sidecar, e.g. for the client certificate of a
+	// DestinationRule. The sidecar still obtains its workload certificates via the standard path.
+	CertSourceFile CertSource = "file"
+)
+
+// VolumeClaim defines a persistent volume to be claimed and mounted by an Echo Instance.
+type VolumeClaim struct {
+	// Size of the volume (e.g. "1Gi"). If not provided, a default will be selected.
+	Size string
+
+	// StorageClass used to provision the volume. If provided, the storage class must already
+	// exist in the cluster. If not provided, the cluster's default storage class is used.
+	StorageClass string
+
+	// AccessMode of the volume (e.g. "ReadWriteOnce"). If not provided, ReadWriteOnce is used.
+	AccessMode string
+
+	// MountPath of the volume within the application container. If not provided, "/data" is used.
+	MountPath string
+}
+
+// SidecarVolume defines a Secret or ConfigMap to be mounted into the sidecar of an Echo Instance.
+type SidecarVolume struct {
+	// Name of the volume, which must be unique within the pod.
+	Name string
+
+	// Secret in the echo Namespace providing the contents of the volume. Exactly one of Secret or
+	// ConfigMap must be provided.
+	Secret string
+
+	// ConfigMap in the echo Namespace providing the contents of the volume.
+	ConfigMap string
+
+	// MountPath of the volume within the sidecar container. The volume is mounted read-only.
+	MountPath string
 }
 
 // String implements the Configuration interface (which implements fmt.Stringer)
@@ -66,6 +367,29 @@ func (c Config) String() string {
 	return fmt.Sprint("{service: ", c.Service, ", version: ", c.Version, "}")
 }
 
+// Principal returns the SPIFFE identity expected for the workloads of the service, e.g. as the source
+// principal reported via ParsedResponse.SourcePrincipal for calls made by the service.
+func (c Config) Principal() string {
+	serviceAccount := "default"
+	if c.ServiceAccount {
+		serviceAccount = c.Service
+	}
+	ns := ""
+	if c.Namespace != nil {
+		ns = c.Namespace.Name()
+	}
+	return fmt.Sprintf("spiffe://%s/ns/%s/sa/%s", c.TrustDomain, ns, serviceAccount)
+}
+
+// NamespacedName returns the name of the service, qualified with its namespace (if any), e.g.
+// "namespace/service".
+func (c Config) NamespacedName() string {
+	if c.Namespace == nil {
+		return c.Service
+	}
+	return c.Namespace.Name() + "/" + c.Service
+}
+
 // FQDN returns the fully qualified domain name for the service.
 func (c Config) FQDN() string {
 	out := c.Service
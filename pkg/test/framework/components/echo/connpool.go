@@ -0,0 +1,151 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const (
+	connectionPoolDefaultCount   = 100
+	connectionPoolSampleInterval = 100 * time.Millisecond
+)
+
+// CheckConnectionPoolLimit verifies that the sidecars of source never have more than maxConnections
+// active connections to the port of opts.Target (per outbound cluster, e.g. for each subset), as
+// configured by the connectionPool.tcp.maxConnections of a DestinationRule. opts.Count (defaults
+// to 100) requests are made concurrently, so opts.QPS should not be set. Requests that overflow
+// the connection pool are not considered failures: over HTTP they are answered with a 503 response,
+// and over TCP (or any other non-HTTP protocol) the overflowing connections are reset, so errors of
+// the call are ignored for such ports. The call still fails the check if no connection was observed.
+//
+// The active connections are sampled from the upstream_cx_active gauge of the outbound clusters in
+// the Envoy stats of each source sidecar throughout the call.
+func CheckConnectionPoolLimit(source Instance, opts CallOptions, maxConnections int) error {
+	if opts.Target == nil {
+		return errors.New("checkConnectionPoolLimit: missing Target")
+	}
+	if maxConnections <= 0 {
+		return errors.New("checkConnectionPoolLimit: maxConnections must be > 0")
+	}
+	port := targetPort(opts)
+	if port == nil {
+		return errors.New("checkConnectionPoolLimit: PortName or Port must match a Target port")
+	}
+	if opts.Count <= 0 {
+		opts.Count = connectionPoolDefaultCount
+	}
+
+	workloads, err := source.Workloads()
+	if err != nil {
+		return err
+	}
+	sidecars := make([]Sidecar, 0, len(workloads))
+	for _, w := range workloads {
+		if w.Sidecar() == nil {
+			return fmt.Errorf("checkConnectionPoolLimit: source %s has no sidecar", source.Config().Service)
+		}
+		sidecars = append(sidecars, w.Sidecar())
+	}
+
+	// Sample the active connections in the background until the call completes.
+	var (
+		peak      int64
+		peakName  string
+		sampleErr error
+		wg        sync.WaitGroup
+	)
+	host := opts.Target.Config().FQDN()
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(connectionPoolSampleInterval)
+		defer ticker.Stop()
+		for {
+			for _, s := range sidecars {
+				stats, err := s.Stats()
+				if err != nil {
+					sampleErr = multierror.Append(sampleErr, err)
+					continue
+				}
+				for name, value := range stats {
+					if isActiveConnectionsGauge(name, port.ServicePort, host) && value > peak {
+						peak, peakName = value, s.NodeID()+": "+name
+					}
+				}
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	// The overflowing requests are expected to fail, and must not be retried.
+	opts.Retry.Disabled = true
+	_, callErr := source.Call(opts)
+	close(stop)
+	wg.Wait()
+
+	if callErr != nil && port.Protocol.IsHTTP() {
+		return callErr
+	}
+	if sampleErr != nil {
+		return fmt.Errorf("failed sampling the active connections: %v", sampleErr)
+	}
+	if peak == 0 {
+		err := fmt.Errorf("no active connections from %s to %s:%d observed", source.Config().Service, host,
+			port.ServicePort)
+		if callErr != nil {
+			return multierror.Append(err, callErr)
+		}
+		return err
+	}
+	if peak > int64(maxConnections) {
+		return fmt.Errorf("connections from %s to %s:%d exceeded the limit of %d: %s=%d",
+			source.Config().Service, host, port.ServicePort, maxConnections, peakName, peak)
+	}
+	return nil
+}
+
+// CheckConnectionPoolLimitOrFail calls CheckConnectionPoolLimit and fails t if an error occurs.
+func CheckConnectionPoolLimitOrFail(t testing.TB, source Instance, opts CallOptions, maxConnections int) {
+	if err := CheckConnectionPoolLimit(source, opts, maxConnections); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// isActiveConnectionsGauge indicates whether the stat is the upstream_cx_active gauge of an outbound
+// cluster (e.g. "cluster.outbound|80|v1|b.ns.svc.cluster.local.upstream_cx_active") for the port of
+// the host.
+func isActiveConnectionsGauge(stat string, port int, host string) bool {
+	const prefix, suffix = "cluster.", ".upstream_cx_active"
+	if !strings.HasPrefix(stat, prefix) || !strings.HasSuffix(stat, suffix) {
+		return false
+	}
+	parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(stat, prefix), suffix), "|")
+	return len(parts) == 4 && parts[0] == "outbound" && parts[1] == strconv.Itoa(port) && parts[3] == host
+}
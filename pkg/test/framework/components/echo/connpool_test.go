@@ -0,0 +1,128 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/test/echo/client"
+)
+
+const connectionPoolGauge = "cluster.outbound|80||b.ns.upstream_cx_active"
+
+// connectionPoolSidecar reports the active connections to port 80 of service b, along with those
+// of an unrelated cluster.
+type connectionPoolSidecar struct {
+	Sidecar
+
+	mutex  sync.Mutex
+	active int64
+}
+
+func (s *connectionPoolSidecar) NodeID() string {
+	return "sidecar~10.0.0.1~a-v1-0.ns~ns.svc.cluster.local"
+}
+
+func (s *connectionPoolSidecar) Stats() (map[string]int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return map[string]int64{
+		connectionPoolGauge:                              s.active,
+		"cluster.outbound|80||c.ns.upstream_cx_active":   100,
+		"cluster.outbound|80||b.ns.upstream_cx_overflow": 100,
+	}, nil
+}
+
+func (s *connectionPoolSidecar) setActive(active int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.active = active
+}
+
+// connectionPoolSource holds the given number of active connections for the duration of each call,
+// which then returns callErr.
+type connectionPoolSource struct {
+	Instance
+	sidecar *connectionPoolSidecar
+	active  int64
+	callErr error
+}
+
+func (s *connectionPoolSource) Config() Config {
+	return Config{Service: "a"}
+}
+
+func (s *connectionPoolSource) Workloads() ([]Workload, error) {
+	return []Workload{&fakeWorkload{sidecar: s.sidecar}}, nil
+}
+
+func (s *connectionPoolSource) Call(opts CallOptions) (client.ParsedResponses, error) {
+	if !opts.Retry.Disabled {
+		return nil, errors.New("overflowing requests must not be retried")
+	}
+	s.sidecar.setActive(s.active)
+	time.Sleep(3 * connectionPoolSampleInterval)
+	s.sidecar.setActive(0)
+	return nil, s.callErr
+}
+
+func TestCheckConnectionPoolLimit(t *testing.T) {
+	target := &fakeInstance{cfg: Config{
+		Service:   "b",
+		Namespace: fakeNamespace("ns"),
+		Ports: []Port{
+			{Name: "http", Protocol: model.ProtocolHTTP, ServicePort: 80},
+			{Name: "tcp", Protocol: model.ProtocolTCP, ServicePort: 80},
+		},
+	}}
+	overflow := errors.New("connection reset by peer")
+
+	cases := []struct {
+		name     string
+		portName string
+		active   int64
+		callErr  error
+		err      string
+	}{
+		{name: "within limit", portName: "http", active: 2},
+		{name: "exceeded", portName: "http", active: 3, err: connectionPoolGauge + "=3"},
+		{name: "no connections", portName: "http", err: "no active connections"},
+		{name: "http call failed", portName: "http", active: 2, callErr: overflow, err: "connection reset"},
+		// Overflowing TCP connections are reset, failing the call.
+		{name: "tcp overflow", portName: "tcp", active: 2, callErr: overflow},
+		{name: "tcp no connections", portName: "tcp", callErr: overflow, err: "connection reset"},
+		{name: "unknown port", portName: "grpc", err: "must match a Target port"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			source := &connectionPoolSource{sidecar: &connectionPoolSidecar{}, active: c.active, callErr: c.callErr}
+			err := CheckConnectionPoolLimit(source, CallOptions{Target: target, PortName: c.portName}, 2)
+			if c.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("expected error containing %q, got: %v", c.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// ResolveHost resolves the host from within the workload (via Exec, k8s only), returning the sorted,
+// unique addresses. If DNS capture is enabled for the workload, the lookup is answered by the
+// proxy's DNS proxy.
+func ResolveHost(w Workload, host string) ([]string, error) {
+	stdout, _, err := w.Exec([]string{"getent", "ahosts", host})
+	if err != nil {
+		return nil, fmt.Errorf("failed resolving %s: %v", host, err)
+	}
+
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && net.ParseIP(fields[0]) != nil {
+			seen[fields[0]] = true
+		}
+	}
+
+	out := make([]string, 0, len(seen))
+	for addr := range seen {
+		out = append(out, addr)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// CheckDNSResolution verifies that the host resolves to (at least) the expected addresses from
+// within every workload of source. Resolving to an address that is only known to the mesh (e.g. the
+// address of a ServiceEntry) verifies that the lookup was answered by the DNS proxy.
+func CheckDNSResolution(source Instance, host string, expected ...string) error {
+	workloads, err := source.Workloads()
+	if err != nil {
+		return err
+	}
+
+	for _, w := range workloads {
+		resolved, err := ResolveHost(w, host)
+		if err != nil {
+			return err
+		}
+		for _, addr := range expected {
+			i := sort.SearchStrings(resolved, addr)
+			if i == len(resolved) || resolved[i] != addr {
+				return fmt.Errorf("host %s resolved to %v by workload %s of %s, expected to include %s",
+					host, resolved, w.Hostname(), source.Config().Service, addr)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckDNSResolutionOrFail calls CheckDNSResolution and fails t if an error occurs.
+func CheckDNSResolutionOrFail(t testing.TB, source Instance, host string, expected ...string) {
+	if err := CheckDNSResolution(source, host, expected...); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// dnsWorkload resolves hosts via getent, with the addresses of each host.
+type dnsWorkload struct {
+	Workload
+	hosts map[string][]string
+}
+
+func (w *dnsWorkload) Hostname() string {
+	return "a-v1-0"
+}
+
+func (w *dnsWorkload) Exec(command []string) (string, string, error) {
+	if len(command) != 3 || command[0] != "getent" || command[1] != "ahosts" {
+		return "", "", fmt.Errorf("unexpected command %v", command)
+	}
+	addrs, ok := w.hosts[command[2]]
+	if !ok {
+		return "", "", fmt.Errorf("command terminated with exit code 2")
+	}
+	var out strings.Builder
+	for _, addr := range addrs {
+		// Each address is listed for each socket type.
+		fmt.Fprintf(&out, "%s       STREAM %s\n%s       DGRAM\n%s       RAW\n", addr, command[2], addr, addr)
+	}
+	return out.String(), "", nil
+}
+
+// dnsSource is an Instance with a single dnsWorkload.
+type dnsSource struct {
+	Instance
+	workload *dnsWorkload
+}
+
+func (s *dnsSource) Config() Config {
+	return Config{Service: "a"}
+}
+
+func (s *dnsSource) Workloads() ([]Workload, error) {
+	return []Workload{s.workload}, nil
+}
+
+func TestResolveHost(t *testing.T) {
+	w := &dnsWorkload{hosts: map[string][]string{"example.com": {"240.240.0.2", "240.240.0.1", "2001:db8::1"}}}
+
+	resolved, err := ResolveHost(w, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"2001:db8::1", "240.240.0.1", "240.240.0.2"}; !reflect.DeepEqual(resolved, expected) {
+		t.Fatalf("expected %v, got %v", expected, resolved)
+	}
+
+	if _, err := ResolveHost(w, "unknown.com"); err == nil || !strings.Contains(err.Error(), "failed resolving unknown.com") {
+		t.Fatalf("expected failure resolving unknown host, got %v", err)
+	}
+}
+
+func TestCheckDNSResolution(t *testing.T) {
+	source := &dnsSource{workload: &dnsWorkload{hosts: map[string][]string{"example.com": {"240.240.0.1", "240.240.0.2"}}}}
+
+	cases := []struct {
+		name     string
+		host     string
+		expected []string
+		err      string
+	}{
+		{name: "all addresses", host: "example.com", expected: []string{"240.240.0.2", "240.240.0.1"}},
+		{name: "some addresses", host: "example.com", expected: []string{"240.240.0.1"}},
+		{name: "other address", host: "example.com", expected: []string{"10.0.0.1"},
+			err: "host example.com resolved to [240.240.0.1 240.240.0.2] by workload a-v1-0 of a, expected to include 10.0.0.1"},
+		{name: "not resolved", host: "unknown.com", expected: []string{"240.240.0.1"}, err: "failed resolving unknown.com"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := CheckDNSResolution(source, c.host, c.expected...)
+			if c.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("expected error containing %q, got: %v", c.err, err)
+			}
+		})
+	}
+}
@@ -15,7 +15,13 @@
 package echo
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	envoyAdmin "github.com/envoyproxy/go-control-plane/envoy/admin/v2alpha"
 
@@ -32,9 +38,28 @@ type Instance interface {
 	// Config returns the configuration of the Echo instance.
 	Config() Config
 
-	// Address of the service (e.g. Kubernetes cluster IP). May be "" if headless.
+	// String returns a readable identifier of the Echo instance for test failures, i.e.
+	// "namespace/service@version".
+	String() string
+
+	// Equals returns true if other is the same Echo instance, based on the resource ID.
+	Equals(other Instance) bool
+
+	// Address of the service (e.g. Kubernetes cluster IP). May be "" if headless. An IPv6 address is
+	// not enclosed in brackets, so use net.JoinHostPort to combine it with a port.
 	Address() string
 
+	// AddressOf returns the address of the service of the given type. May be "" if the service has
+	// no such address (e.g. an external address, unless the ServiceType is LoadBalancer). Only the
+	// single (primary) cluster IP of the service is known, so on a dual-stack service only one of
+	// AddressTypeClusterIPv4 and AddressTypeClusterIPv6 is available.
+	AddressOf(addressType AddressType) string
+
+	// Addresses at which each (exposed) port of the service can be reached, i.e. the cluster IP or,
+	// for headless services, the address of each workload.
+	Addresses() ([]ServiceAddress, error)
+	AddressesOrFail(t testing.TB) []ServiceAddress
+
 	// WaitUntilReady waits until this instance is up and ready to receive traffic. If
 	// outbound are specified, the wait also includes readiness for each
 	// outbound instance as well as waiting for receipt of outbound Envoy configuration
@@ -43,14 +68,162 @@ type Instance interface {
 	WaitUntilReady(outbound ...Instance) error
 	WaitUntilReadyOrFail(t testing.TB, outbound ...Instance)
 
+	// WaitUntilReadyWithin is like WaitUntilReady, but fails if this instance isn't ready within the
+	// timeout. The error indicates whether the wait for the endpoints or the outbound config failed.
+	WaitUntilReadyWithin(timeout time.Duration, outbound ...Instance) error
+
+	// IsReady is like WaitUntilReady, but checks the current state once rather than waiting, e.g. to
+	// verify that outbound config was not pushed to the sidecars. Returns false if this instance (or
+	// any outbound instance, or ReadinessDependency) isn't ready yet, or a sidecar isn't configured.
+	IsReady(outbound ...Instance) (bool, error)
+
+	// WaitUntilNotReady waits until the pods of this instance's version are no ready endpoints of the
+	// service (k8s only), e.g. after scaling to zero replicas, and fails if they're still ready after
+	// the timeout. The workloads are
+	// then re-discovered by the next WaitUntilReady. Calls that are rejected by the proxies (e.g.
+	// due to an AuthorizationPolicy) don't affect readiness, so have to be checked via Call.
+	WaitUntilNotReady(timeout time.Duration) error
+
 	// Workloads retrieves the list of all deployed workloads for this Echo service.
-	// Guarantees at least one workload, if error == nil.
+	// Guarantees at least one workload, if error == nil, unless scaled to zero replicas.
 	Workloads() ([]Workload, error)
 	WorkloadsOrFail(t testing.TB) []Workload
 
+	// WorkloadsByVersion groups the workloads of Workloads() by their Version, e.g. to verify the
+	// routing to the subsets of a DestinationRule when several versions share the same service.
+	// Workloads without a version are grouped under "".
+	WorkloadsByVersion() (map[string][]Workload, error)
+	WorkloadsByVersionOrFail(t testing.TB) map[string][]Workload
+
+	// Sidecars retrieves the sidecars of all deployed workloads for this Echo service, e.g. to inspect
+	// their Envoy config dumps. Workloads without a sidecar are skipped.
+	Sidecars() ([]Sidecar, error)
+	SidecarsOrFail(t testing.TB) []Sidecar
+
 	// Call makes a call from this Instance to a target Instance.
 	Call(options CallOptions) (client.ParsedResponses, error)
 	CallOrFail(t testing.TB, options CallOptions) client.ParsedResponses
+
+	// CallContext is like Call, but the wait for readiness and the call itself are abandoned once
+	// the context is done.
+	CallContext(ctx context.Context, options CallOptions) (client.ParsedResponses, error)
+
+	// CallWithResult is like Call, but also returns the workload that made the call, and how long
+	// the call took (excluding any wait for readiness).
+	CallWithResult(options CallOptions) (CallResult, error)
+
+	// HealthSummary retrieves a snapshot of the health of the workloads backing this Instance.
+	// Each call fetches the current state, so the result may be refreshed by calling again.
+	HealthSummary() (HealthSummary, error)
+
+	// LabelWorkload sets a label on the workload (e.g. pod) with the given index in Workloads(). If
+	// the label affects the selection of workloads for this Instance, the workloads will be
+	// re-initialized the next time they are needed, and may no longer include the labeled workload.
+	LabelWorkload(index int, key, value string) error
+
+	// Restart (k8s only) replaces all of the workloads of this Instance via a rollout restart, which
+	// is useful to verify the behavior of new proxies (e.g. after an upgrade or a change of config).
+	// Blocks until the new workloads are ready and have received the outbound configuration for the
+	// ReadinessDependencies, after which Workloads() returns the new workloads.
+	Restart() error
+
+	// Scale (k8s only) changes the number of replicas (i.e. workloads) of this Instance. Blocks until
+	// the service endpoints match the new workloads, after which Workloads() returns them. The
+	// Replicas in Config() are updated accordingly.
+	Scale(replicas int) error
+
+	// Delete (k8s only) deletes the deployed resources of this Instance (e.g. the Deployment and
+	// Service), and blocks until its pods and service endpoints are gone, so that a service of the
+	// same name can be deployed again. If other versions of the service are deployed, only the
+	// Deployment (or StatefulSet) of this version is deleted, and the shared Service is kept. The
+	// Instance can't be used afterwards.
+	Delete() error
+
+	// WorkloadLabels retrieves the current labels of the workload with the given index in Workloads().
+	WorkloadLabels(index int) (map[string]string, error)
+
+	// ExecInWorkload runs the command in the echo application container of the workload with the
+	// given index in Workloads(). See Workload.Exec.
+	ExecInWorkload(index int, command []string) (stdout, stderr string, err error)
+
+	// Logs (k8s only) of the named container of all pods of this Instance, each preceded by a header
+	// naming the pod. If container is empty, the logs of the echo application are returned. Use
+	// "istio-proxy" for the logs of the sidecars. Doesn't wait for readiness, so that the logs of pods
+	// that fail to become ready can be retrieved.
+	Logs(container string) (string, error)
+	LogsOrFail(t testing.TB, container string) string
+
+	// DiagnosticBundle writes the state of this Instance (e.g. pod specs, logs, events and Envoy
+	// config dumps and stats) to files in the given directory, to help diagnose test failures.
+	// Collection is best-effort: an error is returned if any state could not be collected.
+	DiagnosticBundle(outDir string) error
+}
+
+// AddressType selects an address of an Instance.
+type AddressType string
+
+const (
+	// AddressTypeCluster is the cluster-internal address of the service, as returned by Address().
+	AddressTypeCluster AddressType = "cluster"
+
+	// AddressTypeExternal is the external address of the service (e.g. the IP or hostname of the
+	// load balancer).
+	AddressTypeExternal AddressType = "external"
+
+	// AddressTypeClusterIPv4 is the cluster-internal address of the service, if it is an IPv4 address.
+	// The address families are selected from the primary cluster IP only: the Kubernetes API in this
+	// tree has no Spec.ClusterIPs, so the secondary address of a dual-stack service is not available.
+	AddressTypeClusterIPv4 AddressType = "cluster-ipv4"
+
+	// AddressTypeClusterIPv6 is the cluster-internal address of the service, if it is an IPv6 address.
+	// Like AddressTypeClusterIPv4, it is only available if it is the primary cluster IP.
+	AddressTypeClusterIPv6 AddressType = "cluster-ipv6"
+)
+
+// ServiceAddress is an address at which a port of an Instance can be reached.
+type ServiceAddress struct {
+	// Port of the service.
+	Port Port
+
+	// Address of the service (e.g. the cluster IP) or, for headless services, of a workload.
+	Address string
+
+	// PortNumber at the Address, i.e. the ServicePort for the service, or the InstancePort for a
+	// workload.
+	PortNumber int
+}
+
+// HostPort returns the address and port number combined as "host:port" (or "[host]:port" for IPv6).
+func (a ServiceAddress) HostPort() string {
+	return net.JoinHostPort(a.Address, strconv.Itoa(a.PortNumber))
+}
+
+// HealthSummary is an aggregate view of the health of the workloads for an Instance.
+type HealthSummary struct {
+	// Replicas is the total number of workloads (e.g. pods) found.
+	Replicas int
+
+	// Ready is the number of workloads that are ready.
+	Ready int
+
+	// NotReady is the number of workloads that are not ready.
+	NotReady int
+
+	// Restarts is the total number of container restarts across all workloads.
+	Restarts int
+
+	// TerminationReasons for the most recent container terminations, if any.
+	TerminationReasons []string
+}
+
+// String implements fmt.Stringer
+func (s HealthSummary) String() string {
+	out := fmt.Sprintf("{replicas: %d, ready: %d, notReady: %d, restarts: %d",
+		s.Replicas, s.Ready, s.NotReady, s.Restarts)
+	if len(s.TerminationReasons) > 0 {
+		out += ", terminations: [" + strings.Join(s.TerminationReasons, "; ") + "]"
+	}
+	return out + "}"
 }
 
 // Port exposed by an Echo Instance
@@ -69,13 +242,82 @@ type Port struct {
 	// InstancePort number where this instance is listening for connections.
 	// This need not be the same as the ServicePort where the service is accessed.
 	InstancePort int
+
+	// NodePort (k8s only) allocated for this port on each node, if the ServiceType is NodePort or
+	// LoadBalancer. Set once the Instance has been created.
+	NodePort int
+
+	// MTLSMode expected for this port, as configured by the PeerAuthentication applied by the test.
+	// If set, calls to this port verify that mTLS was used (or the call was rejected) accordingly.
+	MTLSMode MTLSMode
+
+	// Unexposed ports (k8s only) are listened on by the echo container, but aren't exposed by the
+	// Service, e.g. to verify that calls to the port through the mesh fail. The gRPC port used to
+	// control the echo application must be exposed.
+	Unexposed bool
+
+	// ControlPort marks the gRPC port used to control the echo application, if the configuration has
+	// several gRPC ports. At most one port may be marked. If none is, the first gRPC port is used.
+	ControlPort bool
 }
 
+// MTLSMode is the mTLS mode of a port, as configured via PeerAuthentication.
+type MTLSMode string
+
+const (
+	// MTLSModeStrict only accepts mTLS traffic.
+	MTLSModeStrict MTLSMode = "STRICT"
+
+	// MTLSModePermissive accepts both mTLS and plaintext traffic.
+	MTLSModePermissive MTLSMode = "PERMISSIVE"
+
+	// MTLSModeDisable only accepts plaintext traffic.
+	MTLSModeDisable MTLSMode = "DISABLE"
+)
+
 // Workload provides an interface for a single deployed echo server.
 type Workload interface {
 	// Address returns the network address of the endpoint.
 	Address() string
 
+	// SecondaryAddresses of the workload on any attached secondary networks (k8s only).
+	SecondaryAddresses() []string
+
+	// Hostname reported by this workload in its responses (e.g. the pod name).
+	Hostname() string
+
+	// PodName of this workload (k8s only). Empty if the workload doesn't correspond to a pod.
+	PodName() string
+
+	// NodeName of the node on which this workload runs (k8s only). Empty if not known.
+	NodeName() string
+
+	// Version of this workload, i.e. the "version" label of its pod in k8s, or the Version of the
+	// Config otherwise. Empty if the pod has no version label.
+	Version() string
+
+	// PodFQDN returns the fully qualified per-pod DNS name of this workload within a headless service
+	// (e.g. "10-0-0-1.service.namespace.svc.cluster.local"). Empty if the workload is not addressable
+	// individually.
+	PodFQDN() string
+
+	// RequestCount returns the number of requests received by this workload that carried the
+	// given value for the common.RequestCountHeader header.
+	RequestCount(key string) (int, error)
+
+	// Exec runs the command (without a shell) in the echo application container of this workload,
+	// rather than the sidecar (k8s only). For gateways, the command runs in the proxy container.
+	Exec(command []string) (stdout, stderr string, err error)
+
+	// ForwardPort forwards a local port to the given port of the pod of this workload (k8s only), e.g.
+	// to reach the app directly with a protocol the echo client doesn't speak. The returned close
+	// func stops the forward. Any forwards that are still open are closed with the Instance.
+	ForwardPort(remote uint16) (local uint16, close func(), err error)
+
+	// Logs of the named container of this workload (k8s only). If container is empty, the logs of
+	// the echo application (or, for gateways, the proxy) are returned.
+	Logs(container string) (string, error)
+
 	// Sidecar if one was specified.
 	Sidecar() Sidecar
 }
@@ -85,6 +327,12 @@ type Sidecar interface {
 	// NodeID returns the node ID used for uniquely identifying this sidecar to Pilot.
 	NodeID() string
 
+	// Logs of the proxy (e.g. the access logs, if written to stdout). Not supported in all environments.
+	Logs() (string, error)
+
+	// ProxyVersion returns the version of the Istio build of the proxy (e.g. "1.3.0").
+	ProxyVersion() (string, error)
+
 	// Info about the Envoy instance.
 	Info() (*envoyAdmin.ServerInfo, error)
 	InfoOrFail(t testing.TB) *envoyAdmin.ServerInfo
@@ -93,9 +341,23 @@ type Sidecar interface {
 	Config() (*envoyAdmin.ConfigDump, error)
 	ConfigOrFail(t testing.TB) *envoyAdmin.ConfigDump
 
+	// Clusters returns the status of the Envoy clusters, including their hosts (i.e. the endpoints
+	// resolved via EDS or DNS).
+	Clusters() (*envoyAdmin.Clusters, error)
+	ClustersOrFail(t testing.TB) *envoyAdmin.Clusters
+
+	// Stats of the Envoy instance (counters and gauges), by name.
+	Stats() (map[string]int64, error)
+	StatsOrFail(t testing.TB) map[string]int64
+
 	// WaitForConfig queries the Envoy configuration an executes the given accept handler. If the
 	// response is not accepted, the request will be retried until either a timeout or a response
 	// has been accepted.
 	WaitForConfig(accept func(*envoyAdmin.ConfigDump) (bool, error), options ...retry.Option) error
 	WaitForConfigOrFail(t testing.TB, accept func(*envoyAdmin.ConfigDump) (bool, error), options ...retry.Option)
+
+	// CheckConfig is like WaitForConfig, but evaluates the current Envoy configuration once. Returns
+	// false if the configuration isn't accepted (or the accept handler returns an error), and an error
+	// only if the configuration couldn't be retrieved.
+	CheckConfig(accept func(*envoyAdmin.ConfigDump) (bool, error)) (bool, error)
 }
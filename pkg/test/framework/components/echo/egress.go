@@ -0,0 +1,86 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// CheckEgressCluster verifies that a call from source used the given Envoy upstream cluster (e.g.
+// "outbound|443||www.example.com") of the source's sidecar. The cluster's upstream request counter
+// (or, for TCP clusters, its connection counter) is compared before and after the call, and must
+// increase by at least opts.Count. Envoy doesn't report the selected cluster in responses, so its
+// stats are used instead.
+func CheckEgressCluster(source Instance, opts CallOptions, expectedCluster string) error {
+	if !source.Config().Sidecar {
+		return errors.New("checkEgressCluster: source has no sidecar")
+	}
+	if opts.Count <= 0 {
+		opts.Count = 1
+	}
+
+	workloads, err := source.Workloads()
+	if err != nil {
+		return err
+	}
+
+	rqBefore, cxBefore, err := clusterUsage(workloads, expectedCluster)
+	if err != nil {
+		return err
+	}
+
+	if _, err := source.Call(opts); err != nil {
+		return err
+	}
+
+	rqAfter, cxAfter, err := clusterUsage(workloads, expectedCluster)
+	if err != nil {
+		return err
+	}
+
+	// TCP clusters don't count requests, so fall back to connections.
+	delta := rqAfter - rqBefore
+	if delta == 0 {
+		delta = cxAfter - cxBefore
+	}
+	if delta < int64(opts.Count) {
+		return fmt.Errorf("expected %d calls via cluster %s from %s, but the cluster was used %d times",
+			opts.Count, expectedCluster, source.Config().Service, delta)
+	}
+	return nil
+}
+
+// CheckEgressClusterOrFail calls CheckEgressCluster and fails t if an error occurs.
+func CheckEgressClusterOrFail(t testing.TB, source Instance, opts CallOptions, expectedCluster string) {
+	if err := CheckEgressCluster(source, opts, expectedCluster); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// clusterUsage returns the total number of upstream requests and connections made via the cluster
+// by the sidecars of the given workloads.
+func clusterUsage(workloads []Workload, cluster string) (requests int64, connections int64, err error) {
+	for _, w := range workloads {
+		stats, err := w.Sidecar().Stats()
+		if err != nil {
+			return 0, 0, err
+		}
+		requests += stats["cluster."+cluster+".upstream_rq_total"]
+		connections += stats["cluster."+cluster+".upstream_cx_total"]
+	}
+	return requests, connections, nil
+}
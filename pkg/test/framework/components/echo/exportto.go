@@ -0,0 +1,94 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	envoyAdmin "github.com/envoyproxy/go-control-plane/envoy/admin/v2alpha"
+
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const exportToTimeout = time.Minute
+
+// CheckExportToVisibility verifies that the target is visible to source (e.g. as restricted by the
+// exportTo of the target's Service or VirtualServices) if and only if expectedVisible is set. The
+// sidecar of every source workload must (or must not) have outbound clusters for the target, and a
+// call to the first HTTP port of the target must succeed (or fail). For a target that isn't
+// visible, the call only fails if the source's sidecar doesn't pass the traffic through (e.g. with
+// the REGISTRY_ONLY outbound traffic policy, or a STRICT mTLS target).
+func CheckExportToVisibility(source, target Instance, expectedVisible bool) error {
+	if !source.Config().Sidecar {
+		return fmt.Errorf("checkExportToVisibility: source %s has no sidecar", source.Config().Service)
+	}
+	port, err := httpPort(target.Config(), "")
+	if err != nil {
+		return err
+	}
+
+	// Visibility changes take effect asynchronously.
+	host := target.Config().FQDN()
+	err = retry.UntilSuccess(func() error {
+		workloads, err := source.Workloads()
+		if err != nil {
+			return err
+		}
+		for _, w := range workloads {
+			clusters, err := w.Sidecar().Clusters()
+			if err != nil {
+				return err
+			}
+			if visible := hasOutboundCluster(clusters, host); visible != expectedVisible {
+				return fmt.Errorf("sidecar %s has outbound clusters for %s: %v, expected %v",
+					w.Sidecar().NodeID(), host, visible, expectedVisible)
+			}
+		}
+		return nil
+	}, retry.Timeout(exportToTimeout), retry.Delay(time.Second))
+	if err != nil {
+		return err
+	}
+
+	err = callOK(source, CallOptions{Target: target, PortName: port.Name})
+	switch {
+	case expectedVisible && err != nil:
+		return fmt.Errorf("%s not reachable from %s, although visible: %v", host, source.Config().Service, err)
+	case !expectedVisible && err == nil:
+		return fmt.Errorf("%s reachable from %s, although not visible", host, source.Config().Service)
+	}
+	return nil
+}
+
+// CheckExportToVisibilityOrFail calls CheckExportToVisibility and fails t if an error occurs.
+func CheckExportToVisibilityOrFail(t testing.TB, source, target Instance, expectedVisible bool) {
+	if err := CheckExportToVisibility(source, target, expectedVisible); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// hasOutboundCluster indicates whether there are any outbound clusters for the host.
+func hasOutboundCluster(clusters *envoyAdmin.Clusters, host string) bool {
+	for _, c := range clusters.ClusterStatuses {
+		parts := strings.Split(c.Name, "|")
+		if len(parts) == 4 && parts[0] == "outbound" && parts[3] == host {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+const (
+	// DefaultFairnessSignificance is a reasonable significance level for CheckFairness.
+	DefaultFairnessSignificance = 0.01
+
+	// fairnessCallsPerWorkload is the number of calls made per target workload when no count is
+	// specified for CheckFairness.
+	fairnessCallsPerWorkload = 50
+)
+
+// FairnessResult is the outcome of a statistical fairness check over the distribution of responses
+// across the workloads of a target Instance.
+type FairnessResult struct {
+	// Counts of responses received from each workload, keyed by hostname.
+	Counts map[string]int
+
+	// Workloads is the number of workloads that were expected to receive traffic.
+	Workloads int
+
+	// Statistic is the computed chi-square statistic.
+	Statistic float64
+
+	// PValue is the probability of observing a distribution at least this uneven if traffic were
+	// distributed uniformly.
+	PValue float64
+
+	// Passed indicates that the uniform distribution hypothesis was not rejected at the requested
+	// significance level.
+	Passed bool
+}
+
+// String implements fmt.Stringer
+func (r FairnessResult) String() string {
+	return fmt.Sprintf("{passed: %v, chiSquare: %.3f, pValue: %.5f, workloads: %d, counts: %v}",
+		r.Passed, r.Statistic, r.PValue, r.Workloads, r.Counts)
+}
+
+// CheckFairness makes calls from the source Instance to the target of the given options and runs a
+// chi-square goodness-of-fit test over the number of responses received from each target workload,
+// against a uniform distribution. The check fails if the distribution is uneven at the given
+// significance level (e.g. 0.01). Workloads that received no traffic at all are accounted for. If
+// opts.Count is not set, a number of calls proportional to the number of target workloads is made.
+func CheckFairness(source Instance, opts CallOptions, significance float64) (FairnessResult, error) {
+	if opts.Target == nil {
+		return FairnessResult{}, errors.New("checkFairness: missing Target")
+	}
+	if significance <= 0 || significance >= 1 {
+		return FairnessResult{}, fmt.Errorf("checkFairness: invalid significance %v", significance)
+	}
+
+	workloads, err := opts.Target.Workloads()
+	if err != nil {
+		return FairnessResult{}, err
+	}
+	if opts.Count <= 0 {
+		opts.Count = fairnessCallsPerWorkload * len(workloads)
+	}
+
+	responses, err := source.Call(opts)
+	if err != nil {
+		return FairnessResult{}, err
+	}
+
+	result := computeFairness(responses.Distribution(), len(workloads), significance)
+	if !result.Passed {
+		return result, fmt.Errorf("unfair distribution of calls from %s to %s: %s",
+			source.Config().Service, opts.Target.Config().Service, result)
+	}
+	return result, nil
+}
+
+// CheckFairnessOrFail calls CheckFairness and fails t if an error occurs.
+func CheckFairnessOrFail(t testing.TB, source Instance, opts CallOptions, significance float64) FairnessResult {
+	result, err := CheckFairness(source, opts, significance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func computeFairness(counts map[string]int, workloads int, significance float64) FairnessResult {
+	result := FairnessResult{
+		Counts:    counts,
+		Workloads: workloads,
+	}
+
+	// Responses may have come from more hosts than expected (e.g. a pod was replaced).
+	if len(counts) > workloads {
+		workloads = len(counts)
+		result.Workloads = workloads
+	}
+
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	if total == 0 || workloads < 2 {
+		// Nothing to compare.
+		result.PValue = 1
+		result.Passed = total > 0
+		return result
+	}
+
+	expected := float64(total) / float64(workloads)
+	for _, c := range counts {
+		d := float64(c) - expected
+		result.Statistic += d * d / expected
+	}
+	// Workloads that didn't respond at all contribute the full expected value.
+	result.Statistic += float64(workloads-len(counts)) * expected
+
+	result.PValue = chiSquareSurvival(result.Statistic, workloads-1)
+	result.Passed = result.PValue >= significance
+	return result
+}
+
+// chiSquareSurvival returns P(X >= x), for X following a chi-square distribution with the given
+// degrees of freedom.
+func chiSquareSurvival(x float64, dof int) float64 {
+	if x <= 0 {
+		return 1
+	}
+	return upperIncompleteGamma(float64(dof)/2, x/2)
+}
+
+// upperIncompleteGamma computes the regularized upper incomplete gamma function Q(a, x), using a
+// series expansion for x < a+1 and a continued fraction otherwise.
+func upperIncompleteGamma(a, x float64) float64 {
+	const (
+		maxIterations = 1000
+		epsilon       = 1e-14
+		tiny          = 1e-300
+	)
+
+	lgamma, _ := math.Lgamma(a)
+	prefix := math.Exp(-x + a*math.Log(x) - lgamma)
+
+	if x < a+1 {
+		sum := 1 / a
+		term := sum
+		for n := 1; n < maxIterations; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*epsilon {
+				break
+			}
+		}
+		return math.Max(0, 1-sum*prefix)
+	}
+
+	// Modified Lentz's method.
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for n := 1; n < maxIterations; n++ {
+		an := -float64(n) * (float64(n) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return prefix * h
+}
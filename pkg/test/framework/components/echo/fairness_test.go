@@ -0,0 +1,85 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestChiSquareSurvival(t *testing.T) {
+	cases := []struct {
+		x        float64
+		dof      int
+		expected float64
+	}{
+		// Reference values for the chi-square distribution.
+		{x: 3.841, dof: 1, expected: 0.05},
+		{x: 6.635, dof: 1, expected: 0.01},
+		{x: 5.991, dof: 2, expected: 0.05},
+		{x: 16.919, dof: 9, expected: 0.05},
+		{x: 0, dof: 4, expected: 1},
+	}
+
+	for _, c := range cases {
+		actual := chiSquareSurvival(c.x, c.dof)
+		if math.Abs(actual-c.expected) > 1e-3 {
+			t.Errorf("chiSquareSurvival(%v, %d): expected %v, got %v", c.x, c.dof, c.expected, actual)
+		}
+	}
+}
+
+func TestComputeFairness(t *testing.T) {
+	cases := []struct {
+		name      string
+		counts    map[string]int
+		workloads int
+		passed    bool
+	}{
+		{
+			name:      "uniform",
+			counts:    map[string]int{"a": 50, "b": 52, "c": 48},
+			workloads: 3,
+			passed:    true,
+		},
+		{
+			name:      "skewed",
+			counts:    map[string]int{"a": 100, "b": 30, "c": 20},
+			workloads: 3,
+			passed:    false,
+		},
+		{
+			name:      "starved",
+			counts:    map[string]int{"a": 75, "b": 75},
+			workloads: 3,
+			passed:    false,
+		},
+		{
+			name:      "single workload",
+			counts:    map[string]int{"a": 10},
+			workloads: 1,
+			passed:    true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result := computeFairness(c.counts, c.workloads, DefaultFairnessSignificance)
+			if result.Passed != c.passed {
+				t.Fatalf("expected passed=%v, got %s", c.passed, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// CheckHeadlessPerPodRouting verifies that every workload of the headless opts.Target is reachable
+// from source via its per-pod DNS name, and that calls to that name are served by the expected
+// workload.
+func CheckHeadlessPerPodRouting(source Instance, opts CallOptions) error {
+	if opts.Target == nil {
+		return errors.New("checkHeadlessPerPodRouting: missing Target")
+	}
+	if !opts.Target.Config().Headless {
+		return fmt.Errorf("checkHeadlessPerPodRouting: service %s is not headless", opts.Target.Config().Service)
+	}
+
+	workloads, err := opts.Target.Workloads()
+	if err != nil {
+		return err
+	}
+
+	var out error
+	for _, w := range workloads {
+		fqdn := w.PodFQDN()
+		if fqdn == "" {
+			out = multierror.Append(out, fmt.Errorf("workload %s has no per-pod DNS name", w.Hostname()))
+			continue
+		}
+
+		podOpts := opts
+		podOpts.Host = fqdn
+		responses, err := source.Call(podOpts)
+		if err != nil {
+			out = multierror.Append(out, fmt.Errorf("call to %s failed: %v", fqdn, err))
+			continue
+		}
+		if err := responses.CheckOK(); err != nil {
+			out = multierror.Append(out, fmt.Errorf("call to %s failed: %v", fqdn, err))
+			continue
+		}
+		for host, count := range responses.Distribution() {
+			if host != w.Hostname() {
+				out = multierror.Append(out, fmt.Errorf("call to %s routed to %s (%d responses), expected %s",
+					fqdn, host, count, w.Hostname()))
+			}
+		}
+	}
+	return out
+}
+
+// CheckHeadlessPerPodRoutingOrFail calls CheckHeadlessPerPodRouting and fails t if an error occurs.
+func CheckHeadlessPerPodRoutingOrFail(t testing.TB, source Instance, opts CallOptions) {
+	if err := CheckHeadlessPerPodRouting(source, opts); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/test/framework/components/galley"
+	"istio.io/istio/pkg/test/framework/components/ingress"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/util/retry"
+	"istio.io/istio/pkg/test/util/tmpl"
+)
+
+const (
+	ingressRouteYAML = `
+apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: {{ .Name }}
+spec:
+  selector:
+    istio: {{ .Selector }}
+  servers:
+  - port:
+      number: 80
+      name: http
+      protocol: HTTP
+    hosts:
+    - "*"
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: {{ .Name }}
+spec:
+  hosts:
+  - "*"
+  gateways:
+  - {{ .Name }}
+  http:
+  - match:
+    - uri:
+        prefix: {{ .PathPrefix }}
+    route:
+    - destination:
+        host: {{ .Host }}
+        port:
+          number: {{ .Port }}
+`
+
+	defaultIngressSelector     = "ingressgateway"
+	defaultIngressRouteTimeout = 2 * time.Minute
+)
+
+// IngressRouteConfig for NewIngressRoute.
+type IngressRouteConfig struct {
+	// Galley used to apply the Gateway and VirtualService. Required.
+	Galley galley.Instance
+
+	// Ingress through which requests are made. Required.
+	Ingress ingress.Instance
+
+	// Target echo Instance to which requests are routed. Required.
+	Target Instance
+
+	// PathPrefix of the requests routed to the Target. Defaults to "/".
+	PathPrefix string
+
+	// PortName of the HTTP port of the Target to which requests are routed. If not provided, the
+	// first HTTP port is used.
+	PortName string
+
+	// Selector is the value of the "istio" label of the ingress gateway pods selected by the
+	// Gateway. Defaults to "ingressgateway".
+	Selector string
+
+	// Timeout for the route to take effect. Defaults to 2m.
+	Timeout time.Duration
+}
+
+// IngressRoute routes requests for a path prefix from an ingress gateway to an echo Instance, via a
+// Gateway and VirtualService in the namespace of the Instance. The config is deleted when the
+// IngressRoute is closed, which happens automatically at the end of the context in which it was
+// created.
+type IngressRoute struct {
+	id   resource.ID
+	cfg  IngressRouteConfig
+	yaml string
+}
+
+var _ resource.Resource = &IngressRoute{}
+
+// NewIngressRoute applies the Gateway and VirtualService for the route, and waits until requests for
+// the PathPrefix are routed to the Target.
+func NewIngressRoute(ctx resource.Context, cfg IngressRouteConfig) (*IngressRoute, error) {
+	if cfg.Galley == nil || cfg.Ingress == nil || cfg.Target == nil {
+		return nil, errors.New("newIngressRoute: Galley, Ingress and Target are required")
+	}
+	if cfg.PathPrefix == "" {
+		cfg.PathPrefix = "/"
+	}
+	if cfg.Selector == "" {
+		cfg.Selector = defaultIngressSelector
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultIngressRouteTimeout
+	}
+
+	port, err := httpPort(cfg.Target.Config(), cfg.PortName)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &IngressRoute{cfg: cfg}
+	r.yaml, err = tmpl.Evaluate(ingressRouteYAML, map[string]interface{}{
+		"Name":       cfg.Target.Config().Service + "-ingress",
+		"Selector":   cfg.Selector,
+		"PathPrefix": cfg.PathPrefix,
+		"Host":       cfg.Target.Config().FQDN(),
+		"Port":       port.ServicePort,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cfg.Galley.ApplyConfig(cfg.Target.Config().Namespace, r.yaml); err != nil {
+		return nil, err
+	}
+	r.id = ctx.TrackResource(r)
+
+	err = retry.UntilSuccess(func() error {
+		return r.CheckRouted(cfg.PathPrefix)
+	}, retry.Timeout(cfg.Timeout), retry.Delay(time.Second))
+	if err != nil {
+		return nil, fmt.Errorf("ingress route %s to %s not ready: %v", cfg.PathPrefix, cfg.Target.Config().Service, err)
+	}
+	return r, nil
+}
+
+// NewIngressRouteOrFail calls NewIngressRoute and fails t if an error occurs.
+func NewIngressRouteOrFail(t testing.TB, ctx resource.Context, cfg IngressRouteConfig) *IngressRoute {
+	r, err := NewIngressRoute(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// ID implements resource.Resource
+func (r *IngressRoute) ID() resource.ID {
+	return r.id
+}
+
+// Call makes an HTTP request for the given path through the ingress gateway.
+func (r *IngressRoute) Call(path string) (ingress.CallResponse, error) {
+	return r.cfg.Ingress.Call(path)
+}
+
+// CheckRouted verifies that a request for the given path through the ingress gateway was routed to
+// (and received with the same path by) a workload of the Target.
+func (r *IngressRoute) CheckRouted(path string) error {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	resp, err := r.Call(path)
+	if err != nil {
+		return err
+	}
+	if resp.Code != http.StatusOK {
+		return fmt.Errorf("request for %s through ingress: expected Status Code 200, received %d", path, resp.Code)
+	}
+	if !strings.Contains(resp.Body, "URL="+path) {
+		return fmt.Errorf("request for %s through ingress not received with the same path: %s", path, resp.Body)
+	}
+
+	workloads, err := r.cfg.Target.Workloads()
+	if err != nil {
+		return err
+	}
+	for _, w := range workloads {
+		if strings.Contains(resp.Body, "Hostname="+w.Hostname()+"\n") {
+			return nil
+		}
+	}
+	return fmt.Errorf("request for %s through ingress not routed to %s: %s", path, r.cfg.Target.Config().Service, resp.Body)
+}
+
+// CheckRoutedOrFail calls CheckRouted and fails t if an error occurs.
+func (r *IngressRoute) CheckRoutedOrFail(t testing.TB, path string) {
+	if err := r.CheckRouted(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Close deletes the Gateway and VirtualService of the route.
+func (r *IngressRoute) Close() error {
+	return r.cfg.Galley.DeleteConfig(r.cfg.Target.Config().Namespace, r.yaml)
+}
+
+// httpPort returns the exposed HTTP port with the given name (or the first exposed HTTP port).
+func httpPort(cfg Config, portName string) (*Port, error) {
+	for _, p := range cfg.Ports {
+		if p.Protocol != model.ProtocolHTTP || p.Unexposed {
+			continue
+		}
+		if portName == "" || p.Name == portName {
+			return &p, nil
+		}
+	}
+	return nil, fmt.Errorf("no HTTP port %q found for service %s", portName, cfg.Service)
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// JWTIssuer generates RS256-signed JWTs for use with CallOptions.JWT. The public key must be
+// configured (e.g. via the JWKS of the issuer) in the authentication policy under test.
+type JWTIssuer struct {
+	// Issuer (iss) of the generated tokens.
+	Issuer string
+
+	// KeyID (kid) included in the header of the generated tokens. Optional.
+	KeyID string
+
+	// Key used to sign the tokens.
+	Key *rsa.PrivateKey
+}
+
+// Token generates a JWT for the given subject, expiring after the given duration. Additional claims
+// (e.g. "groups") may be provided, and override the standard claims.
+func (i JWTIssuer) Token(subject string, expiry time.Duration, claims map[string]interface{}) (string, error) {
+	if i.Key == nil {
+		return "", errors.New("jwtIssuer: missing Key")
+	}
+
+	now := time.Now()
+	mapClaims := jwt.MapClaims{
+		"iss": i.Issuer,
+		"sub": subject,
+		"iat": now.Unix(),
+		"exp": now.Add(expiry).Unix(),
+	}
+	for name, value := range claims {
+		mapClaims[name] = value
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, mapClaims)
+	if i.KeyID != "" {
+		token.Header["kid"] = i.KeyID
+	}
+	return token.SignedString(i.Key)
+}
+
+// JWKS returns the JSON Web Key Set with the public key of the issuer, e.g. for the inline jwks of
+// the authentication policy under test.
+func (i JWTIssuer) JWKS() (string, error) {
+	if i.Key == nil {
+		return "", errors.New("jwtIssuer: missing Key")
+	}
+
+	key := map[string]string{
+		"kty": "RSA",
+		"alg": "RS256",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(i.Key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(i.Key.E)).Bytes()),
+	}
+	if i.KeyID != "" {
+		key["kid"] = i.KeyID
+	}
+	by, err := json.Marshal(map[string]interface{}{"keys": []interface{}{key}})
+	if err != nil {
+		return "", err
+	}
+	return string(by), nil
+}
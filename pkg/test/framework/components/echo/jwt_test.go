@@ -0,0 +1,129 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// verifyJWT verifies the token against the JWKS, the way the proxy does, returning its claims.
+func verifyJWT(token, jwks string) (jwt.MapClaims, error) {
+	var keySet struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal([]byte(jwks), &keySet); err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		for _, k := range keySet.Keys {
+			if k.Kty != "RSA" || k.Kid != t.Header["kid"] {
+				continue
+			}
+			n, err := base64.RawURLEncoding.DecodeString(k.N)
+			if err != nil {
+				return nil, err
+			}
+			e, err := base64.RawURLEncoding.DecodeString(k.E)
+			if err != nil {
+				return nil, err
+			}
+			return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+		}
+		return nil, fmt.Errorf("no key %v in JWKS", t.Header["kid"])
+	})
+	return claims, err
+}
+
+func TestJWTIssuer(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := JWTIssuer{Issuer: "test@istio.io", KeyID: "key-1", Key: key}
+	jwks, err := issuer.JWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("verified", func(t *testing.T) {
+		token, err := issuer.Token("alice", time.Hour, map[string]interface{}{"groups": []string{"admins"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := verifyJWT(token, jwks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims["iss"] != "test@istio.io" || claims["sub"] != "alice" {
+			t.Fatalf("unexpected standard claims: %v", claims)
+		}
+		if groups, ok := claims["groups"].([]interface{}); !ok || len(groups) != 1 || groups[0] != "admins" {
+			t.Fatalf("unexpected groups claim: %v", claims["groups"])
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		token, err := issuer.Token("alice", -time.Minute, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := verifyJWT(token, jwks); err == nil {
+			t.Fatal("expected the expired token to fail verification")
+		}
+	})
+
+	t.Run("other key", func(t *testing.T) {
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		other := JWTIssuer{Issuer: "test@istio.io", KeyID: "key-1", Key: otherKey}
+		token, err := other.Token("alice", time.Hour, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := verifyJWT(token, jwks); err == nil {
+			t.Fatal("expected the token signed with another key to fail verification")
+		}
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		if _, err := (JWTIssuer{Issuer: "test@istio.io"}).Token("alice", time.Hour, nil); err == nil {
+			t.Fatal("expected an error generating a token without a key")
+		}
+		if _, err := (JWTIssuer{Issuer: "test@istio.io"}).JWKS(); err == nil {
+			t.Fatal("expected an error generating a JWKS without a key")
+		}
+	})
+}
@@ -0,0 +1,140 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/util/retry"
+	"istio.io/istio/pkg/test/util/yml"
+
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// deleteTimeout bounds the wait for the deleted resources to disappear.
+	deleteTimeout = 2 * time.Minute
+)
+
+func (c *instance) Delete() error {
+	ns := c.cfg.Namespace.Name()
+
+	// Close the port forwards to the pods before deleting them.
+	c.mutex.Lock()
+	err := c.resetWorkloads()
+	c.mutex.Unlock()
+
+	// The Service (and ServiceAccount) may be shared with other versions, which keep using it.
+	shared, sharedErr := c.serviceShared()
+	if sharedErr != nil {
+		return multierror.Append(err, fmt.Errorf("failed checking for other versions of service %s/%s: %v",
+			ns, c.cfg.Service, sharedErr))
+	}
+	manifest := c.manifest
+	if shared {
+		var manifestErr error
+		if manifest, manifestErr = workloadManifest(c.manifest, deploymentKind(c.Config())); manifestErr != nil {
+			return multierror.Append(err, manifestErr)
+		}
+	}
+
+	if deleteErr := c.env.DeleteContents(ns, manifest); deleteErr != nil {
+		return multierror.Append(err, fmt.Errorf("failed deleting %s: %v", c, deleteErr))
+	}
+
+	if waitErr := retry.UntilSuccess(func() error {
+		pods, err := c.env.GetPods(ns, "app="+c.cfg.Service, "version="+c.cfg.Version)
+		if err != nil {
+			return err
+		}
+		if len(pods) > 0 {
+			return fmt.Errorf("%d pods remaining", len(pods))
+		}
+		if shared {
+			// The endpoints of the remaining versions stay.
+			return nil
+		}
+		_, err = c.env.GetEndpoints(ns, c.cfg.Service, kubeApiMeta.GetOptions{})
+		switch {
+		case err == nil:
+			return fmt.Errorf("endpoints remaining")
+		case kubeErrors.IsNotFound(err):
+			return nil
+		default:
+			return err
+		}
+	}, retry.Timeout(deleteTimeout), retry.Delay(time.Second)); waitErr != nil {
+		err = multierror.Append(err, fmt.Errorf("failed waiting for deletion of %s: %v", c, waitErr))
+	}
+	return err
+}
+
+// serviceShared indicates whether a Deployment or StatefulSet of another version of the service
+// exists, even if scaled to zero.
+func (c *instance) serviceShared() (bool, error) {
+	ns := c.cfg.Namespace.Name()
+	deployments, err := c.env.GetDeployments(ns)
+	if err != nil {
+		return false, err
+	}
+	statefulSets, err := c.env.GetStatefulSets(ns)
+	if err != nil {
+		return false, err
+	}
+
+	selectors := make([]*kubeApiMeta.LabelSelector, 0, len(deployments)+len(statefulSets))
+	for _, d := range deployments {
+		selectors = append(selectors, d.Spec.Selector)
+	}
+	for _, s := range statefulSets {
+		selectors = append(selectors, s.Spec.Selector)
+	}
+	return selectsOtherVersion(c.Config(), selectors), nil
+}
+
+// selectsOtherVersion indicates whether any of the workload selectors selects the pods of another
+// version of the configured service.
+func selectsOtherVersion(cfg echo.Config, selectors []*kubeApiMeta.LabelSelector) bool {
+	for _, s := range selectors {
+		if s != nil && s.MatchLabels["app"] == cfg.Service && s.MatchLabels["version"] != cfg.Version {
+			return true
+		}
+	}
+	return false
+}
+
+// workloadManifest returns the parts of the manifest of the given kind, i.e. the Deployment or
+// StatefulSet of a single version.
+func workloadManifest(manifest string, kind echo.DeploymentKind) (string, error) {
+	parts, err := yml.Parse(manifest)
+	if err != nil {
+		return "", err
+	}
+	out := make([]string, 0, 1)
+	for _, p := range parts {
+		if p.Descriptor.Kind == string(kind) {
+			out = append(out, p.Contents)
+		}
+	}
+	if len(out) == 0 {
+		return "", fmt.Errorf("no %s in the manifest", kind)
+	}
+	return yml.JoinString(out...), nil
+}
@@ -15,16 +15,43 @@
 package kube
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"text/template"
 
 	"istio.io/istio/pkg/test/framework/core/image"
 
 	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/scopes"
 	"istio.io/istio/pkg/test/util/tmpl"
+
+	kubeCore "k8s.io/api/core/v1"
 )
 
 const (
+	defaultVolumeClaimSize      = "1Gi"
+	defaultVolumeClaimMountPath = "/data"
+	customCertDir               = "/etc/certs/custom"
+
+	// appContainerName is the name of the echo application container in deploymentYAML.
+	appContainerName = "app"
+
+	// certVolumeName is the name of the CertSecret volume in deploymentYAML.
+	certVolumeName = "custom-certs"
+
+	userVolumeAnnotation = "sidecar.istio.io/userVolume"
+	userMountAnnotation  = "sidecar.istio.io/userVolumeMount"
+
+	// dnsCaptureMetadata is the proxy metadata that controls DNS capture. The injector passes the pod
+	// annotations to the proxy as metadata, so the annotation is equivalent to setting
+	// ISTIO_META_DNS_CAPTURE.
+	dnsCaptureMetadata = "DNS_CAPTURE"
+
+	multusNetworksAnnotation       = "k8s.v1.cni.cncf.io/networks"
+	multusNetworksStatusAnnotation = "k8s.v1.cni.cncf.io/networks-status"
+
 	deploymentYAML = `
 {{- if .ServiceAccount }}
 apiVersion: v1
@@ -33,6 +60,22 @@ metadata:
   name: {{ .Service }}
 ---
 {{- end }}
+{{- with .VolumeClaim }}
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: {{ $.VolumeClaimName }}
+spec:
+  accessModes:
+  - {{ .AccessMode }}
+{{- if .StorageClass }}
+  storageClassName: {{ .StorageClass }}
+{{- end }}
+  resources:
+    requests:
+      storage: {{ .Size }}
+---
+{{- end }}
 apiVersion: v1
 kind: Service
 metadata:
@@ -42,22 +85,31 @@ metadata:
 spec:
 {{- if .Headless }}
   clusterIP: None
+{{- else if .ServiceType }}
+  type: {{ .ServiceType }}
 {{- end }}
   ports:
 {{- range $i, $p := .Ports }}
   - name: {{ $p.Name }}
     port: {{ $p.ServicePort }}
     targetPort: {{ $p.InstancePort }}
+{{- if eq $p.Protocol "UDP" }}
+    protocol: UDP
+{{- end }}
 {{- end }}
   selector:
     app: {{ .Service }}
 ---
 apiVersion: apps/v1
-kind: Deployment
+kind: {{ .DeploymentKind }}
 metadata:
   name: {{ .Service }}-{{ .Version }}
 spec:
-  replicas: 1
+  replicas: {{ .Replicas }}
+{{- if eq .DeploymentKind "StatefulSet" }}
+  serviceName: {{ .Service }}
+  podManagementPolicy: Parallel
+{{- end }}
   selector:
     matchLabels:
       app: {{ .Service }}
@@ -68,54 +120,145 @@ spec:
   template:
     metadata:
       labels:
-        app: {{ .Service }}
-        version: {{ .Version }}
-{{- if ne .Locality "" }}
-        istio-locality: {{ .Locality }}
+{{- range $name, $value := .PodLabels }}
+        {{ $name }}: {{ printf "%q" $value }}
 {{- end }}
-{{- if not .Sidecar }}
+{{- if .PodAnnotations }}
       annotations:
-        sidecar.istio.io/inject: "false"
+{{- range $name, $value := .PodAnnotations }}
+        {{ $name }}: {{ printf "%q" $value }}
+{{- end }}
 {{- end }}
     spec:
 {{- if .ServiceAccount }}
       serviceAccountName: {{ .Service }}
+{{- end }}
+{{- if .SchedulerName }}
+      schedulerName: {{ .SchedulerName }}
+{{- end }}
+{{- if .FSGroup }}
+      securityContext:
+        fsGroup: {{ .FSGroup }}
+{{- end }}
+{{- if .ImagePullSecrets }}
+      imagePullSecrets:
+{{- range $name := .ImagePullSecrets }}
+      - name: {{ $name }}
+{{- end }}
 {{- end }}
       containers:
       - name: app
-        image: {{ .Hub }}/app:{{ .Tag }}
+        image: {{ .Image }}
         imagePullPolicy: {{ .PullPolicy }}
+{{- if .RunAsUser }}
+        securityContext:
+          runAsUser: {{ .RunAsUser }}
+{{- end }}
+{{- if or .ResourceRequests .ResourceLimits }}
+        resources:
+{{- if .ResourceRequests }}
+          requests:
+{{- range $name, $quantity := .ResourceRequests }}
+            {{ $name }}: {{ printf "%q" $quantity }}
+{{- end }}
+{{- end }}
+{{- if .ResourceLimits }}
+          limits:
+{{- range $name, $quantity := .ResourceLimits }}
+            {{ $name }}: {{ printf "%q" $quantity }}
+{{- end }}
+{{- end }}
+{{- end }}
         args:
 {{- range $i, $p := .ContainerPorts }}
-{{- if eq .Protocol "GRPC" }}
+{{- if eq .Protocol "UDP" }}
+{{- /* UDP ports aren't served by the echo application. */ -}}
+{{- else if eq .Protocol "GRPC" }}
           - --grpc
+          - "{{ $p.Port }}"
 {{- else }}
           - --port
-{{- end }}
           - "{{ $p.Port }}"
+{{- end }}
 {{- end }}
           - --version
           - "{{ .Version }}"
+{{- if .Cluster }}
+          - --cluster
+          - "{{ .Cluster }}"
+{{- end }}
+{{- if .StartupDelay }}
+          - --startup-delay
+          - "{{ .StartupDelay }}"
+{{- end }}
+{{- range $path, $code := .PathStatuses }}
+          - --path-status
+          - "{{ $path }}={{ $code }}"
+{{- end }}
         ports:
 {{- range $i, $p := .ContainerPorts }}
         - containerPort: {{ $p.Port }} 
-{{- if eq .Port 3333 }}
-          name: tcp-health-port
+{{- if eq .Protocol "UDP" }}
+          protocol: UDP
+{{- end }}
+{{- if eq .Port $.HealthPort }}
+          name: {{ $.HealthPortName }}
 {{- end }}
 {{- end }}
         readinessProbe:
+{{- if .ReadinessGRPCPort }}
+          exec:
+            command:
+            - /usr/local/bin/client
+            - --health
+            - --url
+            - grpc://127.0.0.1:{{ .ReadinessGRPCPort }}
+{{- else if eq .HealthCheckProtocol "TCP" }}
+          tcpSocket:
+            port: {{ .ReadinessPort }}
+{{- else }}
           httpGet:
             path: /
-            port: 8080
+            port: {{ .ReadinessPort }}
+{{- end }}
           initialDelaySeconds: 10
           periodSeconds: 10
           failureThreshold: 10
         livenessProbe:
+{{- if eq .HealthCheckProtocol "HTTP" }}
+          httpGet:
+            path: /
+            port: {{ .HealthPortName }}
+{{- else }}
           tcpSocket:
-            port: tcp-health-port
+            port: {{ .HealthPortName }}
+{{- end }}
           initialDelaySeconds: 10
           periodSeconds: 10
           failureThreshold: 10
+{{- if or .VolumeClaim .CertSecret }}
+        volumeMounts:
+{{- with .VolumeClaim }}
+        - name: data
+          mountPath: {{ .MountPath }}
+{{- end }}
+{{- if .CertSecret }}
+        - name: {{ .CertVolume }}
+          mountPath: {{ .CertDir }}
+          readOnly: true
+{{- end }}
+      volumes:
+{{- with .VolumeClaim }}
+      - name: data
+        persistentVolumeClaim:
+          claimName: {{ $.VolumeClaimName }}
+{{- end }}
+{{- if .CertSecret }}
+      - name: {{ .CertVolume }}
+        secret:
+          secretName: {{ .CertSecret }}
+{{- end }}
+{{- end }}
 ---
 apiVersion: v1
 kind: Secret
@@ -150,6 +293,165 @@ func init() {
 	}
 }
 
+// deploymentKind returns the kind of the workload resource for the configuration.
+func deploymentKind(cfg echo.Config) echo.DeploymentKind {
+	if cfg.DeploymentKind == "" {
+		return echo.DeploymentKindDeployment
+	}
+	return cfg.DeploymentKind
+}
+
+// podAnnotations returns the annotations to be applied to the echo pods.
+func podAnnotations(cfg echo.Config) map[string]string {
+	out := make(map[string]string)
+	if !cfg.Sidecar {
+		out["sidecar.istio.io/inject"] = "false"
+	} else {
+		if cfg.BootstrapOverride != "" {
+			out["sidecar.istio.io/bootstrapOverride"] = cfg.BootstrapOverride
+		}
+		if cfg.DNSCapture != nil {
+			out[dnsCaptureMetadata] = strconv.FormatBool(*cfg.DNSCapture)
+		}
+		if volumes, mounts := sidecarVolumeAnnotations(cfg); mounts != "" {
+			if volumes != "" {
+				out[userVolumeAnnotation] = volumes
+			}
+			out[userMountAnnotation] = mounts
+		}
+		// Validated by New.
+		if resources, _ := cfg.EffectiveProxyResources(); resources != nil {
+			if resources.CPU != "" {
+				out["sidecar.istio.io/proxyCPU"] = resources.CPU
+			}
+			if resources.Memory != "" {
+				out["sidecar.istio.io/proxyMemory"] = resources.Memory
+			}
+		}
+	}
+	if len(cfg.NetworkAttachments) > 0 {
+		out[multusNetworksAnnotation] = strings.Join(cfg.NetworkAttachments, ",")
+	}
+	return mergeUserValues(cfg, "annotation", out, cfg.PodAnnotations)
+}
+
+// podLabels returns the labels to be applied to the echo pods.
+func podLabels(cfg echo.Config) map[string]string {
+	out := map[string]string{
+		"app":     cfg.Service,
+		"version": cfg.Version,
+	}
+	if cfg.Locality != "" {
+		out["istio-locality"] = cfg.Locality
+	}
+	return mergeUserValues(cfg, "label", out, cfg.PodLabels)
+}
+
+// mergeUserValues adds the user-provided values to those set by the framework, which take
+// precedence. A warning is logged for each user value that is ignored.
+func mergeUserValues(cfg echo.Config, kind string, framework, user map[string]string) map[string]string {
+	for k, v := range user {
+		if fv, ok := framework[k]; ok {
+			if fv != v {
+				scopes.Framework.Warnf("echo %s: ignoring pod %s %s=%q, which is set by the framework to %q",
+					cfg.Service, kind, k, v, fv)
+			}
+			continue
+		}
+		framework[k] = v
+	}
+	return framework
+}
+
+// sidecarVolumeAnnotations returns the values of the annotations for the user volumes and mounts of
+// the sidecar, which are JSON objects keyed by volume name. The CertSecret volume of CertSourceFile
+// is already part of the pod, so it is only mounted. Empty values are returned if there's nothing to
+// mount.
+func sidecarVolumeAnnotations(cfg echo.Config) (string, string) {
+	sources := make(map[string]kubeCore.VolumeSource, len(cfg.SidecarVolumes))
+	mounts := make(map[string]interface{}, len(cfg.SidecarVolumes)+1)
+	for _, v := range cfg.SidecarVolumes {
+		source := kubeCore.VolumeSource{}
+		if v.Secret != "" {
+			source.Secret = &kubeCore.SecretVolumeSource{SecretName: v.Secret}
+		} else {
+			source.ConfigMap = &kubeCore.ConfigMapVolumeSource{
+				LocalObjectReference: kubeCore.LocalObjectReference{Name: v.ConfigMap},
+			}
+		}
+		sources[v.Name] = source
+		mounts[v.Name] = map[string]interface{}{
+			"mountPath": v.MountPath,
+			"readOnly":  true,
+		}
+	}
+	if cfg.CertSource == echo.CertSourceFile {
+		mounts[certVolumeName] = map[string]interface{}{
+			"mountPath": customCertDir,
+			"readOnly":  true,
+		}
+	}
+	if len(mounts) == 0 {
+		return "", ""
+	}
+
+	// Marshaling can't fail for these types.
+	mountsJSON, _ := json.Marshal(mounts)
+	if len(sources) == 0 {
+		return "", string(mountsJSON)
+	}
+	sourcesJSON, _ := json.Marshal(sources)
+	return string(sourcesJSON), string(mountsJSON)
+}
+
+// volumeClaimName returns the name of the PersistentVolumeClaim created for the configuration.
+func volumeClaimName(cfg echo.Config) string {
+	return cfg.Service + "-" + cfg.Version + "-data"
+}
+
+// volumeClaimWithDefaults returns a copy of the given claim with defaults filled in for any missing
+// values, or nil if no claim was given.
+func volumeClaimWithDefaults(claim *echo.VolumeClaim) *echo.VolumeClaim {
+	if claim == nil {
+		return nil
+	}
+	out := *claim
+	if out.Size == "" {
+		out.Size = defaultVolumeClaimSize
+	}
+	if out.AccessMode == "" {
+		out.AccessMode = string(kubeCore.ReadWriteOnce)
+	}
+	if out.MountPath == "" {
+		out.MountPath = defaultVolumeClaimMountPath
+	}
+	return &out
+}
+
+// appResources returns the quantities of the resources requested by the echo application container
+// and their limits, by resource name. These are the Resources of the configuration, along with the
+// ExtendedResources (which are both requested and limited).
+func appResources(cfg echo.Config) (requests, limits map[string]string) {
+	add := func(out map[string]string, name, quantity string) map[string]string {
+		if out == nil {
+			out = make(map[string]string)
+		}
+		out[name] = quantity
+		return out
+	}
+	for name, quantity := range cfg.Resources.Requests {
+		requests = add(requests, string(name), quantity.String())
+	}
+	for name, quantity := range cfg.Resources.Limits {
+		limits = add(limits, string(name), quantity.String())
+	}
+	for name, quantity := range cfg.ExtendedResources {
+		requests = add(requests, name, quantity)
+		limits = add(limits, name, quantity)
+	}
+	return requests, limits
+}
+
 func generateYAML(cfg echo.Config) (string, error) {
 	// Create the parameters for the YAML template.
 	settings, err := image.SettingsFromCommandLine()
@@ -157,19 +459,59 @@ func generateYAML(cfg echo.Config) (string, error) {
 		return "", err
 	}
 
+	readinessGRPCPort := 0
+	if cfg.ReadinessGRPCPort != "" {
+		p, err := getReadinessGRPCPort(cfg)
+		if err != nil {
+			return "", err
+		}
+		readinessGRPCPort = p.InstancePort
+	}
+
+	appImage := cfg.Image
+	if appImage == "" {
+		appImage = settings.Hub + "/app:" + settings.Tag
+	}
+	pullPolicy := cfg.ImagePullPolicy
+	if pullPolicy == "" {
+		pullPolicy = settings.PullPolicy
+	}
+
 	params := map[string]interface{}{
-		"Hub":            settings.Hub,
-		"Tag":            settings.Tag,
-		"PullPolicy":     settings.PullPolicy,
-		"Service":        cfg.Service,
-		"Version":        cfg.Version,
-		"Sidecar":        cfg.Sidecar,
-		"Headless":       cfg.Headless,
-		"Locality":       cfg.Locality,
-		"ServiceAccount": cfg.ServiceAccount,
-		"Ports":          cfg.Ports,
-		"ContainerPorts": getContainerPorts(cfg.Ports),
+		"Image":               appImage,
+		"PullPolicy":          pullPolicy,
+		"ImagePullSecrets":    cfg.ImagePullSecrets,
+		"Service":             cfg.Service,
+		"Version":             cfg.Version,
+		"Cluster":             cfg.Cluster,
+		"Sidecar":             cfg.Sidecar,
+		"Headless":            cfg.Headless,
+		"ServiceType":         kubeServiceType(cfg),
+		"Locality":            cfg.Locality,
+		"ServiceAccount":      cfg.ServiceAccount,
+		"Ports":               getServicePorts(cfg),
+		"ContainerPorts":      getContainerPorts(cfg),
+		"ReadinessGRPCPort":   readinessGRPCPort,
+		"ReadinessPort":       getReadinessPort(cfg),
+		"HealthPort":          getHealthPort(cfg),
+		"HealthPortName":      getHealthPortName(cfg),
+		"HealthCheckProtocol": string(cfg.HealthCheckProtocol),
+		"VolumeClaim":         volumeClaimWithDefaults(cfg.VolumeClaim),
+		"VolumeClaimName":     volumeClaimName(cfg),
+		"PodAnnotations":      podAnnotations(cfg),
+		"PodLabels":           podLabels(cfg),
+		"CertSecret":          cfg.CertSecret,
+		"CertDir":             customCertDir,
+		"CertVolume":          certVolumeName,
+		"StartupDelay":        cfg.StartupDelay,
+		"PathStatuses":        cfg.PathStatuses,
+		"SchedulerName":       cfg.SchedulerName,
+		"Replicas":            cfg.Replicas,
+		"DeploymentKind":      deploymentKind(cfg),
+		"RunAsUser":           cfg.RunAsUser,
+		"FSGroup":             cfg.FSGroup,
 	}
+	params["ResourceRequests"], params["ResourceLimits"] = appResources(cfg)
 
 	// Generate the YAML content.
 	return tmpl.Execute(deploymentTemplate, params)
@@ -0,0 +1,678 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"encoding/json"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/common"
+
+	kubeApps "k8s.io/api/apps/v1"
+	kubeCore "k8s.io/api/core/v1"
+	kubeApiResource "k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestGenerateYAMLUDPPort(t *testing.T) {
+	setImageFlags(t)
+
+	cfg := echo.Config{
+		Service:  "a",
+		Version:  "v1",
+		Replicas: 1,
+		Ports: []echo.Port{
+			{Name: "grpc", Protocol: model.ProtocolGRPC, ServicePort: 70, InstancePort: 7070},
+			{Name: "udp", Protocol: model.ProtocolUDP, ServicePort: 53, InstancePort: 5353},
+		},
+	}
+	out, err := generateYAML(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, deployment := parseGeneratedYAML(t, out)
+
+	var servicePort *kubeCore.ServicePort
+	for i, p := range service.Spec.Ports {
+		if p.Name == "udp" {
+			servicePort = &service.Spec.Ports[i]
+		}
+	}
+	if servicePort == nil {
+		t.Fatalf("UDP port not found in Service: %+v", service.Spec.Ports)
+	}
+	if servicePort.Protocol != kubeCore.ProtocolUDP || servicePort.Port != 53 || servicePort.TargetPort.IntValue() != 5353 {
+		t.Fatalf("unexpected Service port: %+v", *servicePort)
+	}
+
+	if len(deployment.Spec.Template.Spec.Containers) == 0 {
+		t.Fatalf("no containers in Deployment:\n%s", out)
+	}
+	app := deployment.Spec.Template.Spec.Containers[0]
+	protocols := make(map[int32]kubeCore.Protocol)
+	for _, p := range app.Ports {
+		protocols[p.ContainerPort] = p.Protocol
+	}
+	if protocols[5353] != kubeCore.ProtocolUDP {
+		t.Fatalf("expected UDP container port 5353, found: %+v", app.Ports)
+	}
+	// The readiness and health ports are added for TCP, not the UDP port.
+	for _, port := range []int32{httpReadinessPort, tcpHealthPort} {
+		if p, ok := protocols[port]; !ok || p == kubeCore.ProtocolUDP {
+			t.Fatalf("expected TCP container port %d, found: %+v", port, app.Ports)
+		}
+	}
+	for _, arg := range app.Args {
+		if arg == "5353" {
+			t.Fatalf("UDP port passed to the echo application: %v", app.Args)
+		}
+	}
+}
+
+func TestPodLabelsAndAnnotations(t *testing.T) {
+	cfg := echo.Config{
+		Service: "a",
+		Version: "v1",
+		PodLabels: map[string]string{
+			"app":    "b",
+			"custom": "label",
+		},
+		PodAnnotations: map[string]string{
+			"sidecar.istio.io/inject":                       "true",
+			"traffic.sidecar.istio.io/excludeOutboundPorts": "3306",
+		},
+	}
+
+	labels := podLabels(cfg)
+	if labels["app"] != "a" || labels["version"] != "v1" || labels["custom"] != "label" {
+		t.Fatalf("unexpected labels: %v", labels)
+	}
+
+	annotations := podAnnotations(cfg)
+	if annotations["sidecar.istio.io/inject"] != "false" ||
+		annotations["traffic.sidecar.istio.io/excludeOutboundPorts"] != "3306" {
+		t.Fatalf("unexpected annotations: %v", annotations)
+	}
+}
+
+func TestDNSCaptureAnnotation(t *testing.T) {
+	enabled, disabled := true, false
+	for _, c := range []struct {
+		dnsCapture *bool
+		expected   string
+	}{
+		{dnsCapture: nil, expected: ""},
+		{dnsCapture: &enabled, expected: "true"},
+		{dnsCapture: &disabled, expected: "false"},
+	} {
+		cfg := echo.Config{Service: "a", Version: "v1", Sidecar: true, DNSCapture: c.dnsCapture}
+		if actual := podAnnotations(cfg)[dnsCaptureMetadata]; actual != c.expected {
+			t.Fatalf("expected DNS capture %q, got %q", c.expected, actual)
+		}
+	}
+}
+
+func TestGenerateYAMLServiceType(t *testing.T) {
+	setImageFlags(t)
+
+	cases := []struct {
+		serviceType echo.ServiceType
+		headless    bool
+		expected    kubeCore.ServiceType
+		clusterIP   string
+	}{
+		{serviceType: echo.ServiceTypeClusterIP},
+		{serviceType: echo.ServiceTypeNodePort, expected: kubeCore.ServiceTypeNodePort},
+		{serviceType: echo.ServiceTypeLoadBalancer, expected: kubeCore.ServiceTypeLoadBalancer},
+		{serviceType: echo.ServiceTypeHeadless, headless: true, clusterIP: kubeCore.ClusterIPNone},
+	}
+	for _, c := range cases {
+		t.Run(string(c.serviceType), func(t *testing.T) {
+			cfg := echo.Config{
+				Service:     "a",
+				Version:     "v1",
+				Replicas:    1,
+				Headless:    c.headless,
+				ServiceType: c.serviceType,
+				Ports: []echo.Port{
+					{Name: "grpc", Protocol: model.ProtocolGRPC, ServicePort: 70, InstancePort: 7070},
+				},
+			}
+			if err := validateServiceType(cfg); err != nil {
+				t.Fatal(err)
+			}
+			out, err := generateYAML(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			service, _ := parseGeneratedYAML(t, out)
+			if service.Spec.Type != c.expected || service.Spec.ClusterIP != c.clusterIP {
+				t.Fatalf("unexpected Service spec for %s: %+v", c.serviceType, service.Spec)
+			}
+		})
+	}
+}
+
+func TestValidateServiceType(t *testing.T) {
+	for _, cfg := range []echo.Config{
+		{Service: "a", ServiceType: echo.ServiceTypeNodePort, Headless: true},
+		{Service: "a", ServiceType: "ExternalName"},
+	} {
+		if err := validateServiceType(cfg); err == nil {
+			t.Errorf("expected error for service type %q (headless: %t)", cfg.ServiceType, cfg.Headless)
+		}
+	}
+}
+
+func TestServiceAddresses(t *testing.T) {
+	cfg := echo.Config{
+		Ports: []echo.Port{
+			{Name: "grpc", Protocol: model.ProtocolGRPC, ServicePort: 70, InstancePort: 7070},
+			{Name: "http", Protocol: model.ProtocolHTTP, ServicePort: 80, InstancePort: 8080},
+			{Name: "hidden", Protocol: model.ProtocolHTTP, ServicePort: 90, InstancePort: 9090, Unexposed: true},
+		},
+	}
+
+	var actual []string
+	for _, a := range serviceAddresses(cfg, "fd00::1", []string{"10.0.0.1"}) {
+		actual = append(actual, a.Port.Name+"="+a.HostPort())
+	}
+	expected := []string{"grpc=[fd00::1]:70", "http=[fd00::1]:80"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("cluster IP: expected %v, got %v", expected, actual)
+	}
+
+	// Headless services are reached at the instance ports of each workload.
+	actual = nil
+	for _, a := range serviceAddresses(cfg, "", []string{"10.0.0.1", "10.0.0.2"}) {
+		actual = append(actual, a.Port.Name+"="+a.HostPort())
+	}
+	expected = []string{"grpc=10.0.0.1:7070", "grpc=10.0.0.2:7070", "http=10.0.0.1:8080", "http=10.0.0.2:8080"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("headless: expected %v, got %v", expected, actual)
+	}
+}
+
+func TestGenerateYAMLHealthCheckProtocol(t *testing.T) {
+	setImageFlags(t)
+
+	cases := []struct {
+		protocol       model.Protocol
+		readinessHTTP  bool
+		livenessHTTP   bool
+		healthPortName string
+	}{
+		{protocol: "", readinessHTTP: true, livenessHTTP: false, healthPortName: "tcp-health-port"},
+		{protocol: model.ProtocolTCP, readinessHTTP: false, livenessHTTP: false, healthPortName: "tcp-health-port"},
+		{protocol: model.ProtocolHTTP, readinessHTTP: true, livenessHTTP: true, healthPortName: "http-health-port"},
+	}
+	for _, c := range cases {
+		t.Run("protocol="+string(c.protocol), func(t *testing.T) {
+			cfg := echo.Config{
+				Service:             "a",
+				Version:             "v1",
+				Replicas:            1,
+				HealthCheckProtocol: c.protocol,
+				Ports: []echo.Port{
+					{Name: "grpc", Protocol: model.ProtocolGRPC, ServicePort: 70, InstancePort: 7070},
+				},
+			}
+			if err := validateProbePorts(cfg); err != nil {
+				t.Fatal(err)
+			}
+			out, err := generateYAML(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, deployment := parseGeneratedYAML(t, out)
+			if len(deployment.Spec.Template.Spec.Containers) == 0 {
+				t.Fatalf("no containers in Deployment:\n%s", out)
+			}
+			app := deployment.Spec.Template.Spec.Containers[0]
+
+			checkProbe(t, "readiness", app.ReadinessProbe, c.readinessHTTP, intstr.FromInt(httpReadinessPort))
+			checkProbe(t, "liveness", app.LivenessProbe, c.livenessHTTP, intstr.FromString(c.healthPortName))
+
+			found := false
+			for _, p := range app.Ports {
+				if p.ContainerPort == tcpHealthPort {
+					found = p.Name == c.healthPortName
+				}
+			}
+			if !found {
+				t.Fatalf("expected health port %s, found: %+v", c.healthPortName, app.Ports)
+			}
+		})
+	}
+
+	for _, p := range getContainerPorts(echo.Config{}) {
+		if p.Port == tcpHealthPort && p.Protocol != model.ProtocolTCP {
+			t.Fatalf("expected TCP health port by default, found %s", p.Protocol)
+		}
+	}
+
+	if err := validateProbePorts(echo.Config{Service: "a", HealthCheckProtocol: model.ProtocolUDP}); err == nil {
+		t.Fatal("expected error for UDP health check protocol")
+	}
+}
+
+// checkProbe checks that the probe is an httpGet or tcpSocket probe of the given port.
+func checkProbe(t *testing.T, name string, probe *kubeCore.Probe, http bool, port intstr.IntOrString) {
+	t.Helper()
+	if probe == nil {
+		t.Fatalf("missing %s probe", name)
+	}
+	switch {
+	case http && (probe.HTTPGet == nil || probe.HTTPGet.Port != port):
+		t.Fatalf("expected httpGet %s probe of port %s, found: %+v", name, port.String(), probe.Handler)
+	case !http && (probe.TCPSocket == nil || probe.TCPSocket.Port != port):
+		t.Fatalf("expected tcpSocket %s probe of port %s, found: %+v", name, port.String(), probe.Handler)
+	}
+}
+
+func TestGenerateYAMLStatefulSet(t *testing.T) {
+	setImageFlags(t)
+
+	cfg := echo.Config{
+		Service:        "a",
+		Version:        "v1",
+		Replicas:       2,
+		ServiceType:    echo.ServiceTypeClusterIP,
+		DeploymentKind: echo.DeploymentKindStatefulSet,
+		Ports: []echo.Port{
+			{Name: "grpc", Protocol: model.ProtocolGRPC, ServicePort: 70, InstancePort: 7070},
+		},
+	}
+	if err := validateDeploymentKind(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Headless || cfg.ServiceType != echo.ServiceTypeHeadless {
+		t.Fatalf("expected a headless service for the StatefulSet, found %s", cfg.ServiceType)
+	}
+	out, err := generateYAML(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, _ := parseGeneratedYAML(t, out)
+	if service.Spec.ClusterIP != kubeCore.ClusterIPNone {
+		t.Fatalf("expected headless Service, found: %+v", service.Spec)
+	}
+	var statefulSet kubeApps.StatefulSet
+	for _, doc := range strings.Split(out, "\n---\n") {
+		if strings.Contains(doc, "kind: StatefulSet\n") {
+			if err := yaml.Unmarshal([]byte(doc), &statefulSet); err != nil {
+				t.Fatalf("failed parsing generated YAML: %v\n%s", err, doc)
+			}
+		}
+	}
+	if statefulSet.Name != "a-v1" || statefulSet.Spec.ServiceName != "a" || *statefulSet.Spec.Replicas != 2 {
+		t.Fatalf("unexpected StatefulSet:\n%s", out)
+	}
+
+	for _, cfg := range []echo.Config{
+		{Service: "a", DeploymentKind: echo.DeploymentKindStatefulSet, ServiceType: echo.ServiceTypeNodePort},
+		{Service: "a", DeploymentKind: echo.DeploymentKindStatefulSet, ServiceType: echo.ServiceTypeClusterIP, Gateway: true},
+		{Service: "a", DeploymentKind: "DaemonSet"},
+	} {
+		if err := validateDeploymentKind(&cfg); err == nil {
+			t.Errorf("expected error for deployment kind %s of %+v", cfg.DeploymentKind, cfg)
+		}
+	}
+}
+
+func TestGenerateYAMLWithoutSidecar(t *testing.T) {
+	setImageFlags(t)
+
+	cfg := echo.Config{
+		Service:           "a",
+		Version:           "v1",
+		Replicas:          1,
+		BootstrapOverride: "bootstrap",
+		Ports: []echo.Port{
+			{Name: "grpc", Protocol: model.ProtocolGRPC, ServicePort: 70, InstancePort: 7070},
+		},
+	}
+	out, err := generateYAML(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Injection is disabled explicitly, in case it's enabled for the namespace.
+	_, deployment := parseGeneratedYAML(t, out)
+	annotations := deployment.Spec.Template.Annotations
+	if annotations["sidecar.istio.io/inject"] != "false" {
+		t.Fatalf("expected injection to be disabled, found annotations: %v", annotations)
+	}
+	if _, ok := annotations["sidecar.istio.io/bootstrapOverride"]; ok {
+		t.Fatalf("unexpected sidecar annotations: %v", annotations)
+	}
+
+	pod := kubeCore.Pod{Spec: deployment.Spec.Template.Spec}
+	if hasProxyContainer(pod) {
+		t.Fatal("expected no proxy container")
+	}
+	pod.Spec.Containers = append(pod.Spec.Containers, kubeCore.Container{Name: proxyContainerName})
+	if !hasProxyContainer(pod) {
+		t.Fatal("expected proxy container")
+	}
+}
+
+func TestGenerateDeploymentYAML(t *testing.T) {
+	setImageFlags(t)
+
+	// The defaults are filled in as by New, including the gRPC port.
+	out, err := GenerateDeploymentYAML(nil, echo.Config{
+		Namespace: fakeNamespace("ns"),
+		Sidecar:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, deployment := parseGeneratedYAML(t, out)
+	if service.Name != "echo" || deployment.Name != "echo-v1" || *deployment.Spec.Replicas != 1 {
+		t.Fatalf("unexpected YAML for the defaults:\n%s", out)
+	}
+	found := false
+	for _, p := range service.Spec.Ports {
+		if p.Name == "grpc" && p.Port > 0 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected default gRPC port, found: %+v", service.Spec.Ports)
+	}
+
+	// The Service of a StatefulSet is made headless, as by New.
+	out, err = GenerateDeploymentYAML(nil, echo.Config{
+		Namespace:      fakeNamespace("ns"),
+		DeploymentKind: echo.DeploymentKindStatefulSet,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, _ = parseGeneratedYAML(t, out)
+	if service.Spec.ClusterIP != kubeCore.ClusterIPNone {
+		t.Fatalf("expected headless Service, found: %+v", service.Spec)
+	}
+}
+
+type fakeNamespace string
+
+func (n fakeNamespace) Name() string {
+	return string(n)
+}
+
+func TestPodOrdinal(t *testing.T) {
+	for name, expected := range map[string]int{
+		"a-v1-0":  0,
+		"a-v1-12": 12,
+		"a-v1":    -1,
+		"a":       -1,
+	} {
+		if actual := podOrdinal(name); actual != expected {
+			t.Errorf("podOrdinal(%q): expected %d, got %d", name, expected, actual)
+		}
+	}
+}
+
+// setImageFlags sets the image flags required to generate the YAML.
+func setImageFlags(t *testing.T) {
+	t.Helper()
+	for name, value := range map[string]string{"istio.test.hub": "hub", "istio.test.tag": "tag"} {
+		if err := flag.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// parseGeneratedYAML parses the Service and Deployment in the generated YAML.
+func parseGeneratedYAML(t *testing.T, out string) (kubeCore.Service, kubeApps.Deployment) {
+	t.Helper()
+	var service kubeCore.Service
+	var deployment kubeApps.Deployment
+	for _, doc := range strings.Split(out, "\n---\n") {
+		var err error
+		switch {
+		case strings.Contains(doc, "kind: Service\n"):
+			err = yaml.Unmarshal([]byte(doc), &service)
+		case strings.Contains(doc, "kind: Deployment\n"):
+			err = yaml.Unmarshal([]byte(doc), &deployment)
+		}
+		if err != nil {
+			t.Fatalf("failed parsing generated YAML: %v\n%s", err, doc)
+		}
+	}
+	return service, deployment
+}
+
+func TestDomainOverride(t *testing.T) {
+	cfg := echo.Config{
+		Service:   "a",
+		Namespace: fakeNamespace("ns"),
+		Domain:    "svc.cluster.internal",
+		Headless:  true,
+	}
+	if err := common.FillInDefaults(nil, defaultDomain, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if fqdn := cfg.FQDN(); fqdn != "a.ns.svc.cluster.internal" {
+		t.Fatalf("expected FQDN with the domain override, got %s", fqdn)
+	}
+	if fqdn := podFQDN(kubeCore.EndpointAddress{Hostname: "a-0"}, cfg); fqdn != "a-0.a.ns.svc.cluster.internal" {
+		t.Fatalf("expected pod FQDN with the domain override, got %s", fqdn)
+	}
+
+	// Without an override, the default domain is used.
+	cfg = echo.Config{Service: "a", Namespace: fakeNamespace("ns")}
+	if err := common.FillInDefaults(nil, defaultDomain, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if fqdn := cfg.FQDN(); fqdn != "a.ns.svc.cluster.local" {
+		t.Fatalf("expected FQDN with the default domain, got %s", fqdn)
+	}
+
+	for _, domain := range []string{".svc.cluster.internal", "svc.cluster.internal."} {
+		cfg := echo.Config{Service: "a", Namespace: fakeNamespace("ns"), Domain: domain}
+		if err := common.FillInDefaults(nil, defaultDomain, &cfg); err == nil {
+			t.Errorf("expected domain %q to be rejected", domain)
+		}
+	}
+}
+
+func TestGenerateYAMLImage(t *testing.T) {
+	setImageFlags(t)
+
+	cfg := echo.Config{
+		Service:  "a",
+		Version:  "v1",
+		Replicas: 1,
+		Ports: []echo.Port{
+			{Name: "grpc", Protocol: model.ProtocolGRPC, ServicePort: 70, InstancePort: 7070},
+		},
+	}
+
+	// By default, the app image of the command line is used.
+	out, err := generateYAML(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, deployment := parseGeneratedYAML(t, out)
+	if spec := deployment.Spec.Template.Spec; len(spec.ImagePullSecrets) != 0 || spec.Containers[0].Image != "hub/app:tag" {
+		t.Fatalf("unexpected default image: %s (pull secrets %v)", spec.Containers[0].Image, spec.ImagePullSecrets)
+	}
+
+	cfg.Image = "registry.example.com/echo:dev"
+	cfg.ImagePullPolicy = string(kubeCore.PullNever)
+	cfg.ImagePullSecrets = []string{"registry-credentials"}
+	if err := validateImage(cfg); err != nil {
+		t.Fatal(err)
+	}
+	out, err = generateYAML(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, deployment = parseGeneratedYAML(t, out)
+	spec := deployment.Spec.Template.Spec
+	if len(spec.ImagePullSecrets) != 1 || spec.ImagePullSecrets[0].Name != "registry-credentials" {
+		t.Fatalf("expected the image pull secret, found: %v", spec.ImagePullSecrets)
+	}
+	app := spec.Containers[0]
+	if app.Image != cfg.Image || app.ImagePullPolicy != kubeCore.PullNever {
+		t.Fatalf("expected the custom image, found %s (%s)", app.Image, app.ImagePullPolicy)
+	}
+
+	// Pull secrets are only used for a custom image.
+	cfg.Image = ""
+	if err := validateImage(cfg); err == nil {
+		t.Fatal("expected error for image pull secrets without a custom image")
+	}
+}
+
+func TestGenerateYAMLResources(t *testing.T) {
+	setImageFlags(t)
+
+	cfg := echo.Config{
+		Service:  "a",
+		Version:  "v1",
+		Replicas: 1,
+		Ports: []echo.Port{
+			{Name: "grpc", Protocol: model.ProtocolGRPC, ServicePort: 70, InstancePort: 7070},
+		},
+		Resources: kubeCore.ResourceRequirements{
+			Requests: kubeCore.ResourceList{
+				kubeCore.ResourceCPU:    kubeApiResource.MustParse("100m"),
+				kubeCore.ResourceMemory: kubeApiResource.MustParse("64Mi"),
+			},
+			Limits: kubeCore.ResourceList{
+				kubeCore.ResourceCPU:    kubeApiResource.MustParse("200m"),
+				kubeCore.ResourceMemory: kubeApiResource.MustParse("64Mi"),
+			},
+		},
+		ExtendedResources: map[string]string{"example.com/device": "1"},
+	}
+	if err := validateResources(cfg); err != nil {
+		t.Fatal(err)
+	}
+	out, err := generateYAML(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, deployment := parseGeneratedYAML(t, out)
+	resources := deployment.Spec.Template.Spec.Containers[0].Resources
+	for name, expected := range map[kubeCore.ResourceName]string{
+		kubeCore.ResourceCPU:    "100m",
+		kubeCore.ResourceMemory: "64Mi",
+		"example.com/device":    "1",
+	} {
+		if q := resources.Requests[name]; q.String() != expected {
+			t.Errorf("expected request %s of %s, found %s", expected, name, q.String())
+		}
+	}
+	if q := resources.Limits[kubeCore.ResourceCPU]; q.String() != "200m" {
+		t.Errorf("expected CPU limit 200m, found %s", q.String())
+	}
+
+	// Limits must not be smaller than the requests.
+	cfg.Resources.Limits[kubeCore.ResourceMemory] = kubeApiResource.MustParse("32Mi")
+	if err := validateResources(cfg); err == nil {
+		t.Fatal("expected error for a limit smaller than the request")
+	}
+}
+
+func TestGenerateYAMLCertSourceFile(t *testing.T) {
+	setImageFlags(t)
+
+	cfg := echo.Config{
+		Service:    "a",
+		Version:    "v1",
+		Replicas:   1,
+		Sidecar:    true,
+		CertSource: echo.CertSourceFile,
+		CertSecret: "certs",
+		SidecarVolumes: []echo.SidecarVolume{
+			{Name: "lua", ConfigMap: "scripts", MountPath: "/etc/lua"},
+		},
+		Ports: []echo.Port{
+			{Name: "grpc", Protocol: model.ProtocolGRPC, ServicePort: 70, InstancePort: 7070},
+		},
+	}
+	out, err := generateYAML(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The secret is a volume of the pod, mounted into the application.
+	_, deployment := parseGeneratedYAML(t, out)
+	spec := deployment.Spec.Template.Spec
+	var volume *kubeCore.Volume
+	for i, v := range spec.Volumes {
+		if v.Name == certVolumeName {
+			volume = &spec.Volumes[i]
+		}
+	}
+	if volume == nil || volume.Secret == nil || volume.Secret.SecretName != "certs" {
+		t.Fatalf("expected cert secret volume, found: %+v", spec.Volumes)
+	}
+	mounted := false
+	for _, m := range spec.Containers[0].VolumeMounts {
+		mounted = mounted || (m.Name == certVolumeName && m.MountPath == customCertDir)
+	}
+	if !mounted {
+		t.Fatalf("expected cert secret mount in the application, found: %+v", spec.Containers[0].VolumeMounts)
+	}
+
+	// The sidecar mounts the same volume, along with its own volumes.
+	annotations := deployment.Spec.Template.Annotations
+	var mounts map[string]kubeCore.VolumeMount
+	if err := json.Unmarshal([]byte(annotations[userMountAnnotation]), &mounts); err != nil {
+		t.Fatal(err)
+	}
+	if m := mounts[certVolumeName]; m.MountPath != customCertDir || !m.ReadOnly {
+		t.Fatalf("expected cert secret mount in the sidecar, found: %v", annotations[userMountAnnotation])
+	}
+	if _, ok := mounts["lua"]; !ok {
+		t.Fatalf("expected sidecar volume mount, found: %v", annotations[userMountAnnotation])
+	}
+	var volumes map[string]kubeCore.VolumeSource
+	if err := json.Unmarshal([]byte(annotations[userVolumeAnnotation]), &volumes); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := volumes[certVolumeName]; ok || len(volumes) != 1 {
+		t.Fatalf("expected only the sidecar volume to be added, found: %v", annotations[userVolumeAnnotation])
+	}
+
+	// Without other sidecar volumes, only the mount is added.
+	cfg.SidecarVolumes = nil
+	out, err = generateYAML(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, deployment = parseGeneratedYAML(t, out)
+	annotations = deployment.Spec.Template.Annotations
+	if _, ok := annotations[userVolumeAnnotation]; ok || annotations[userMountAnnotation] == "" {
+		t.Fatalf("expected only the mount annotation, found: %v", annotations)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/scopes"
+
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ resource.Dumper = &instance{}
+
+// DiagnosticBundle writes the pod specs, events and container logs of all pods of this instance to
+// the given directory, along with the Envoy config dumps and stats of their sidecars and the
+// endpoints of the service. Every collector is best-effort: failures are returned, but don't stop
+// the remaining state from being collected.
+func (c *instance) DiagnosticBundle(outDir string) (err error) {
+	ns := c.cfg.Namespace.Name()
+	marshaler := jsonpb.Marshaler{
+		Indent: "  ",
+	}
+
+	write := func(name, content string) {
+		if e := ioutil.WriteFile(path.Join(outDir, name), []byte(content), os.ModePerm); e != nil {
+			err = multierror.Append(err, fmt.Errorf("failed writing %s: %v", name, e))
+		}
+	}
+	fail := func(format string, args ...interface{}) {
+		err = multierror.Append(err, fmt.Errorf(format, args...))
+	}
+
+	if endpoints, e := c.env.GetEndpoints(ns, c.cfg.Service, kubeApiMeta.GetOptions{}); e != nil {
+		fail("failed getting endpoints for service %s/%s: %v", ns, c.cfg.Service, e)
+	} else if str, e := marshaler.MarshalToString(endpoints); e != nil {
+		fail("failed marshaling endpoints for service %s/%s: %v", ns, c.cfg.Service, e)
+	} else {
+		write(fmt.Sprintf("endpoints_%s.json", c.cfg.Service), str)
+	}
+
+	pods, e := c.env.GetPods(ns, "app="+c.cfg.Service, "version="+c.cfg.Version)
+	if e != nil {
+		fail("failed getting pods for service %s/%s: %v", ns, c.cfg.Service, e)
+		return
+	}
+
+	for i := range pods {
+		pod := &pods[i]
+
+		if str, e := marshaler.MarshalToString(pod); e != nil {
+			fail("failed marshaling pod %s: %v", pod.Name, e)
+		} else {
+			write(fmt.Sprintf("pod_%s.json", pod.Name), str)
+		}
+
+		if events, e := c.env.GetEvents(ns, pod.Name); e != nil {
+			fail("failed getting events for pod %s: %v", pod.Name, e)
+		} else {
+			eventsStr := ""
+			for j := range events {
+				str, e := marshaler.MarshalToString(&events[j])
+				if e != nil {
+					fail("failed marshaling event for pod %s: %v", pod.Name, e)
+					continue
+				}
+				eventsStr += str + "\n"
+			}
+			write(fmt.Sprintf("pod_events_%s.json", pod.Name), eventsStr)
+		}
+
+		for _, container := range pod.Spec.Containers {
+			logs, e := c.env.Logs(ns, pod.Name, container.Name)
+			if e != nil {
+				fail("failed getting logs for pod/container %s/%s: %v", pod.Name, container.Name, e)
+				continue
+			}
+			write(fmt.Sprintf("%s_%s.log", pod.Name, container.Name), logs)
+		}
+
+		if !c.cfg.Sidecar {
+			continue
+		}
+
+		s := &sidecar{
+			podNamespace: ns,
+			podName:      pod.Name,
+			accessor:     c.env.Accessor,
+		}
+		if cfg, e := s.Config(); e != nil {
+			fail("failed getting config dump for pod %s: %v", pod.Name, e)
+		} else if str, e := marshaler.MarshalToString(cfg); e != nil {
+			fail("failed marshaling config dump for pod %s: %v", pod.Name, e)
+		} else {
+			write(fmt.Sprintf("config_dump_%s.json", pod.Name), str)
+		}
+
+		if stats, e := s.Stats(); e != nil {
+			fail("failed getting stats for pod %s: %v", pod.Name, e)
+		} else if by, e := json.MarshalIndent(stats, "", "  "); e != nil {
+			fail("failed marshaling stats for pod %s: %v", pod.Name, e)
+		} else {
+			write(fmt.Sprintf("stats_%s.json", pod.Name), string(by))
+		}
+	}
+	return
+}
+
+// Dump implements resource.Dumper.
+func (c *instance) Dump() {
+	outDir, err := c.ctx.CreateTmpDirectory(fmt.Sprintf("echo-%s-%s-state", c.cfg.Service, c.cfg.Version))
+	if err != nil {
+		scopes.CI.Errorf("Unable to create dump folder for echo %s: %v", c.cfg.Service, err)
+		return
+	}
+
+	scopes.CI.Infof("Dumping diagnostics for echo %s to %s", c.cfg.Service, outDir)
+	if err := c.DiagnosticBundle(outDir); err != nil {
+		scopes.CI.Errorf("Failed collecting some diagnostics for echo %s: %v", c.cfg.Service, err)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"errors"
+	"fmt"
+
+	"istio.io/istio/pkg/test/framework/components/echo"
+)
+
+// DeployStage is the stage of New at which the deployment of an echo Instance failed.
+type DeployStage string
+
+const (
+	// DeployStageConfig is the validation of the echo.Config, before anything is deployed.
+	DeployStageConfig DeployStage = "Config"
+
+	// DeployStageGenerate is the generation of the deployment YAML.
+	DeployStageGenerate DeployStage = "Generate"
+
+	// DeployStageApply is the application of the deployment YAML to the cluster.
+	DeployStageApply DeployStage = "Apply"
+
+	// DeployStageService is the lookup of the addresses of the deployed Service.
+	DeployStageService DeployStage = "Service"
+)
+
+var (
+	// ErrMissingGRPCPort is the cause of a DeployError if the echo.Config has no gRPC port for
+	// controlling the app.
+	ErrMissingGRPCPort = errors.New("unable to find GRPC command port")
+
+	// ErrMissingGalley is the cause of a DeployError if echo.Config.RequireGalley is set, but no
+	// Galley was provided.
+	ErrMissingGalley = errors.New("galley must be provided")
+
+	// ErrServiceNotFound is the cause of a DeployError if the deployed Service isn't registered
+	// within a timeout.
+	ErrServiceNotFound = errors.New("service not found")
+
+	// ErrInvalidClusterIP is the cause of a DeployError if a non-headless Service has no valid
+	// ClusterIP.
+	ErrInvalidClusterIP = errors.New("invalid ClusterIP")
+)
+
+// DeployError is returned by New if the echo Instance could not be deployed.
+type DeployError struct {
+	// Service being deployed.
+	Service string
+
+	// Stage that failed.
+	Stage DeployStage
+
+	// Err is the failure.
+	Err error
+
+	// Cause of the failure, which can be compared against the errors above (e.g. ErrMissingGRPCPort).
+	// Nil if the failure has none of these causes.
+	Cause error
+}
+
+// Error implements error
+func (e *DeployError) Error() string {
+	return fmt.Sprintf("failed deploying echo %s (%s): %v", e.Service, e.Stage, e.Err)
+}
+
+func newDeployError(cfg echo.Config, stage DeployStage, err error) error {
+	return &DeployError{
+		Service: cfg.Service,
+		Stage:   stage,
+		Err:     err,
+		Cause:   causeOf(err),
+	}
+}
+
+// causeError details one of the causes of a DeployError.
+type causeError struct {
+	cause  error
+	detail string
+}
+
+// Error implements error
+func (e *causeError) Error() string {
+	return e.cause.Error() + e.detail
+}
+
+// newCauseError returns an error for the cause, followed by the formatted detail.
+func newCauseError(cause error, format string, args ...interface{}) error {
+	return &causeError{
+		cause:  cause,
+		detail: fmt.Sprintf(format, args...),
+	}
+}
+
+// causeOf returns the cause of the error, or nil if it has none of the causes of a DeployError.
+func causeOf(err error) error {
+	switch err {
+	case ErrMissingGRPCPort, ErrMissingGalley, ErrServiceNotFound, ErrInvalidClusterIP:
+		return err
+	}
+	if e, ok := err.(*causeError); ok {
+		return e.cause
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pkg/test/kube"
+)
+
+func (w *workload) ForwardPort(remote uint16) (uint16, func(), error) {
+	forwarder, err := w.accessor.NewPortForwarder(w.pod, 0, remote)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err = forwarder.Start(); err != nil {
+		return 0, nil, fmt.Errorf("failed forwarding port %d of pod %s/%s: %v", remote, w.pod.Namespace, w.pod.Name, err)
+	}
+	local, err := localPort(forwarder.Address())
+	if err != nil {
+		_ = forwarder.Close()
+		return 0, nil, err
+	}
+	return local, w.trackForward(forwarder), nil
+}
+
+// trackForward records the forwarder, so that it is closed with the workload, and returns a func
+// that closes it early. The func may be called multiple times.
+func (w *workload) trackForward(forwarder kube.PortForwarder) func() {
+	w.forwardsMutex.Lock()
+	defer w.forwardsMutex.Unlock()
+	if w.forwards == nil {
+		w.forwards = make(map[kube.PortForwarder]struct{})
+	}
+	w.forwards[forwarder] = struct{}{}
+
+	return func() {
+		w.forwardsMutex.Lock()
+		_, open := w.forwards[forwarder]
+		delete(w.forwards, forwarder)
+		w.forwardsMutex.Unlock()
+		if open {
+			_ = forwarder.Close()
+		}
+	}
+}
+
+// closeForwards closes the forwards created by ForwardPort that were not closed by the caller.
+func (w *workload) closeForwards() (err error) {
+	w.forwardsMutex.Lock()
+	defer w.forwardsMutex.Unlock()
+	for forwarder := range w.forwards {
+		err = multierror.Append(err, forwarder.Close()).ErrorOrNil()
+	}
+	w.forwards = nil
+	return
+}
+
+// localPort returns the port of the local address of a forward.
+func localPort(address string) (uint16, error) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return 0, fmt.Errorf("invalid forwarded address %q: %v", address, err)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid forwarded address %q: %v", address, err)
+	}
+	return uint16(p), nil
+}
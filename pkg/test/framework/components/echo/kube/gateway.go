@@ -0,0 +1,183 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"fmt"
+	"text/template"
+
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/core/image"
+	"istio.io/istio/pkg/test/util/tmpl"
+)
+
+const (
+	gatewayStatusPort = 15020
+
+	gatewayYAML = `
+{{- if .ServiceAccount }}
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: {{ .Service }}
+---
+{{- end }}
+apiVersion: v1
+kind: Service
+metadata:
+  name: {{ .Service }}
+  labels:
+    app: {{ .Service }}
+spec:
+{{- if .ServiceType }}
+  type: {{ .ServiceType }}
+{{- end }}
+  ports:
+{{- range $i, $p := .Ports }}
+  - name: {{ $p.Name }}
+    port: {{ $p.ServicePort }}
+    targetPort: {{ $p.InstancePort }}
+{{- end }}
+  selector:
+    app: {{ .Service }}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .Service }}-{{ .Version }}
+spec:
+  replicas: {{ .Replicas }}
+  selector:
+    matchLabels:
+      app: {{ .Service }}
+      version: {{ .Version }}
+  template:
+    metadata:
+      labels:
+        app: {{ .Service }}
+        version: {{ .Version }}
+        istio: {{ .Service }}
+      annotations:
+        sidecar.istio.io/inject: "false"
+    spec:
+{{- if .ServiceAccount }}
+      serviceAccountName: {{ .Service }}
+{{- end }}
+      containers:
+      - name: istio-proxy
+        image: {{ .Hub }}/proxyv2:{{ .Tag }}
+        imagePullPolicy: {{ .PullPolicy }}
+        args:
+        - proxy
+        - router
+        - --domain
+        - $(POD_NAMESPACE).{{ .Domain }}
+        - --serviceCluster
+        - {{ .Service }}
+        - --proxyAdminPort
+        - "{{ .AdminPort }}"
+        - --statusPort
+        - "{{ .StatusPort }}"
+        - --controlPlaneAuthPolicy
+        - NONE
+        - --discoveryAddress
+        - istio-pilot.{{ .PilotNamespace }}:15010
+        ports:
+{{- range $i, $p := .Ports }}
+        - containerPort: {{ $p.InstancePort }}
+{{- end }}
+        - containerPort: {{ .StatusPort }}
+        readinessProbe:
+          httpGet:
+            path: /healthz/ready
+            port: {{ .StatusPort }}
+          initialDelaySeconds: 1
+          periodSeconds: 2
+          failureThreshold: 30
+        env:
+        - name: POD_NAME
+          valueFrom:
+            fieldRef:
+              fieldPath: metadata.name
+        - name: POD_NAMESPACE
+          valueFrom:
+            fieldRef:
+              fieldPath: metadata.namespace
+        - name: INSTANCE_IP
+          valueFrom:
+            fieldRef:
+              fieldPath: status.podIP
+        - name: SERVICE_ACCOUNT
+          valueFrom:
+            fieldRef:
+              fieldPath: spec.serviceAccountName
+        - name: ISTIO_META_POD_NAME
+          valueFrom:
+            fieldRef:
+              fieldPath: metadata.name
+        - name: ISTIO_META_CONFIG_NAMESPACE
+          valueFrom:
+            fieldRef:
+              fieldPath: metadata.namespace
+        - name: ISTIO_META_ROUTER_MODE
+          value: sni-dnat
+        volumeMounts:
+        - name: istio-certs
+          mountPath: /etc/certs
+          readOnly: true
+      volumes:
+      - name: istio-certs
+        secret:
+          secretName: istio.{{ if .ServiceAccount }}{{ .Service }}{{ else }}default{{ end }}
+          optional: true
+`
+)
+
+var (
+	gatewayTemplate *template.Template
+)
+
+func init() {
+	gatewayTemplate = template.New("echo_gateway")
+	if _, err := gatewayTemplate.Parse(gatewayYAML); err != nil {
+		panic(fmt.Sprintf("unable to parse echo gateway template: %v", err))
+	}
+}
+
+// generateGatewayYAML generates the YAML for a gateway (i.e. istio-proxy running as a router, with
+// no echo app), which connects to the Pilot in the given namespace.
+func generateGatewayYAML(cfg echo.Config, pilotNamespace string) (string, error) {
+	settings, err := image.SettingsFromCommandLine()
+	if err != nil {
+		return "", err
+	}
+
+	params := map[string]interface{}{
+		"Hub":            settings.Hub,
+		"Tag":            settings.Tag,
+		"PullPolicy":     settings.PullPolicy,
+		"Service":        cfg.Service,
+		"Version":        cfg.Version,
+		"Replicas":       cfg.Replicas,
+		"Domain":         cfg.Domain,
+		"ServiceAccount": cfg.ServiceAccount,
+		"ServiceType":    kubeServiceType(cfg),
+		"Ports":          cfg.Ports,
+		"AdminPort":      proxyAdminPort,
+		"StatusPort":     gatewayStatusPort,
+		"PilotNamespace": pilotNamespace,
+	}
+	return tmpl.Execute(gatewayTemplate, params)
+}
@@ -0,0 +1,62 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"fmt"
+
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/kube"
+
+	kubeCore "k8s.io/api/core/v1"
+)
+
+func (c *instance) HealthSummary() (echo.HealthSummary, error) {
+	pods, err := c.env.GetPods(c.cfg.Namespace.Name(), "app="+c.cfg.Service, "version="+c.cfg.Version)
+	if err != nil {
+		return echo.HealthSummary{}, fmt.Errorf("failed retrieving pods for service %s/%s: %v",
+			c.cfg.Namespace.Name(), c.cfg.Service, err)
+	}
+	return newHealthSummary(pods), nil
+}
+
+func newHealthSummary(pods []kubeCore.Pod) echo.HealthSummary {
+	out := echo.HealthSummary{
+		Replicas: len(pods),
+	}
+	for i := range pods {
+		pod := &pods[i]
+		if kube.CheckPodReady(pod) == nil {
+			out.Ready++
+		} else {
+			out.NotReady++
+		}
+
+		for _, status := range pod.Status.ContainerStatuses {
+			out.Restarts += int(status.RestartCount)
+
+			// Prefer the current termination, falling back to the previous one (e.g. after a restart).
+			terminated := status.State.Terminated
+			if terminated == nil {
+				terminated = status.LastTerminationState.Terminated
+			}
+			if terminated != nil {
+				out.TerminationReasons = append(out.TerminationReasons, fmt.Sprintf("%s/%s: %s (exit code %d)",
+					pod.Name, status.Name, terminated.Reason, terminated.ExitCode))
+			}
+		}
+	}
+	return out
+}
@@ -15,24 +15,34 @@
 package kube
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"io"
+	"math/rand"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"istio.io/istio/pilot/pkg/model"
 	appEcho "istio.io/istio/pkg/test/echo/client"
 	"istio.io/istio/pkg/test/framework/components/echo"
 	"istio.io/istio/pkg/test/framework/components/echo/common"
 	kubeEnv "istio.io/istio/pkg/test/framework/components/environment/kube"
+	"istio.io/istio/pkg/test/framework/components/istio"
 	"istio.io/istio/pkg/test/framework/resource"
-	"istio.io/istio/pkg/test/kube"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/retry"
 
 	kubeCore "k8s.io/api/core/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	kubeApiResource "k8s.io/apimachinery/pkg/api/resource"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
@@ -48,76 +58,268 @@ var (
 
 type instance struct {
 	id        resource.ID
+	ctx       resource.Context
 	cfg       echo.Config
 	clusterIP string
-	env       *kubeEnv.Environment
-	workloads []*workload
-	grpcPort  uint16
-	mutex     sync.Mutex
+	// externalAddress of the load balancer, if the ServiceType is LoadBalancer.
+	externalAddress string
+	env             *kubeEnv.Environment
+	workloads       []*workload
+	grpcPort        uint16
+	// mutex guards the workloads, and the Replicas of cfg (which are updated by Scale).
+	mutex sync.Mutex
+	// manifest is the YAML applied by New, which is deleted by Delete.
+	manifest string
+
+	// nextWorkload is the index of the next workload to make a call, for round-robin selection.
+	nextWorkload int
 }
 
 func New(ctx resource.Context, cfg echo.Config) (out echo.Instance, err error) {
 	// Fill in defaults for any missing values.
 	if err = common.FillInDefaults(ctx, defaultDomain, &cfg); err != nil {
-		return nil, err
+		return nil, newDeployError(cfg, DeployStageConfig, err)
 	}
 
+	env := ctx.Environment().(*kubeEnv.Environment)
+
 	// Validate the configuration.
-	if cfg.Galley == nil {
-		// Galley is not actually required currently, but it will be once Pilot gets
-		// all resources from Galley. Requiring now for forward-compatibility.
-		return nil, errors.New("galley must be provided")
+	if err = validateConfig(&cfg, env); err != nil {
+		return nil, newDeployError(cfg, DeployStageConfig, err)
 	}
 
-	env := ctx.Environment().(*kubeEnv.Environment)
 	c := &instance{
+		ctx: ctx,
 		env: env,
 		cfg: cfg,
 	}
 	c.id = ctx.TrackResource(c)
 
-	// Save the GRPC port.
-	grpcPort := common.GetGRPCPort(&cfg)
-	if grpcPort == nil {
-		return nil, errors.New("unable fo find GRPC command port")
+	if !cfg.Gateway {
+		// Save the GRPC port.
+		c.grpcPort = uint16(common.GetGRPCPort(&cfg).InstancePort)
 	}
-	c.grpcPort = uint16(grpcPort.InstancePort)
 
 	// Generate the deployment YAML.
-	generatedYAML, err := generateYAML(cfg)
+	generatedYAML, err := generateDeploymentYAML(ctx, cfg)
 	if err != nil {
-		return nil, err
+		return nil, newDeployError(cfg, DeployStageGenerate, err)
 	}
 
 	// Deploy the YAML.
 	if err = env.ApplyContents(cfg.Namespace.Name(), generatedYAML); err != nil {
-		return nil, err
+		return nil, newDeployError(cfg, DeployStageApply, err)
 	}
+	c.manifest = generatedYAML
 
-	// Now retrieve the service information to find the ClusterIP
-	s, err := env.GetService(cfg.Namespace.Name(), cfg.Service)
-	if err != nil {
-		return nil, err
+	// Now retrieve the service information to find the ClusterIP and, for load balancers, the
+	// external address.
+	if err = c.initServiceAddresses(); err != nil {
+		return nil, newDeployError(cfg, DeployStageService, err)
 	}
 
-	c.clusterIP = s.Spec.ClusterIP
-	switch c.clusterIP {
-	case kubeCore.ClusterIPNone, "":
-		if !cfg.Headless {
-			return nil, fmt.Errorf("invalid ClusterIP %s for non-headless service %s/%s",
-				c.clusterIP,
-				c.cfg.Namespace.Name(),
-				c.cfg.Service)
+	return c, nil
+}
+
+// validateConfig validates the configuration of an echo Instance, with defaults filled in, before
+// anything is deployed.
+func validateConfig(cfg *echo.Config, env *kubeEnv.Environment) error {
+	if cfg.Galley == nil {
+		// Galley is not actually required currently, but it will be once Pilot gets
+		// all resources from Galley.
+		if cfg.RequireGalley {
+			return ErrMissingGalley
+		}
+		scopes.Framework.Warnf("echo %s: galley was not provided, which will be required once Pilot gets all resources from Galley",
+			cfg.Service)
+	}
+	if cfg.ReadinessGRPCPort != "" {
+		if _, err := getReadinessGRPCPort(*cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.VolumeClaim != nil && cfg.VolumeClaim.StorageClass != "" {
+		if _, err := env.GetStorageClass(cfg.VolumeClaim.StorageClass); err != nil {
+			return fmt.Errorf("storage class %s for service %s not available: %v",
+				cfg.VolumeClaim.StorageClass, cfg.Service, err)
 		}
-		c.clusterIP = ""
 	}
+	if err := validateDeploymentKind(cfg); err != nil {
+		return err
+	}
+	if err := validateServiceType(*cfg); err != nil {
+		return err
+	}
+	if err := validateProbePorts(*cfg); err != nil {
+		return err
+	}
+	if err := validateNetworkAttachments(*cfg); err != nil {
+		return err
+	}
+	if cfg.DNSCapture != nil && !cfg.Sidecar {
+		return fmt.Errorf("DNS capture for service %s requires a sidecar", cfg.Service)
+	}
+	if cfg.ProxyResourceProfile != "" || cfg.ProxyResources != nil {
+		if !cfg.Sidecar {
+			return fmt.Errorf("proxy resources for service %s require a sidecar", cfg.Service)
+		}
+		if _, err := cfg.EffectiveProxyResources(); err != nil {
+			return err
+		}
+	}
+	if err := validateSidecarVolumes(*cfg, env); err != nil {
+		return err
+	}
+	if err := validateSecurityContext(*cfg); err != nil {
+		return err
+	}
+	if err := validateCertSource(*cfg, env); err != nil {
+		return err
+	}
+	if err := validateExtendedResources(*cfg); err != nil {
+		return err
+	}
+	if err := validateResources(*cfg); err != nil {
+		return err
+	}
+	if err := validateImage(*cfg); err != nil {
+		return err
+	}
+	if cfg.BootstrapOverride != "" {
+		if !cfg.Sidecar {
+			return fmt.Errorf("bootstrap override for service %s requires a sidecar", cfg.Service)
+		}
+		if _, err := env.GetConfigMap(cfg.Namespace.Name(), cfg.BootstrapOverride); err != nil {
+			return fmt.Errorf("bootstrap override config map %s for service %s not available: %v",
+				cfg.BootstrapOverride, cfg.Service, err)
+		}
+	}
+	if !cfg.Gateway {
+		grpcPort := common.GetGRPCPort(cfg)
+		if grpcPort == nil {
+			return ErrMissingGRPCPort
+		}
+		if grpcPort.Unexposed {
+			// The workloads are discovered via the endpoints of the Service for this port.
+			return fmt.Errorf("grpc port %s for service %s must be exposed", grpcPort.Name, cfg.Service)
+		}
+	}
+	return nil
+}
 
-	return c, nil
+// GenerateDeploymentYAML returns the YAML (i.e. the Service and Deployment, or StatefulSet) that New
+// applies for the configuration, after filling in the defaults as New does. If no Namespace is
+// configured, the default namespace is created via the context.
+func GenerateDeploymentYAML(ctx resource.Context, cfg echo.Config) (string, error) {
+	if err := common.FillInDefaults(ctx, defaultDomain, &cfg); err != nil {
+		return "", err
+	}
+	if err := validateDeploymentKind(&cfg); err != nil {
+		return "", err
+	}
+	return generateDeploymentYAML(ctx, cfg)
+}
+
+func generateDeploymentYAML(ctx resource.Context, cfg echo.Config) (string, error) {
+	if cfg.Gateway {
+		icfg, err := istio.DefaultConfig(ctx)
+		if err != nil {
+			return "", err
+		}
+		return generateGatewayYAML(cfg, icfg.ConfigNamespace)
+	}
+	return generateYAML(cfg)
+}
+
+// getReadinessGRPCPort returns the gRPC port used for readiness checks, as named by the configuration.
+func getReadinessGRPCPort(cfg echo.Config) (*echo.Port, error) {
+	for _, p := range cfg.Ports {
+		if p.Name == cfg.ReadinessGRPCPort {
+			if p.Protocol != model.ProtocolGRPC {
+				return nil, fmt.Errorf("readiness port %s must be GRPC, but is %s", p.Name, p.Protocol)
+			}
+			return &p, nil
+		}
+	}
+	return nil, fmt.Errorf("readiness port %s not found in ports for service %s", cfg.ReadinessGRPCPort, cfg.Service)
+}
+
+// validateProbePorts verifies that the readiness and health ports don't collide with each other, or
+// with an application port that can't be used for the probe. An HTTP application port may be used
+// for readiness, and any port other than gRPC for health.
+func validateProbePorts(cfg echo.Config) error {
+	if cfg.ReadinessPort < 0 || cfg.HealthPort < 0 {
+		return fmt.Errorf("invalid readiness port %d or health port %d for service %s",
+			cfg.ReadinessPort, cfg.HealthPort, cfg.Service)
+	}
+	switch cfg.HealthCheckProtocol {
+	case "", model.ProtocolTCP, model.ProtocolHTTP:
+	default:
+		return fmt.Errorf("unsupported health check protocol %s for service %s: must be TCP or HTTP",
+			cfg.HealthCheckProtocol, cfg.Service)
+	}
+	readinessPort, healthPort := getReadinessPort(cfg), getHealthPort(cfg)
+	if cfg.ReadinessGRPCPort == "" && readinessPort == healthPort {
+		return fmt.Errorf("readiness and health ports for service %s must differ, but both are %d",
+			cfg.Service, readinessPort)
+	}
+	for _, p := range cfg.Ports {
+		if cfg.ReadinessGRPCPort == "" && p.InstancePort == readinessPort && p.Protocol != model.ProtocolHTTP {
+			return fmt.Errorf("readiness port %d for service %s collides with %s port %s",
+				readinessPort, cfg.Service, p.Protocol, p.Name)
+		}
+		if p.InstancePort == healthPort && p.Protocol == model.ProtocolGRPC {
+			return fmt.Errorf("health port %d for service %s collides with %s port %s",
+				healthPort, cfg.Service, p.Protocol, p.Name)
+		}
+	}
+	return nil
+}
+
+// getServicePorts returns the ports exposed by the Service, which may be a subset of the container ports.
+func getServicePorts(cfg echo.Config) []echo.Port {
+	ports := make([]echo.Port, 0, len(cfg.Ports))
+	for _, p := range cfg.Ports {
+		if !p.Unexposed {
+			ports = append(ports, p)
+		}
+	}
+	return ports
+}
+
+// getReadinessPort returns the number of the HTTP readiness port.
+func getReadinessPort(cfg echo.Config) int {
+	if cfg.ReadinessPort > 0 {
+		return cfg.ReadinessPort
+	}
+	return httpReadinessPort
+}
+
+// getHealthPort returns the number of the TCP health port.
+func getHealthPort(cfg echo.Config) int {
+	if cfg.HealthPort > 0 {
+		return cfg.HealthPort
+	}
+	return tcpHealthPort
+}
+
+// getHealthPortProtocol returns the protocol of the health port, on which liveness is checked.
+func getHealthPortProtocol(cfg echo.Config) model.Protocol {
+	if cfg.HealthCheckProtocol == model.ProtocolHTTP {
+		return model.ProtocolHTTP
+	}
+	return model.ProtocolTCP
+}
+
+// getHealthPortName returns the name of the health port, e.g. "tcp-health-port".
+func getHealthPortName(cfg echo.Config) string {
+	return strings.ToLower(string(getHealthPortProtocol(cfg))) + "-health-port"
 }
 
 // getContainerPorts converts the ports to a port list of container ports.
 // Adds ports for health/readiness if necessary.
-func getContainerPorts(ports []echo.Port) model.PortList {
+func getContainerPorts(cfg echo.Config) model.PortList {
+	ports := cfg.Ports
 	containerPorts := make(model.PortList, 0, len(ports))
 	var healthPort *model.Port
 	var readyPort *model.Port
@@ -131,32 +333,32 @@ func getContainerPorts(ports []echo.Port) model.PortList {
 		containerPorts = append(containerPorts, cport)
 
 		switch p.Protocol {
-		case model.ProtocolGRPC:
+		case model.ProtocolGRPC, model.ProtocolUDP:
 			continue
 		case model.ProtocolHTTP:
-			if p.InstancePort == httpReadinessPort {
+			if p.InstancePort == getReadinessPort(cfg) {
 				readyPort = cport
 			}
-		default:
-			if p.InstancePort == tcpHealthPort {
-				healthPort = cport
-			}
+		}
+		if p.InstancePort == getHealthPort(cfg) {
+			healthPort = cport
 		}
 	}
 
-	// If we haven't added the readiness/health ports, do so now.
-	if readyPort == nil {
+	// If we haven't added the readiness/health ports, do so now. Readiness via gRPC health
+	// uses an existing gRPC port, so no HTTP readiness port is needed.
+	if readyPort == nil && cfg.ReadinessGRPCPort == "" {
 		containerPorts = append(containerPorts, &model.Port{
 			Name:     "http-readiness-port",
 			Protocol: model.ProtocolHTTP,
-			Port:     httpReadinessPort,
+			Port:     getReadinessPort(cfg),
 		})
 	}
 	if healthPort == nil {
 		containerPorts = append(containerPorts, &model.Port{
-			Name:     "tcp-health-port",
-			Protocol: model.ProtocolHTTP,
-			Port:     tcpHealthPort,
+			Name:     getHealthPortName(cfg),
+			Protocol: getHealthPortProtocol(cfg),
+			Port:     getHealthPort(cfg),
 		})
 	}
 	return containerPorts
@@ -166,6 +368,14 @@ func (c *instance) ID() resource.ID {
 	return c.id
 }
 
+func (c *instance) String() string {
+	return fmt.Sprintf("%s@%s", c.cfg.NamespacedName(), c.cfg.Version)
+}
+
+func (c *instance) Equals(other echo.Instance) bool {
+	return other != nil && c.ID().String() == other.ID().String()
+}
+
 func (c *instance) Address() string {
 	return c.clusterIP
 }
@@ -175,8 +385,9 @@ func (c *instance) Workloads() ([]echo.Workload, error) {
 		return nil, err
 	}
 
-	out := make([]echo.Workload, 0, len(c.workloads))
-	for _, w := range c.workloads {
+	workloads := c.currentWorkloads()
+	out := make([]echo.Workload, 0, len(workloads))
+	for _, w := range workloads {
 		out = append(out, w)
 	}
 	return out, nil
@@ -190,32 +401,122 @@ func (c *instance) WorkloadsOrFail(t testing.TB) []echo.Workload {
 	return out
 }
 
-func initAllWorkloads(accessor *kube.Accessor, instances []echo.Instance) error {
+func (c *instance) WorkloadsByVersion() (map[string][]echo.Workload, error) {
+	workloads, err := c.Workloads()
+	if err != nil {
+		return nil, err
+	}
+	return common.WorkloadsByVersion(workloads), nil
+}
+
+func (c *instance) WorkloadsByVersionOrFail(t testing.TB) map[string][]echo.Workload {
+	out, err := c.WorkloadsByVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func (c *instance) Sidecars() ([]echo.Sidecar, error) {
+	if err := c.WaitUntilReady(); err != nil {
+		return nil, err
+	}
+
+	workloads := c.currentWorkloads()
+	out := make([]echo.Sidecar, 0, len(workloads))
+	for _, w := range workloads {
+		if w.sidecar != nil {
+			out = append(out, w.sidecar)
+		}
+	}
+	return out, nil
+}
+
+func (c *instance) SidecarsOrFail(t testing.TB) []echo.Sidecar {
+	out, err := c.Sidecars()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func initAllWorkloads(env *kubeEnv.Environment, instances []echo.Instance, opts ...retry.Option) error {
+	needInit, instanceEndpoints, err := waitForUninitializedEndpoints(instances, env.Settings().EndpointWaitConcurrency,
+		func(ns, service string) (*kubeCore.Endpoints, error) {
+			_, endpoints, err := env.WaitUntilServiceEndpointsAreReady(ns, service, opts...)
+			return endpoints, err
+		})
+	if err != nil {
+		return err
+	}
+
+	// Initialize the workloads for each instance.
+	for i, inst := range needInit {
+		if err := inst.initWorkloads(instanceEndpoints[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForUninitializedEndpoints waits for the endpoints of the instances that aren't initialized yet
+// (see waitForAllEndpoints), and returns those instances along with the endpoints for each.
+func waitForUninitializedEndpoints(instances []echo.Instance, concurrency int,
+	wait func(ns, service string) (*kubeCore.Endpoints, error)) ([]*instance, []*kubeCore.Endpoints, error) {
 	needInit := getUninitializedInstances(instances)
 	if len(needInit) == 0 {
 		// Everything is already initialized.
-		return nil
+		return nil, nil, nil
+	}
+
+	// Only wait for the instances that need to be initialized, so that the endpoints line up with them.
+	configs := make([]echo.Config, 0, len(needInit))
+	for _, inst := range needInit {
+		configs = append(configs, inst.Config())
+	}
+	instanceEndpoints, err := waitForAllEndpoints(configs, concurrency, wait)
+	if err != nil {
+		return nil, nil, err
+	}
+	return needInit, instanceEndpoints, nil
+}
+
+// waitForAllEndpoints waits for the endpoints of the services of the configurations in parallel,
+// with at most the given number of waits at a time (or no limit if <= 0), and returns the endpoints
+// for each configuration. The endpoints of services scaled to zero aren't waited for, and are nil.
+func waitForAllEndpoints(configs []echo.Config, concurrency int,
+	wait func(ns, service string) (*kubeCore.Endpoints, error)) ([]*kubeCore.Endpoints, error) {
+	if concurrency <= 0 {
+		concurrency = len(configs)
 	}
 
-	instanceEndpoints := make([]*kubeCore.Endpoints, len(needInit))
+	instanceEndpoints := make([]*kubeCore.Endpoints, len(configs))
 	aggregateErrMux := &sync.Mutex{}
 	var aggregateErr error
 	wg := sync.WaitGroup{}
+	sem := make(chan struct{}, concurrency)
 
-	for i, inst := range instances {
+	for i, cfg := range configs {
+		if cfg.Replicas == 0 {
+			// There are no endpoints to wait for.
+			continue
+		}
 		wg.Add(1)
 
 		instanceIndex := i
-		serviceName := inst.Config().Service
-		serviceNamespace := inst.Config().Namespace.Name()
+		serviceName := cfg.Service
+		serviceNamespace := cfg.Namespace.Name()
 
 		// Run the waits in parallel.
 		go func() {
 			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
 
 			// Wait until all the endpoints are ready for this service
-			_, endpoints, err := accessor.WaitUntilServiceEndpointsAreReady(serviceNamespace, serviceName)
+			endpoints, err := wait(serviceNamespace, serviceName)
 			if err != nil {
+				err = fmt.Errorf("failed waiting for the endpoints of service %s/%s: %v", serviceNamespace, serviceName, err)
 				aggregateErrMux.Lock()
 				aggregateErr = multierror.Append(aggregateErr, err)
 				aggregateErrMux.Unlock()
@@ -228,16 +529,16 @@ func initAllWorkloads(accessor *kube.Accessor, instances []echo.Instance) error
 	wg.Wait()
 
 	if aggregateErr != nil {
-		return aggregateErr
+		return nil, aggregateErr
 	}
+	return instanceEndpoints, nil
+}
 
-	// Initialize the workloads for each instance.
-	for i, inst := range needInit {
-		if err := inst.initWorkloads(instanceEndpoints[i]); err != nil {
-			return err
-		}
-	}
-	return nil
+// currentWorkloads returns a copy of the workloads, which may be replaced concurrently (e.g. by Scale).
+func (c *instance) currentWorkloads() []*workload {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]*workload{}, c.workloads...)
 }
 
 func (c *instance) isInitialized() bool {
@@ -258,26 +559,181 @@ func getUninitializedInstances(instances []echo.Instance) []*instance {
 }
 
 func (c *instance) WaitUntilReady(outboundInstances ...echo.Instance) error {
+	return c.waitUntilReady(time.Time{}, outboundInstances...)
+}
+
+func (c *instance) WaitUntilReadyWithin(timeout time.Duration, outboundInstances ...echo.Instance) error {
+	err := c.waitUntilReady(time.Now().Add(timeout), outboundInstances...)
+	if err != nil {
+		return fmt.Errorf("service %s not ready within %v: %v", c.cfg.Service, timeout, err)
+	}
+	return nil
+}
+
+func (c *instance) IsReady(outboundInstances ...echo.Instance) (bool, error) {
+	if !c.isInitialized() {
+		var endpoints *kubeCore.Endpoints
+		if c.Config().Replicas > 0 {
+			var ready bool
+			var err error
+			endpoints, ready, err = checkEndpoints(c.env.GetEndpoints, c.cfg.Namespace.Name(), c.cfg.Service)
+			if !ready || err != nil {
+				return false, err
+			}
+		}
+		if err := c.initWorkloads(endpoints); err != nil {
+			return false, err
+		}
+	}
+	deps, err := common.ReadinessDependencies(c)
+	if err != nil {
+		return false, err
+	}
+	outboundInstances = append(append([]echo.Instance{}, outboundInstances...), deps...)
+	for _, outbound := range outboundInstances {
+		if ready, err := outbound.IsReady(); !ready || err != nil {
+			return false, err
+		}
+	}
+
+	accept := common.OutboundConfigAcceptFunc(outboundInstances...)
+	workloads := c.currentWorkloads()
+	for _, w := range workloads {
+		if w.sidecar == nil {
+			continue
+		}
+		if accepted, err := w.sidecar.CheckConfig(accept); !accepted || err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (c *instance) WaitUntilNotReady(timeout time.Duration) error {
+	if err := waitForNoReadyEndpoints(c.env.GetEndpoints, c.env.GetPod, c.cfg.Namespace.Name(), c.cfg.Service,
+		c.cfg.Version, timeout, time.Second); err != nil {
+		return fmt.Errorf("%s still ready after %v: %v", c, timeout, err)
+	}
+
+	// The previous workloads are gone, or about to be.
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.resetWorkloads()
+}
+
+// checkEndpoints gets the endpoints of the service once, and indicates whether they're ready, as
+// waited for by WaitUntilServiceEndpointsAreReady. Missing endpoints aren't ready (yet).
+func checkEndpoints(get func(ns, service string, options kubeApiMeta.GetOptions) (*kubeCore.Endpoints, error),
+	ns, service string) (*kubeCore.Endpoints, bool, error) {
+	endpoints, err := get(ns, service, kubeApiMeta.GetOptions{})
+	if kubeErrors.IsNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	for _, subset := range endpoints.Subsets {
+		if len(subset.Addresses) > 0 && len(subset.NotReadyAddresses) == 0 {
+			return endpoints, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// waitForNoReadyEndpoints polls the endpoints of the service until none of them are ready pods of
+// the given version (or the endpoints don't exist) or the timeout elapses. Other versions of the
+// service may share its endpoints. Addresses of pods that are gone are ignored.
+func waitForNoReadyEndpoints(get func(ns, service string, options kubeApiMeta.GetOptions) (*kubeCore.Endpoints, error),
+	getPod func(ns, name string) (kubeCore.Pod, error), ns, service, version string, timeout, delay time.Duration) error {
+	return retry.UntilSuccess(func() error {
+		endpoints, err := get(ns, service, kubeApiMeta.GetOptions{})
+		if kubeErrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ready := 0
+		for _, subset := range endpoints.Subsets {
+			for _, addr := range subset.Addresses {
+				if addr.TargetRef != nil && addr.TargetRef.Kind == "Pod" {
+					pod, err := getPod(ns, addr.TargetRef.Name)
+					if kubeErrors.IsNotFound(err) {
+						continue
+					}
+					if err != nil {
+						return err
+					}
+					if pod.Labels["version"] != version {
+						continue
+					}
+				}
+				ready++
+			}
+		}
+		if ready > 0 {
+			return fmt.Errorf("%d ready endpoints for service %s/%s/%s", ready, ns, service, version)
+		}
+		return nil
+	}, retry.Timeout(timeout), retry.Delay(delay))
+}
+
+// waitUntilReady implements WaitUntilReady, bounding each wait by the deadline, if not zero.
+func (c *instance) waitUntilReady(deadline time.Time, outboundInstances ...echo.Instance) error {
+	// Wait for the dependencies, which also require outbound config.
+	deps, err := common.WaitForReadinessDependencies(c, deadline)
+	if err != nil {
+		return err
+	}
+	outboundInstances = append(append([]echo.Instance{}, outboundInstances...), deps...)
 
 	// Initialize the workloads for all instances.
-	if err := initAllWorkloads(c.env.Accessor, append([]echo.Instance{c}, outboundInstances...)); err != nil {
+	if err := initAllWorkloads(c.env, append([]echo.Instance{c}, outboundInstances...),
+		deadlineOptions(deadline)...); err != nil {
 		return err
 	}
 
-	// Wait for the outbound config to be received by each workload from Pilot.
-	for _, w := range c.workloads {
-		if w.sidecar != nil {
-			if err := w.sidecar.WaitForConfig(common.OutboundConfigAcceptFunc(outboundInstances...)); err != nil {
-				return err
-			}
+	// Wait for the outbound config to be received by each workload from Pilot, in parallel. The
+	// accept function is stateless, so it's shared by all of the waits.
+	accept := common.OutboundConfigAcceptFunc(outboundInstances...)
+	workloads := c.currentWorkloads()
+
+	aggregateErrMux := &sync.Mutex{}
+	var aggregateErr error
+	wg := sync.WaitGroup{}
+	for _, w := range workloads {
+		if w.sidecar == nil {
+			continue
 		}
+		wg.Add(1)
+
+		s := w.sidecar
+		go func() {
+			defer wg.Done()
+			if err := s.WaitForConfig(accept, deadlineOptions(deadline)...); err != nil {
+				err = fmt.Errorf("failed waiting for the outbound config of sidecar %s: %v", s.NodeID(), err)
+				aggregateErrMux.Lock()
+				aggregateErr = multierror.Append(aggregateErr, err)
+				aggregateErrMux.Unlock()
+			}
+		}()
 	}
+	wg.Wait()
 
-	return nil
+	return aggregateErr
+}
+
+// deadlineOptions returns the retry options to limit a wait to the deadline, if not zero.
+func deadlineOptions(deadline time.Time) []retry.Option {
+	if deadline.IsZero() {
+		return nil
+	}
+	return []retry.Option{retry.Timeout(common.UntilDeadline(deadline))}
 }
 
 func (c *instance) WaitUntilReadyOrFail(t testing.TB, outboundInstances ...echo.Instance) {
 	if err := c.WaitUntilReady(outboundInstances...); err != nil {
+		c.Dump()
 		t.Fatal(err)
 	}
 }
@@ -290,11 +746,31 @@ func (c *instance) initWorkloads(endpoints *kubeCore.Endpoints) error {
 		// Already ready.
 		return nil
 	}
+	return c.setWorkloads(endpoints)
+}
+
+// replaceWorkloads closes the current workloads and replaces them with those for the given
+// endpoints. The mutex is held throughout, so that concurrent callers never see the workloads
+// missing or partially rebuilt.
+func (c *instance) replaceWorkloads(endpoints *kubeCore.Endpoints) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.resetWorkloads(); err != nil {
+		return err
+	}
+	return c.setWorkloads(endpoints)
+}
 
+// setWorkloads creates the workloads for the given endpoints. Must be called with the mutex held.
+func (c *instance) setWorkloads(endpoints *kubeCore.Endpoints) error {
 	workloads := make([]*workload, 0)
+	if endpoints == nil {
+		endpoints = &kubeCore.Endpoints{}
+	}
 	for _, subset := range endpoints.Subsets {
 		for _, addr := range subset.Addresses {
-			workload, err := newWorkload(addr, c.cfg.Sidecar, c.grpcPort, c.env.Accessor)
+			workload, err := newWorkload(addr, c.cfg, c.grpcPort, c.env.Accessor)
 			if err != nil {
 				return err
 			}
@@ -302,7 +778,14 @@ func (c *instance) initWorkloads(endpoints *kubeCore.Endpoints) error {
 		}
 	}
 
-	if len(workloads) == 0 {
+	if c.cfg.DeploymentKind == echo.DeploymentKindStatefulSet {
+		// Order the workloads by their stable pod names.
+		sort.SliceStable(workloads, func(i, j int) bool {
+			return podOrdinal(workloads[i].podName) < podOrdinal(workloads[j].podName)
+		})
+	}
+
+	if len(workloads) == 0 && c.cfg.Replicas > 0 {
 		return fmt.Errorf("no pods found for service %s/%s/%s", c.cfg.Namespace.Name(), c.cfg.Service, c.cfg.Version)
 	}
 
@@ -314,35 +797,263 @@ func (c *instance) Close() (err error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	for _, w := range c.workloads {
-		err = multierror.Append(err, w.Close()).ErrorOrNil()
+	err = c.resetWorkloads()
+
+	if c.cfg.VolumeClaim != nil {
+		err = multierror.Append(err, c.env.DeletePersistentVolumeClaim(c.cfg.Namespace.Name(),
+			volumeClaimName(c.cfg))).ErrorOrNil()
 	}
-	c.workloads = nil
 	return
 }
 
+// validateSidecarVolumes verifies the sidecar volumes in the configuration, including that the
+// referenced Secrets and ConfigMaps exist.
+func validateSidecarVolumes(cfg echo.Config, env *kubeEnv.Environment) error {
+	if len(cfg.SidecarVolumes) == 0 {
+		return nil
+	}
+	if !cfg.Sidecar {
+		return fmt.Errorf("sidecar volumes for service %s require a sidecar", cfg.Service)
+	}
+
+	names := make(map[string]bool, len(cfg.SidecarVolumes))
+	for _, v := range cfg.SidecarVolumes {
+		switch {
+		case v.Name == "":
+			return fmt.Errorf("sidecar volume for service %s is missing a name", cfg.Service)
+		case names[v.Name]:
+			return fmt.Errorf("duplicate sidecar volume %s for service %s", v.Name, cfg.Service)
+		case v.Name == certVolumeName:
+			return fmt.Errorf("sidecar volume name %s for service %s is reserved for the cert secret", v.Name, cfg.Service)
+		case cfg.CertSource == echo.CertSourceFile && v.MountPath == customCertDir:
+			return fmt.Errorf("sidecar volume %s for service %s conflicts with the cert secret mounted at %s",
+				v.Name, cfg.Service, customCertDir)
+		case v.MountPath == "":
+			return fmt.Errorf("sidecar volume %s for service %s is missing a mount path", v.Name, cfg.Service)
+		case (v.Secret == "") == (v.ConfigMap == ""):
+			return fmt.Errorf("sidecar volume %s for service %s requires exactly one of secret or config map",
+				v.Name, cfg.Service)
+		}
+		names[v.Name] = true
+
+		if v.Secret != "" {
+			if _, err := env.GetSecret(cfg.Namespace.Name()).Get(v.Secret, kubeApiMeta.GetOptions{}); err != nil {
+				return fmt.Errorf("secret %s for sidecar volume %s of service %s not available: %v",
+					v.Secret, v.Name, cfg.Service, err)
+			}
+		} else if _, err := env.GetConfigMap(cfg.Namespace.Name(), v.ConfigMap); err != nil {
+			return fmt.Errorf("config map %s for sidecar volume %s of service %s not available: %v",
+				v.ConfigMap, v.Name, cfg.Service, err)
+		}
+	}
+	return nil
+}
+
+// validateSecurityContext verifies that the RunAsUser and FSGroup in the configuration are consistent.
+func validateSecurityContext(cfg echo.Config) error {
+	if cfg.RunAsUser != nil && *cfg.RunAsUser < 0 {
+		return fmt.Errorf("invalid runAsUser %d for service %s", *cfg.RunAsUser, cfg.Service)
+	}
+	if cfg.FSGroup != nil {
+		if *cfg.FSGroup < 0 {
+			return fmt.Errorf("invalid fsGroup %d for service %s", *cfg.FSGroup, cfg.Service)
+		}
+		if cfg.RunAsUser != nil && *cfg.RunAsUser == 0 {
+			return fmt.Errorf("fsGroup for service %s has no effect when running as root (runAsUser 0)", cfg.Service)
+		}
+	}
+	return nil
+}
+
+// validateResources verifies that the resource limits in the configuration aren't smaller than the
+// corresponding requests, and that the resources don't overlap with the ExtendedResources.
+func validateResources(cfg echo.Config) error {
+	for name, limit := range cfg.Resources.Limits {
+		if request, ok := cfg.Resources.Requests[name]; ok && limit.Cmp(request) < 0 {
+			return fmt.Errorf("limit %s of resource %s for service %s is smaller than the request %s",
+				limit.String(), name, cfg.Service, request.String())
+		}
+	}
+	for name := range cfg.ExtendedResources {
+		_, requested := cfg.Resources.Requests[kubeCore.ResourceName(name)]
+		_, limited := cfg.Resources.Limits[kubeCore.ResourceName(name)]
+		if requested || limited {
+			return fmt.Errorf("resource %s for service %s is set in both Resources and ExtendedResources", name, cfg.Service)
+		}
+	}
+	return nil
+}
+
+// validateImage verifies the custom image settings in the configuration.
+func validateImage(cfg echo.Config) error {
+	if len(cfg.ImagePullSecrets) > 0 && cfg.Image == "" {
+		return fmt.Errorf("image pull secrets for service %s require a custom image", cfg.Service)
+	}
+	switch kubeCore.PullPolicy(cfg.ImagePullPolicy) {
+	case "", kubeCore.PullAlways, kubeCore.PullIfNotPresent, kubeCore.PullNever:
+	default:
+		return fmt.Errorf("unsupported image pull policy %q for service %s", cfg.ImagePullPolicy, cfg.Service)
+	}
+	return nil
+}
+
+// validateExtendedResources verifies the names and quantities of the extended resources in the
+// configuration.
+func validateExtendedResources(cfg echo.Config) error {
+	for name, quantity := range cfg.ExtendedResources {
+		if !strings.Contains(name, "/") {
+			return fmt.Errorf("extended resource %q for service %s must be qualified with a domain (e.g. example.com/%s)",
+				name, cfg.Service, name)
+		}
+		if _, err := kubeApiResource.ParseQuantity(quantity); err != nil {
+			return fmt.Errorf("invalid quantity %q of extended resource %s for service %s: %v",
+				quantity, name, cfg.Service, err)
+		}
+	}
+	return nil
+}
+
+// validateCertSource verifies the certificate options in the configuration.
+func validateCertSource(cfg echo.Config, env *kubeEnv.Environment) error {
+	switch cfg.CertSource {
+	case "", echo.CertSourceSDS:
+		if cfg.CertSecret != "" {
+			return fmt.Errorf("cert secret for service %s is only allowed with cert source %q",
+				cfg.Service, echo.CertSourceFile)
+		}
+	case echo.CertSourceFile:
+		if cfg.CertSecret == "" {
+			return fmt.Errorf("cert source %q for service %s requires a cert secret", cfg.CertSource, cfg.Service)
+		}
+		if _, err := env.GetSecret(cfg.Namespace.Name()).Get(cfg.CertSecret, kubeApiMeta.GetOptions{}); err != nil {
+			return fmt.Errorf("cert secret %s for service %s not available: %v", cfg.CertSecret, cfg.Service, err)
+		}
+	default:
+		return fmt.Errorf("unsupported cert source %q for service %s", cfg.CertSource, cfg.Service)
+	}
+	return nil
+}
+
 func (c *instance) Config() echo.Config {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	return c.cfg
 }
 
 func (c *instance) Call(opts echo.CallOptions) (appEcho.ParsedResponses, error) {
+	return c.CallContext(context.Background(), opts)
+}
+
+func (c *instance) CallContext(ctx context.Context, opts echo.CallOptions) (appEcho.ParsedResponses, error) {
+	result, err := c.callWithResult(ctx, opts)
+	return result.Responses, err
+}
+
+func (c *instance) CallWithResult(opts echo.CallOptions) (echo.CallResult, error) {
+	return c.callWithResult(context.Background(), opts)
+}
+
+func (c *instance) callWithResult(ctx context.Context, opts echo.CallOptions) (echo.CallResult, error) {
+	if c.cfg.Gateway {
+		return echo.CallResult{}, fmt.Errorf("gateway %s can't make calls", c)
+	}
+
 	// If we haven't already initialized the client, do so now.
-	if err := c.WaitUntilReady(); err != nil {
-		return nil, err
+	if err := c.waitUntilReadyContext(ctx); err != nil {
+		if ctx.Err() != nil {
+			return echo.CallResult{}, common.CallError(c, &opts, err)
+		}
+		return echo.CallResult{}, err
 	}
 
-	out, err := common.CallEcho(c.workloads[0].Instance, &opts, common.IdentityOutboundPortSelector)
+	var result echo.CallResult
+	responses, err := common.RetryCall(ctx, &opts, c.cfg.Sidecar, func() (appEcho.ParsedResponses, error) {
+		// Each attempt re-selects the workload, since the previous one may have gone away.
+		candidates, err := c.selectWorkloads(opts)
+		if err != nil {
+			return nil, err
+		}
+		result = echo.CallResult{}
+		for i, w := range candidates {
+			if w.Instance == nil {
+				// There is no pod to call from (e.g. a manually managed endpoint).
+				err = fmt.Errorf("workload %s of %s has no echo app to make calls", w.Address(), c)
+				if i < len(candidates)-1 {
+					continue
+				}
+				return nil, err
+			}
+			start := time.Now()
+			result.Responses, err = common.CallEcho(ctx, w.Instance, &opts, common.IdentityOutboundPortSelector)
+			result.Duration = time.Since(start)
+			if status.Code(err) == codes.Unavailable && i < len(candidates)-1 {
+				// The workload has gone away (e.g. the pod was deleted). Try the next one.
+				continue
+			}
+			for _, r := range result.Responses {
+				r.Source = w.Hostname()
+			}
+			result.Source = w
+			break
+		}
+		return result.Responses, err
+	})
+	result.Responses = responses
+	err = common.CheckMTLSMode(c.cfg.Sidecar, opts.Port, result.Responses, err)
 	if err != nil {
-		if opts.Port != nil {
-			err = fmt.Errorf("failed calling %s->'%s://%s:%d/%s': %v",
-				c.Config().Service,
-				strings.ToLower(string(opts.Port.Protocol)),
-				opts.Target.Config().Service,
-				opts.Port.ServicePort,
-				opts.Path,
-				err)
+		return echo.CallResult{}, common.CallError(c, &opts, err)
+	}
+	return result, nil
+}
+
+// waitUntilReadyContext is like WaitUntilReady, but returns early once the context is done. The
+// wait itself continues in the background until it completes or times out.
+func (c *instance) waitUntilReadyContext(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- c.WaitUntilReady()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for %s to be ready: %v", c, ctx.Err())
+	}
+}
+
+// selectWorkloads returns the workloads to make a call with the given options, in order of
+// preference. The first is selected by opts.SourceWorkload, followed by the others (in order) as
+// fallbacks, unless the selection is pinned.
+func (c *instance) selectWorkloads(opts echo.CallOptions) ([]*workload, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	n := len(c.workloads)
+	if n == 0 {
+		return nil, fmt.Errorf("no workloads for %s", c)
+	}
+
+	start := 0
+	switch opts.SourceWorkload {
+	case "", echo.WorkloadSelectorRoundRobin:
+		start = c.nextWorkload % n
+		c.nextWorkload = start + 1
+	case echo.WorkloadSelectorRandom:
+		start = rand.Intn(n)
+	case echo.WorkloadSelectorPinned:
+		if opts.SourceWorkloadIndex < 0 || opts.SourceWorkloadIndex >= n {
+			return nil, fmt.Errorf("source workload index %d out of range for %s with %d workloads",
+				opts.SourceWorkloadIndex, c, n)
 		}
-		return nil, err
+		return []*workload{c.workloads[opts.SourceWorkloadIndex]}, nil
+	default:
+		return nil, fmt.Errorf("unsupported source workload selector %q", opts.SourceWorkload)
+	}
+
+	out := make([]*workload, 0, n)
+	for i := 0; i < n; i++ {
+		out = append(out, c.workloads[(start+i)%n])
 	}
 	return out, nil
 }
@@ -350,6 +1061,12 @@ func (c *instance) Call(opts echo.CallOptions) (appEcho.ParsedResponses, error)
 func (c *instance) CallOrFail(t testing.TB, opts echo.CallOptions) appEcho.ParsedResponses {
 	r, err := c.Call(opts)
 	if err != nil {
+		c.Dump()
+
+		// Include the current health of the workloads to help diagnose the failure.
+		if summary, e := c.HealthSummary(); e == nil {
+			t.Fatalf("%v\nhealth of %s: %s", err, c, summary)
+		}
 		t.Fatal(err)
 	}
 	return r
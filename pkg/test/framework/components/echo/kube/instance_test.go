@@ -0,0 +1,727 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/common"
+	kubeEnv "istio.io/istio/pkg/test/framework/components/environment/kube"
+	"istio.io/istio/pkg/test/util/yml"
+
+	kubeCore "k8s.io/api/core/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWaitForAllEndpointsConcurrency(t *testing.T) {
+	const concurrency = 5
+	configs := make([]echo.Config, 0, 30)
+	for i := 0; i < cap(configs); i++ {
+		configs = append(configs, echo.Config{
+			Service:   fmt.Sprintf("svc-%d", i),
+			Namespace: fakeNamespace("ns"),
+			Replicas:  1,
+		})
+	}
+
+	var mutex sync.Mutex
+	active, maxActive := 0, 0
+	endpoints, err := waitForAllEndpoints(configs, concurrency, func(ns, service string) (*kubeCore.Endpoints, error) {
+		mutex.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mutex.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mutex.Lock()
+		active--
+		mutex.Unlock()
+		return &kubeCore.Endpoints{ObjectMeta: kubeApiMeta.ObjectMeta{Namespace: ns, Name: service}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if maxActive > concurrency {
+		t.Fatalf("expected at most %d concurrent waits, found %d", concurrency, maxActive)
+	}
+	for i, cfg := range configs {
+		if endpoints[i] == nil || endpoints[i].Name != cfg.Service {
+			t.Fatalf("unexpected endpoints for service %s: %v", cfg.Service, endpoints[i])
+		}
+	}
+}
+
+func TestWaitForAllEndpointsErrors(t *testing.T) {
+	configs := []echo.Config{
+		{Service: "a", Namespace: fakeNamespace("ns"), Replicas: 1},
+		{Service: "b", Namespace: fakeNamespace("ns"), Replicas: 1},
+		{Service: "c", Namespace: fakeNamespace("ns"), Replicas: 1},
+		// Services scaled to zero aren't waited for.
+		{Service: "d", Namespace: fakeNamespace("ns"), Replicas: 0},
+	}
+	_, err := waitForAllEndpoints(configs, 1, func(ns, service string) (*kubeCore.Endpoints, error) {
+		if service == "c" || service == "d" {
+			return &kubeCore.Endpoints{}, nil
+		}
+		return nil, errors.New("timed out")
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, service := range []string{"ns/a", "ns/b"} {
+		if !strings.Contains(err.Error(), service) {
+			t.Errorf("expected error for service %s, got: %v", service, err)
+		}
+	}
+	if strings.Contains(err.Error(), "ns/c") || strings.Contains(err.Error(), "ns/d") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestWaitForUninitializedEndpoints(t *testing.T) {
+	initialized := &instance{
+		cfg:       echo.Config{Service: "a", Namespace: fakeNamespace("ns"), Replicas: 1},
+		workloads: []*workload{},
+	}
+	fresh := &instance{
+		cfg: echo.Config{Service: "b", Namespace: fakeNamespace("ns"), Replicas: 1},
+	}
+
+	var waited []string
+	needInit, endpoints, err := waitForUninitializedEndpoints([]echo.Instance{initialized, fresh}, 1,
+		func(ns, service string) (*kubeCore.Endpoints, error) {
+			waited = append(waited, service)
+			return &kubeCore.Endpoints{ObjectMeta: kubeApiMeta.ObjectMeta{Namespace: ns, Name: service}}, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(waited) != 1 || waited[0] != "b" {
+		t.Fatalf("expected to only wait for service b, waited for %v", waited)
+	}
+	if len(needInit) != 1 || needInit[0] != fresh || len(endpoints) != 1 || endpoints[0].Name != "b" {
+		t.Fatalf("expected the endpoints of service b for the uninitialized instance, got %v", endpoints)
+	}
+}
+
+func TestWaitForUninitializedEndpointsWhileScaling(t *testing.T) {
+	inst := &instance{
+		cfg: echo.Config{Service: "a", Namespace: fakeNamespace("ns"), Replicas: 1},
+	}
+
+	// Update the replicas the way Scale does, while the endpoints are waited for (run with -race).
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			inst.mutex.Lock()
+			inst.cfg.Replicas = i%2 + 1
+			inst.mutex.Unlock()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if _, _, err := waitForUninitializedEndpoints([]echo.Instance{inst}, 1,
+			func(ns, service string) (*kubeCore.Endpoints, error) {
+				return &kubeCore.Endpoints{}, nil
+			}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+
+	if replicas := inst.Config().Replicas; replicas != 2 {
+		t.Fatalf("expected 2 replicas, got %d", replicas)
+	}
+}
+
+func TestReplaceWorkloadsWhileReading(t *testing.T) {
+	endpoints := func(ips ...string) *kubeCore.Endpoints {
+		subset := kubeCore.EndpointSubset{}
+		for _, ip := range ips {
+			subset.Addresses = append(subset.Addresses, kubeCore.EndpointAddress{IP: ip})
+		}
+		return &kubeCore.Endpoints{Subsets: []kubeCore.EndpointSubset{subset}}
+	}
+	inst := &instance{
+		cfg: echo.Config{Service: "a", Namespace: fakeNamespace("ns"), Replicas: 2},
+		env: &kubeEnv.Environment{},
+	}
+	if err := inst.initWorkloads(endpoints("10.0.0.1", "10.0.0.2")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replace the workloads the way Restart and Scale do, while they are read (run with -race).
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			if err := inst.replaceWorkloads(endpoints(fmt.Sprintf("10.0.1.%d", i), "10.0.2.1")); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		// The workloads are never missing or partially rebuilt.
+		if workloads := inst.currentWorkloads(); len(workloads) != 2 {
+			t.Fatalf("expected 2 workloads, got %d", len(workloads))
+		}
+	}
+	<-done
+
+	workloads := inst.currentWorkloads()
+	if len(workloads) != 2 || workloads[0].Address() != "10.0.1.99" || workloads[1].Address() != "10.0.2.1" {
+		t.Fatalf("unexpected workloads after replacing: %v", workloads)
+	}
+}
+
+func TestDeployError(t *testing.T) {
+	cfg := echo.Config{
+		Service:        "a",
+		Namespace:      fakeNamespace("ns"),
+		ServiceType:    echo.ServiceTypeClusterIP,
+		DeploymentKind: echo.DeploymentKindDeployment,
+		Replicas:       1,
+	}
+
+	err := validateConfig(&cfg, nil)
+	if err != ErrMissingGRPCPort {
+		t.Fatalf("expected ErrMissingGRPCPort, got %v", err)
+	}
+
+	err = newDeployError(cfg, DeployStageConfig, err)
+	deployErr, ok := err.(*DeployError)
+	if !ok {
+		t.Fatalf("expected a DeployError, got %T", err)
+	}
+	if deployErr.Service != "a" || deployErr.Stage != DeployStageConfig || deployErr.Cause != ErrMissingGRPCPort {
+		t.Fatalf("unexpected DeployError: %+v", deployErr)
+	}
+
+	// Detailed errors keep their cause, while other errors have none.
+	err = newDeployError(cfg, DeployStageService, newCauseError(ErrInvalidClusterIP, " None for service ns/a"))
+	if deployErr := err.(*DeployError); deployErr.Cause != ErrInvalidClusterIP ||
+		deployErr.Err.Error() != "invalid ClusterIP None for service ns/a" {
+		t.Fatalf("expected the ErrInvalidClusterIP cause, got %+v", deployErr)
+	}
+	if err := newDeployError(cfg, DeployStageApply, errors.New("forbidden")); err.(*DeployError).Cause != nil {
+		t.Fatalf("expected no cause, got %+v", err)
+	}
+
+	cfg.RequireGalley = true
+	if err := validateConfig(&cfg, nil); err != ErrMissingGalley {
+		t.Fatalf("expected ErrMissingGalley, got %v", err)
+	}
+}
+
+type fakeForwarder struct {
+	closed int
+}
+
+func (f *fakeForwarder) Start() error {
+	return nil
+}
+
+func (f *fakeForwarder) Address() string {
+	return "localhost:12345"
+}
+
+func (f *fakeForwarder) Close() error {
+	f.closed++
+	return nil
+}
+
+func TestForwards(t *testing.T) {
+	if port, err := localPort((&fakeForwarder{}).Address()); err != nil || port != 12345 {
+		t.Fatalf("expected local port 12345, got %d (%v)", port, err)
+	}
+	if _, err := localPort("localhost"); err == nil {
+		t.Fatal("expected an error for an address without a port")
+	}
+
+	w := &workload{}
+	closedEarly := &fakeForwarder{}
+	forgotten := &fakeForwarder{}
+	closeEarly := w.trackForward(closedEarly)
+	w.trackForward(forgotten)
+
+	closeEarly()
+	closeEarly()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if closedEarly.closed != 1 || forgotten.closed != 1 {
+		t.Fatalf("expected each forward to be closed once, got %d and %d", closedEarly.closed, forgotten.closed)
+	}
+}
+
+type fakeID string
+
+func (id fakeID) String() string {
+	return string(id)
+}
+
+func TestInstanceStringAndEquals(t *testing.T) {
+	a := &instance{
+		id:  fakeID("a"),
+		cfg: echo.Config{Service: "a", Namespace: fakeNamespace("ns"), Version: "v1"},
+	}
+	if s := a.String(); s != "ns/a@v1" {
+		t.Fatalf("unexpected String: %s", s)
+	}
+
+	sameID := &instance{id: fakeID("a")}
+	other := &instance{id: fakeID("b"), cfg: a.cfg}
+	if !a.Equals(a) || !a.Equals(sameID) {
+		t.Fatal("expected instances with the same ID to be equal")
+	}
+	if a.Equals(other) || a.Equals(nil) {
+		t.Fatal("expected instances with different IDs not to be equal")
+	}
+}
+
+func TestWaitForService(t *testing.T) {
+	notFound := kubeErrors.NewNotFound(kubeCore.Resource("services"), "a")
+
+	// The Service is returned once it is registered.
+	calls := 0
+	s, err := waitForService(func(ns, name string) (*kubeCore.Service, error) {
+		calls++
+		if calls <= 2 {
+			return nil, notFound
+		}
+		return &kubeCore.Service{ObjectMeta: kubeApiMeta.ObjectMeta{Namespace: ns, Name: name}}, nil
+	}, "ns", "a", time.Second, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "a" || calls != 3 {
+		t.Fatalf("expected service a after 3 calls, got %s after %d", s.Name, calls)
+	}
+
+	// A Service that never appears is reported as not found.
+	_, err = waitForService(func(string, string) (*kubeCore.Service, error) {
+		return nil, notFound
+	}, "ns", "a", 10*time.Millisecond, time.Millisecond)
+	if causeOf(err) != ErrServiceNotFound {
+		t.Fatalf("expected ErrServiceNotFound, got %v", err)
+	}
+
+	// Other errors are not retried.
+	calls = 0
+	_, err = waitForService(func(string, string) (*kubeCore.Service, error) {
+		calls++
+		return nil, errors.New("forbidden")
+	}, "ns", "a", time.Second, time.Millisecond)
+	if err == nil || calls != 1 {
+		t.Fatalf("expected the error without retries, got %v after %d calls", err, calls)
+	}
+}
+
+func TestWaitForNoReadyEndpoints(t *testing.T) {
+	address := func(ip, pod string) kubeCore.EndpointAddress {
+		return kubeCore.EndpointAddress{IP: ip, TargetRef: &kubeCore.ObjectReference{Kind: "Pod", Name: pod}}
+	}
+	ready := kubeCore.EndpointSubset{Addresses: []kubeCore.EndpointAddress{address("10.0.0.1", "a-v1-0")}}
+	notReady := kubeCore.EndpointSubset{NotReadyAddresses: []kubeCore.EndpointAddress{address("10.0.0.2", "a-v1-1")}}
+	otherVersion := kubeCore.EndpointSubset{Addresses: []kubeCore.EndpointAddress{address("10.0.0.3", "a-v2-0")}}
+	getPod := func(_, name string) (kubeCore.Pod, error) {
+		version := map[string]string{"a-v1-0": "v1", "a-v1-1": "v1", "a-v2-0": "v2"}[name]
+		if version == "" {
+			return kubeCore.Pod{}, kubeErrors.NewNotFound(kubeCore.Resource("pods"), name)
+		}
+		return kubeCore.Pod{ObjectMeta: kubeApiMeta.ObjectMeta{Name: name, Labels: map[string]string{"version": version}}}, nil
+	}
+
+	// The wait ends once the ready endpoints of the version are gone.
+	calls := 0
+	if err := waitForNoReadyEndpoints(func(string, string, kubeApiMeta.GetOptions) (*kubeCore.Endpoints, error) {
+		calls++
+		if calls <= 2 {
+			return &kubeCore.Endpoints{Subsets: []kubeCore.EndpointSubset{ready, notReady, otherVersion}}, nil
+		}
+		return &kubeCore.Endpoints{Subsets: []kubeCore.EndpointSubset{notReady, otherVersion}}, nil
+	}, getPod, "ns", "a", "v1", time.Second, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+
+	// Deleted endpoints aren't ready.
+	if err := waitForNoReadyEndpoints(func(string, string, kubeApiMeta.GetOptions) (*kubeCore.Endpoints, error) {
+		return nil, kubeErrors.NewNotFound(kubeCore.Resource("endpoints"), "a")
+	}, getPod, "ns", "a", "v1", time.Second, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	// Addresses of deleted pods are ignored.
+	gone := kubeCore.EndpointSubset{Addresses: []kubeCore.EndpointAddress{address("10.0.0.4", "a-v1-2")}}
+	if err := waitForNoReadyEndpoints(func(string, string, kubeApiMeta.GetOptions) (*kubeCore.Endpoints, error) {
+		return &kubeCore.Endpoints{Subsets: []kubeCore.EndpointSubset{gone}}, nil
+	}, getPod, "ns", "a", "v1", time.Second, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	// A version that stays ready fails the wait.
+	if err := waitForNoReadyEndpoints(func(string, string, kubeApiMeta.GetOptions) (*kubeCore.Endpoints, error) {
+		return &kubeCore.Endpoints{Subsets: []kubeCore.EndpointSubset{ready, otherVersion}}, nil
+	}, getPod, "ns", "a", "v1", 10*time.Millisecond, time.Millisecond); err == nil {
+		t.Fatal("expected error for a version that stays ready")
+	}
+}
+
+func TestVersionEndpoints(t *testing.T) {
+	address := func(ip, pod string) kubeCore.EndpointAddress {
+		return kubeCore.EndpointAddress{IP: ip, TargetRef: &kubeCore.ObjectReference{Kind: "Pod", Name: pod}}
+	}
+	manual := kubeCore.EndpointAddress{IP: "10.0.0.9"}
+	endpoints := &kubeCore.Endpoints{Subsets: []kubeCore.EndpointSubset{{
+		Addresses: []kubeCore.EndpointAddress{
+			address("10.0.0.1", "a-v1-0"), address("10.0.0.2", "a-v2-0"), address("10.0.0.3", "a-v1-1"), manual,
+		},
+	}}}
+	deleted := kubeApiMeta.Now()
+	pods := []kubeCore.Pod{
+		{ObjectMeta: kubeApiMeta.ObjectMeta{Name: "a-v1-0"}},
+		{ObjectMeta: kubeApiMeta.ObjectMeta{Name: "a-v1-1", DeletionTimestamp: &deleted}},
+	}
+
+	// Only the addresses of the (remaining) pods of the version are kept.
+	out := versionEndpoints(endpoints, pods)
+	var ips []string
+	for _, addr := range out.Subsets[0].Addresses {
+		ips = append(ips, addr.IP)
+	}
+	if strings.Join(ips, ",") != "10.0.0.1,10.0.0.9" {
+		t.Fatalf("expected the addresses of pod a-v1-0 and without a pod, got %v", ips)
+	}
+	// The endpoints aren't modified.
+	if len(endpoints.Subsets[0].Addresses) != 4 {
+		t.Fatalf("expected the endpoints to be unmodified, got %v", endpoints.Subsets[0].Addresses)
+	}
+}
+
+func TestDeleteSharedService(t *testing.T) {
+	setImageFlags(t)
+
+	cfg := echo.Config{
+		Service:   "a",
+		Version:   "v1",
+		Namespace: fakeNamespace("ns"),
+		Replicas:  1,
+		Ports: []echo.Port{
+			{Name: "grpc", Protocol: model.ProtocolGRPC, ServicePort: 70, InstancePort: 7070},
+		},
+	}
+	selector := func(service, version string) *kubeApiMeta.LabelSelector {
+		return &kubeApiMeta.LabelSelector{MatchLabels: map[string]string{"app": service, "version": version}}
+	}
+
+	// Only the workloads of other versions of the service share it.
+	if selectsOtherVersion(cfg, []*kubeApiMeta.LabelSelector{selector("a", "v1"), selector("b", "v2"), nil}) {
+		t.Fatal("expected the service not to be shared")
+	}
+	if !selectsOtherVersion(cfg, []*kubeApiMeta.LabelSelector{selector("a", "v1"), selector("a", "v2")}) {
+		t.Fatal("expected the service to be shared with v2")
+	}
+
+	// Only the Deployment of the version is deleted from a shared service.
+	manifest, err := generateYAML(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := workloadManifest(manifest, echo.DeploymentKindDeployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts, err := yml.Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 1 || parts[0].Descriptor.Kind != "Deployment" || parts[0].Descriptor.Metadata.Name != "a-v1" {
+		t.Fatalf("expected only the Deployment a-v1, got:\n%s", out)
+	}
+	if _, err := workloadManifest(manifest, echo.DeploymentKindStatefulSet); err == nil {
+		t.Fatal("expected error for a missing StatefulSet")
+	}
+}
+
+func TestCheckEndpoints(t *testing.T) {
+	ready := kubeCore.EndpointSubset{Addresses: []kubeCore.EndpointAddress{{IP: "10.0.0.1"}}}
+	notReady := kubeCore.EndpointSubset{NotReadyAddresses: []kubeCore.EndpointAddress{{IP: "10.0.0.2"}}}
+	partiallyReady := kubeCore.EndpointSubset{Addresses: ready.Addresses, NotReadyAddresses: notReady.NotReadyAddresses}
+
+	cases := []struct {
+		name      string
+		endpoints *kubeCore.Endpoints
+		err       error
+		ready     bool
+		expectErr bool
+	}{
+		{
+			name:      "ready",
+			endpoints: &kubeCore.Endpoints{Subsets: []kubeCore.EndpointSubset{notReady, ready}},
+			ready:     true,
+		},
+		{
+			name:      "no subsets",
+			endpoints: &kubeCore.Endpoints{},
+		},
+		{
+			name:      "partially ready",
+			endpoints: &kubeCore.Endpoints{Subsets: []kubeCore.EndpointSubset{partiallyReady}},
+		},
+		{
+			name: "not found",
+			err:  kubeErrors.NewNotFound(kubeCore.Resource("endpoints"), "a"),
+		},
+		{
+			name:      "get failed",
+			err:       errors.New("connection refused"),
+			expectErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			calls := 0
+			endpoints, ready, err := checkEndpoints(func(string, string, kubeApiMeta.GetOptions) (*kubeCore.Endpoints, error) {
+				calls++
+				return c.endpoints, c.err
+			}, "ns", "a")
+			if ready != c.ready || (err != nil) != c.expectErr {
+				t.Fatalf("expected (%v, error: %v), got (%v, %v)", c.ready, c.expectErr, ready, err)
+			}
+			if ready && endpoints != c.endpoints {
+				t.Fatalf("expected the ready endpoints, got %v", endpoints)
+			}
+			if calls != 1 {
+				t.Fatalf("expected a single get, got %d", calls)
+			}
+		})
+	}
+}
+
+func TestPodLogs(t *testing.T) {
+	pods := []kubeCore.Pod{
+		{ObjectMeta: kubeApiMeta.ObjectMeta{Namespace: "ns", Name: "a-v1-0"}},
+		{ObjectMeta: kubeApiMeta.ObjectMeta{Namespace: "ns", Name: "a-v1-1"}},
+	}
+
+	// The logs of all pods are returned, even if some fail.
+	out, err := podLogs(pods, "app", func(ns, pod, container string) (string, error) {
+		if pod == "a-v1-1" {
+			return "", errors.New("container is waiting to start")
+		}
+		return fmt.Sprintf("logs of %s/%s/%s", ns, pod, container), nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "container app of pod ns/a-v1-1") {
+		t.Fatalf("expected error for pod a-v1-1, got: %v", err)
+	}
+	for _, expected := range []string{
+		"==> pod ns/a-v1-0, container app <==\nlogs of ns/a-v1-0/app\n",
+		"==> pod ns/a-v1-1, container app <==\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected %q in logs:\n%s", expected, out)
+		}
+	}
+}
+
+func TestNewWorkloadWithoutTargetRef(t *testing.T) {
+	w, err := newWorkload(kubeCore.EndpointAddress{IP: "10.0.0.1"}, echo.Config{Service: "a"}, 7070, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Address() != "10.0.0.1" || w.PodName() != "" || w.NodeName() != "" {
+		t.Fatalf("unexpected workload: address %q, pod %q, node %q", w.Address(), w.PodName(), w.NodeName())
+	}
+	if w.Sidecar() != nil {
+		t.Fatal("expected no sidecar")
+	}
+	if _, _, err := w.Exec([]string{"ls"}); err == nil {
+		t.Fatal("expected exec to fail without a pod")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithNodePorts(t *testing.T) {
+	ports := []echo.Port{{Name: "http", ServicePort: 80}, {Name: "grpc", ServicePort: 70}}
+	service := &kubeCore.Service{Spec: kubeCore.ServiceSpec{Ports: []kubeCore.ServicePort{
+		{Name: "grpc", Port: 70, NodePort: 30070},
+		{Name: "http", Port: 80, NodePort: 30080},
+	}}}
+
+	out := withNodePorts(ports, service)
+	if out[0].NodePort != 30080 || out[1].NodePort != 30070 {
+		t.Fatalf("unexpected node ports: %+v", out)
+	}
+	// The ports of the caller's configuration are left alone.
+	if ports[0].NodePort != 0 || ports[1].NodePort != 0 {
+		t.Fatalf("ports modified: %+v", ports)
+	}
+}
+
+func TestControlPort(t *testing.T) {
+	newConfig := func(ports ...echo.Port) echo.Config {
+		return echo.Config{Service: "a", Namespace: fakeNamespace("ns"), Ports: ports}
+	}
+	first := echo.Port{Name: "grpc-1", Protocol: model.ProtocolGRPC, InstancePort: 7070}
+	second := echo.Port{Name: "grpc-2", Protocol: model.ProtocolGRPC, InstancePort: 7071}
+
+	// Without a marked port, the first gRPC port is used.
+	cfg := newConfig(first, second)
+	if err := common.FillInDefaults(nil, defaultDomain, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if p := common.GetGRPCPort(&cfg); p == nil || p.Name != "grpc-1" {
+		t.Fatalf("expected port grpc-1, got %+v", p)
+	}
+
+	// The marked port is preferred.
+	second.ControlPort = true
+	cfg = newConfig(first, second)
+	if err := common.FillInDefaults(nil, defaultDomain, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if p := common.GetGRPCPort(&cfg); p == nil || p.Name != "grpc-2" {
+		t.Fatalf("expected control port grpc-2, got %+v", p)
+	}
+
+	// At most one gRPC port may be marked.
+	first.ControlPort = true
+	cfg = newConfig(first, second)
+	if err := common.FillInDefaults(nil, defaultDomain, &cfg); err == nil {
+		t.Fatal("expected error for multiple control ports")
+	}
+	cfg = newConfig(echo.Port{Name: "http", Protocol: model.ProtocolHTTP, ControlPort: true})
+	if err := common.FillInDefaults(nil, defaultDomain, &cfg); err == nil {
+		t.Fatal("expected error for a non-gRPC control port")
+	}
+}
+
+func TestValidateProbePorts(t *testing.T) {
+	grpc := echo.Port{Name: "grpc", Protocol: model.ProtocolGRPC, ServicePort: 70, InstancePort: 7070}
+	cases := []struct {
+		name     string
+		cfg      echo.Config
+		err      string
+		expected []int
+	}{
+		{
+			name:     "defaults",
+			cfg:      echo.Config{Ports: []echo.Port{grpc}},
+			expected: []int{7070, httpReadinessPort, tcpHealthPort},
+		},
+		{
+			// Probe ports that aren't application ports are added as container ports.
+			name:     "unknown ports",
+			cfg:      echo.Config{Ports: []echo.Port{grpc}, ReadinessPort: 9090, HealthPort: 9091},
+			expected: []int{7070, 9090, 9091},
+		},
+		{
+			name: "negative port",
+			cfg:  echo.Config{ReadinessPort: -1},
+			err:  "invalid readiness port -1 or health port 0",
+		},
+		{
+			// Probe ports on unexposed application ports are reused, rather than added again.
+			name: "unexposed ports",
+			cfg: echo.Config{Ports: []echo.Port{
+				{Name: "http", Protocol: model.ProtocolHTTP, InstancePort: 9090, Unexposed: true},
+				{Name: "tcp", Protocol: model.ProtocolTCP, InstancePort: 9091, Unexposed: true},
+			}, ReadinessPort: 9090, HealthPort: 9091},
+			expected: []int{9090, 9091},
+		},
+		{
+			name: "unexposed non-HTTP readiness port",
+			cfg: echo.Config{Ports: []echo.Port{
+				{Name: "tcp", Protocol: model.ProtocolTCP, InstancePort: 9090, Unexposed: true},
+			}, ReadinessPort: 9090},
+			err: "readiness port 9090 for service a collides with TCP port tcp",
+		},
+		{
+			name: "duplicate probe ports",
+			cfg:  echo.Config{ReadinessPort: 9090, HealthPort: 9090},
+			err:  "readiness and health ports for service a must differ, but both are 9090",
+		},
+		{
+			name: "duplicate default probe port",
+			cfg:  echo.Config{HealthPort: httpReadinessPort},
+			err:  "must differ, but both are 8080",
+		},
+		{
+			// The readiness port isn't used when readiness is checked via gRPC.
+			name:     "duplicate probe ports with gRPC readiness",
+			cfg:      echo.Config{Ports: []echo.Port{grpc}, ReadinessGRPCPort: "grpc", HealthPort: httpReadinessPort},
+			expected: []int{7070, httpReadinessPort},
+		},
+		{
+			name: "readiness port of gRPC port",
+			cfg:  echo.Config{Ports: []echo.Port{grpc}, ReadinessPort: 7070},
+			err:  "readiness port 7070 for service a collides with GRPC port grpc",
+		},
+		{
+			name: "health port of gRPC port",
+			cfg:  echo.Config{Ports: []echo.Port{grpc}, HealthPort: 7070},
+			err:  "health port 7070 for service a collides with GRPC port grpc",
+		},
+		{
+			name: "unsupported health check protocol",
+			cfg:  echo.Config{HealthCheckProtocol: model.ProtocolGRPC},
+			err:  "unsupported health check protocol GRPC",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.cfg.Service = "a"
+			err := validateProbePorts(c.cfg)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("expected error containing %q, got: %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Each probe port is a single container port.
+			var ports []int
+			for _, p := range getContainerPorts(c.cfg) {
+				ports = append(ports, p.Port)
+			}
+			if !reflect.DeepEqual(ports, c.expected) {
+				t.Fatalf("expected container ports %v, got %v", c.expected, ports)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// selectorLabels are the pod labels used by the generated Service and Deployment selectors.
+var selectorLabels = map[string]bool{
+	"app":            true,
+	"version":        true,
+	"istio-locality": true,
+}
+
+func (c *instance) LabelWorkload(index int, key, value string) error {
+	w, err := c.getWorkload(index)
+	if err != nil {
+		return err
+	}
+
+	pod, err := c.env.SetPodLabel(w.pod.Namespace, w.pod.Name, key, value)
+	if err != nil {
+		return fmt.Errorf("failed labeling pod %s/%s with %s=%s: %v", w.pod.Namespace, w.pod.Name, key, value, err)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	w.pod = pod
+
+	if selectorLabels[key] {
+		// The pod may have left (or a replacement may have joined) the service. Reset the workloads,
+		// so that they are re-initialized from the service endpoints when next needed.
+		return c.resetWorkloads()
+	}
+	return nil
+}
+
+func (c *instance) WorkloadLabels(index int) (map[string]string, error) {
+	w, err := c.getWorkload(index)
+	if err != nil {
+		return nil, err
+	}
+
+	pod, err := c.env.GetPod(w.pod.Namespace, w.pod.Name)
+	if err != nil {
+		return nil, err
+	}
+	return pod.Labels, nil
+}
+
+func (c *instance) ExecInWorkload(index int, command []string) (string, string, error) {
+	w, err := c.getWorkload(index)
+	if err != nil {
+		return "", "", err
+	}
+	return w.Exec(command)
+}
+
+// getWorkload returns the workload with the given index, initializing the workloads if necessary.
+func (c *instance) getWorkload(index int) (*workload, error) {
+	if err := c.WaitUntilReady(); err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if index < 0 || index >= len(c.workloads) {
+		return nil, fmt.Errorf("invalid workload index %d for service %s: %d workloads available",
+			index, c.cfg.Service, len(c.workloads))
+	}
+	return c.workloads[index], nil
+}
+
+// resetWorkloads closes and clears the current workloads. Must be called with the mutex held.
+func (c *instance) resetWorkloads() (err error) {
+	for _, w := range c.workloads {
+		err = multierror.Append(err, w.Close()).ErrorOrNil()
+	}
+	c.workloads = nil
+	return
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-multierror"
+
+	kubeCore "k8s.io/api/core/v1"
+)
+
+func (c *instance) Logs(container string) (string, error) {
+	// The pods are listed rather than the workloads, which would require waiting for readiness,
+	// so that the logs of pods failing to become ready can be retrieved as well.
+	ns := c.cfg.Namespace.Name()
+	pods, err := c.env.GetPods(ns, "app="+c.cfg.Service, "version="+c.cfg.Version)
+	if err != nil {
+		return "", fmt.Errorf("failed listing the pods of %s: %v", c, err)
+	}
+	if len(pods) == 0 {
+		return "", fmt.Errorf("no pods found for %s", c)
+	}
+	if container == "" {
+		container = appContainerName
+		if c.cfg.Gateway {
+			container = proxyContainerName
+		}
+	}
+	return podLogs(pods, container, c.env.Logs)
+}
+
+// podLogs returns the logs of the container of each of the pods, each preceded by a header naming
+// the pod. The logs of all pods are returned, along with any errors.
+func podLogs(pods []kubeCore.Pod, container string, logs func(ns, pod, container string) (string, error)) (string, error) {
+	var out strings.Builder
+	var err error
+	for _, pod := range pods {
+		l, e := logs(pod.Namespace, pod.Name, container)
+		if e != nil {
+			err = multierror.Append(err, fmt.Errorf("failed getting logs of container %s of pod %s/%s: %v",
+				container, pod.Namespace, pod.Name, e))
+		}
+		fmt.Fprintf(&out, "==> pod %s/%s, container %s <==\n%s\n", pod.Namespace, pod.Name, container, l)
+	}
+	return out.String(), err
+}
+
+func (c *instance) LogsOrFail(t testing.TB, container string) string {
+	out, err := c.Logs(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func (w *workload) Logs(container string) (string, error) {
+	container = w.logsContainer(container)
+	logs, err := w.accessor.Logs(w.pod.Namespace, w.pod.Name, container)
+	if err != nil {
+		return "", fmt.Errorf("failed getting logs of container %s of pod %s/%s: %v",
+			container, w.pod.Namespace, w.pod.Name, err)
+	}
+	return logs, nil
+}
+
+// logsContainer returns the name of the container for Logs, which defaults to the main container.
+func (w *workload) logsContainer(container string) string {
+	if container == "" {
+		return w.container
+	}
+	return container
+}
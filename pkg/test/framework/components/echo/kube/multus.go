@@ -0,0 +1,71 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/scopes"
+
+	kubeCore "k8s.io/api/core/v1"
+)
+
+// networkAttachmentRegex matches a Multus network selection, in the form [namespace/]name[@interface].
+var networkAttachmentRegex = regexp.MustCompile(
+	`^([a-z0-9]([-a-z0-9]*[a-z0-9])?/)?[a-z0-9]([-a-z0-9]*[a-z0-9])?(@[A-Za-z0-9_.-]+)?$`)
+
+// networkStatus is an entry of the Multus networks-status pod annotation.
+type networkStatus struct {
+	Name      string   `json:"name"`
+	Interface string   `json:"interface"`
+	IPs       []string `json:"ips"`
+	Default   bool     `json:"default"`
+}
+
+func validateNetworkAttachments(cfg echo.Config) error {
+	for _, attachment := range cfg.NetworkAttachments {
+		if !networkAttachmentRegex.MatchString(attachment) {
+			return fmt.Errorf("invalid network attachment %q for service %s (want [namespace/]name[@interface])",
+				attachment, cfg.Service)
+		}
+	}
+	return nil
+}
+
+// secondaryAddresses returns the IPs of the pod on its secondary (i.e. non-default) networks, as
+// reported by Multus.
+func secondaryAddresses(pod kubeCore.Pod) []string {
+	status, ok := pod.Annotations[multusNetworksStatusAnnotation]
+	if !ok {
+		return nil
+	}
+
+	var networks []networkStatus
+	if err := json.Unmarshal([]byte(status), &networks); err != nil {
+		scopes.Framework.Warnf("failed parsing network status of pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		return nil
+	}
+
+	var out []string
+	for _, n := range networks {
+		if !n.Default {
+			out = append(out, n.IPs...)
+		}
+	}
+	return out
+}
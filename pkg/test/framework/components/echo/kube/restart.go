@@ -0,0 +1,59 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"fmt"
+
+	"istio.io/istio/pkg/test/framework/components/echo"
+)
+
+// deploymentName returns the name of the Deployment generated for the configuration.
+func deploymentName(cfg echo.Config) string {
+	return cfg.Service + "-" + cfg.Version
+}
+
+func (c *instance) Restart() error {
+	if err := c.checkDeploymentKind("restart"); err != nil {
+		return err
+	}
+	ns := c.cfg.Namespace.Name()
+	name := deploymentName(c.Config())
+	if err := c.env.RestartDeployment(ns, name); err != nil {
+		return fmt.Errorf("failed restarting deployment %s/%s: %v", ns, name, err)
+	}
+	if err := c.env.WaitUntilDeploymentIsRolledOut(ns, name); err != nil {
+		return fmt.Errorf("failed waiting for restart of deployment %s/%s: %v", ns, name, err)
+	}
+
+	_, endpoints, err := c.env.WaitUntilServiceEndpointsAreReady(ns, c.cfg.Service)
+	if err != nil {
+		return err
+	}
+	// The Service may be shared with other versions, whose pods aren't workloads of this Instance.
+	pods, err := c.env.GetPods(ns, "app="+c.cfg.Service, "version="+c.cfg.Version)
+	if err != nil {
+		return fmt.Errorf("failed getting the pods of deployment %s/%s: %v", ns, name, err)
+	}
+	endpoints = versionEndpoints(endpoints, pods)
+
+	// Replace the workloads, closing the port forwards to the previous pods.
+	if err := c.replaceWorkloads(endpoints); err != nil {
+		return err
+	}
+
+	// Wait for the new sidecars to receive the outbound config.
+	return c.WaitUntilReady()
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"fmt"
+
+	"istio.io/istio/pkg/test/kube"
+	"istio.io/istio/pkg/test/util/retry"
+
+	kubeCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (c *instance) Scale(replicas int) error {
+	if replicas < 0 {
+		return fmt.Errorf("invalid replicas %d for service %s", replicas, c.cfg.Service)
+	}
+	if err := c.checkDeploymentKind("scaling"); err != nil {
+		return err
+	}
+
+	ns := c.cfg.Namespace.Name()
+	name := deploymentName(c.Config())
+	if err := c.env.ScaleDeployment(ns, name, replicas); err != nil {
+		return fmt.Errorf("failed scaling deployment %s/%s to %d replicas: %v", ns, name, replicas, err)
+	}
+	if err := c.env.WaitUntilDeploymentIsRolledOut(ns, name); err != nil {
+		return fmt.Errorf("deployment %s/%s did not reach %d replicas: %v", ns, name, replicas, err)
+	}
+
+	endpoints, err := c.waitForEndpoints(replicas)
+	if err != nil {
+		return fmt.Errorf("endpoints of service %s/%s did not reach %d replicas: %v", ns, c.cfg.Service, replicas, err)
+	}
+
+	c.mutex.Lock()
+	c.cfg.Replicas = replicas
+	c.mutex.Unlock()
+
+	// Replace the workloads, closing the port forwards to the previous pods.
+	if err := c.replaceWorkloads(endpoints); err != nil {
+		return err
+	}
+
+	// Wait for any new sidecars to receive the outbound config.
+	return c.WaitUntilReady()
+}
+
+// waitForEndpoints waits until the pods of this Instance are the given number of ready service
+// endpoints, and returns the endpoints of these pods (see versionEndpoints).
+func (c *instance) waitForEndpoints(replicas int) (*kubeCore.Endpoints, error) {
+	ns := c.cfg.Namespace.Name()
+	var endpoints *kubeCore.Endpoints
+	err := retry.UntilSuccess(func() error {
+		pods, err := c.env.GetPods(ns, "app="+c.cfg.Service, "version="+c.cfg.Version)
+		if err != nil {
+			return err
+		}
+		eps, err := c.env.GetEndpoints(ns, c.cfg.Service, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		ready := make(map[string]bool)
+		for _, subset := range eps.Subsets {
+			for _, addr := range subset.Addresses {
+				if addr.TargetRef != nil {
+					ready[addr.TargetRef.Name] = true
+				}
+			}
+		}
+
+		count := 0
+		for i := range pods {
+			pod := &pods[i]
+			if pod.DeletionTimestamp != nil {
+				if ready[pod.Name] {
+					return fmt.Errorf("deleted pod %s/%s is still an endpoint", ns, pod.Name)
+				}
+				continue
+			}
+			if kube.CheckPodReady(pod) != nil || !ready[pod.Name] {
+				return fmt.Errorf("pod %s/%s is not a ready endpoint", ns, pod.Name)
+			}
+			count++
+		}
+		if count != replicas {
+			return fmt.Errorf("found %d of %d pods", count, replicas)
+		}
+		endpoints = versionEndpoints(eps, pods)
+		return nil
+	})
+	return endpoints, err
+}
+
+// versionEndpoints returns a copy of the endpoints with only the addresses of the given pods (i.e.
+// those of this Instance), leaving out the pods of other versions that share the Service. Addresses
+// without a pod can't be attributed to a version, and are kept.
+func versionEndpoints(endpoints *kubeCore.Endpoints, pods []kubeCore.Pod) *kubeCore.Endpoints {
+	names := make(map[string]bool)
+	for _, pod := range pods {
+		if pod.DeletionTimestamp == nil {
+			names[pod.Name] = true
+		}
+	}
+
+	out := endpoints.DeepCopy()
+	for i := range out.Subsets {
+		addresses := make([]kubeCore.EndpointAddress, 0, len(out.Subsets[i].Addresses))
+		for _, addr := range out.Subsets[i].Addresses {
+			if addr.TargetRef == nil || names[addr.TargetRef.Name] {
+				addresses = append(addresses, addr)
+			}
+		}
+		out.Subsets[i].Addresses = addresses
+	}
+	return out
+}
@@ -0,0 +1,197 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/common"
+	"istio.io/istio/pkg/test/util/retry"
+
+	kubeCore "k8s.io/api/core/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// serviceTimeout bounds the wait for the Service to be registered after it was applied.
+	serviceTimeout = 30 * time.Second
+	serviceDelay   = 500 * time.Millisecond
+)
+
+// validateServiceType verifies that the ServiceType in the configuration is supported, and consistent
+// with Headless.
+func validateServiceType(cfg echo.Config) error {
+	switch cfg.ServiceType {
+	case echo.ServiceTypeClusterIP, echo.ServiceTypeNodePort, echo.ServiceTypeLoadBalancer:
+		if cfg.Headless {
+			return fmt.Errorf("service type %s for service %s can't be headless", cfg.ServiceType, cfg.Service)
+		}
+	case echo.ServiceTypeHeadless:
+	default:
+		return fmt.Errorf("unsupported service type %q for service %s", cfg.ServiceType, cfg.Service)
+	}
+	return nil
+}
+
+// kubeServiceType returns the type of the k8s Service for the configuration, or "" for the default
+// (i.e. ClusterIP, which is also the type of headless services).
+func kubeServiceType(cfg echo.Config) string {
+	switch cfg.ServiceType {
+	case echo.ServiceTypeNodePort, echo.ServiceTypeLoadBalancer:
+		return string(cfg.ServiceType)
+	default:
+		return ""
+	}
+}
+
+// initServiceAddresses records the addresses (and node ports) of the deployed Service, waiting for
+// the external address of a LoadBalancer.
+func (c *instance) initServiceAddresses() error {
+	ns := c.cfg.Namespace.Name()
+	s, err := waitForService(c.env.GetService, ns, c.cfg.Service, serviceTimeout, serviceDelay)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case c.cfg.Headless:
+		c.clusterIP = ""
+	case net.ParseIP(s.Spec.ClusterIP) == nil:
+		// Either an IPv4 or an IPv6 address is accepted, but not "None".
+		return newCauseError(ErrInvalidClusterIP, " %s for non-headless service %s/%s", s.Spec.ClusterIP, ns, c.cfg.Service)
+	default:
+		// Only the primary cluster IP is recorded, since the vendored Kubernetes API has no
+		// Spec.ClusterIPs for the addresses of both families of a dual-stack service.
+		c.clusterIP = s.Spec.ClusterIP
+	}
+
+	if c.cfg.ServiceType == echo.ServiceTypeLoadBalancer {
+		if err := retry.UntilSuccess(func() error {
+			if s, err = c.env.GetService(ns, c.cfg.Service); err != nil {
+				return err
+			}
+			for _, ingress := range s.Status.LoadBalancer.Ingress {
+				if ingress.IP != "" {
+					c.externalAddress = ingress.IP
+					return nil
+				}
+				if ingress.Hostname != "" {
+					c.externalAddress = ingress.Hostname
+					return nil
+				}
+			}
+			return fmt.Errorf("no external address assigned to service %s/%s", ns, c.cfg.Service)
+		}); err != nil {
+			return err
+		}
+	}
+
+	c.cfg.Ports = withNodePorts(c.cfg.Ports, s)
+	return nil
+}
+
+// withNodePorts returns a copy of the ports with the node ports allocated for the Service. The
+// ports aren't modified, since they may be shared with the caller's configuration.
+func withNodePorts(ports []echo.Port, s *kubeCore.Service) []echo.Port {
+	out := append([]echo.Port{}, ports...)
+	for i, p := range out {
+		for _, sp := range s.Spec.Ports {
+			if sp.Name == p.Name {
+				out[i].NodePort = int(sp.NodePort)
+			}
+		}
+	}
+	return out
+}
+
+// waitForService gets the Service, polling while it isn't found (e.g. on a slow cluster, right after
+// it was applied) until the timeout elapses. Other errors are returned immediately.
+func waitForService(get func(ns, name string) (*kubeCore.Service, error), ns, name string,
+	timeout, delay time.Duration) (*kubeCore.Service, error) {
+	notFound := false
+	s, err := retry.Do(func() (interface{}, bool, error) {
+		s, err := get(ns, name)
+		notFound = kubeErrors.IsNotFound(err)
+		if notFound {
+			return nil, false, err
+		}
+		return s, true, err
+	}, retry.Timeout(timeout), retry.Delay(delay))
+	if err != nil {
+		if notFound {
+			return nil, newCauseError(ErrServiceNotFound, ": %s/%s not found within %v", ns, name, timeout)
+		}
+		return nil, err
+	}
+	return s.(*kubeCore.Service), nil
+}
+
+func (c *instance) Addresses() ([]echo.ServiceAddress, error) {
+	if c.clusterIP != "" {
+		return serviceAddresses(c.Config(), c.clusterIP, nil), nil
+	}
+
+	workloads, err := c.Workloads()
+	if err != nil {
+		return nil, err
+	}
+	workloadAddresses := make([]string, 0, len(workloads))
+	for _, w := range workloads {
+		workloadAddresses = append(workloadAddresses, w.Address())
+	}
+	return serviceAddresses(c.Config(), "", workloadAddresses), nil
+}
+
+func (c *instance) AddressesOrFail(t testing.TB) []echo.ServiceAddress {
+	out, err := c.Addresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// serviceAddresses returns the addresses of the exposed ports of the service at the cluster IP or,
+// if empty (i.e. for headless services), at each of the workload addresses.
+func serviceAddresses(cfg echo.Config, clusterIP string, workloadAddresses []string) []echo.ServiceAddress {
+	var out []echo.ServiceAddress
+	for _, p := range cfg.Ports {
+		if p.Unexposed {
+			continue
+		}
+		if clusterIP != "" {
+			out = append(out, echo.ServiceAddress{Port: p, Address: clusterIP, PortNumber: p.ServicePort})
+			continue
+		}
+		for _, addr := range workloadAddresses {
+			out = append(out, echo.ServiceAddress{Port: p, Address: addr, PortNumber: p.InstancePort})
+		}
+	}
+	return out
+}
+
+func (c *instance) AddressOf(addressType echo.AddressType) string {
+	switch addressType {
+	case echo.AddressTypeExternal:
+		return c.externalAddress
+	case echo.AddressTypeClusterIPv4, echo.AddressTypeClusterIPv6:
+		return common.AddressOfFamily(c.Address(), addressType)
+	default:
+		return c.Address()
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/gogo/protobuf/proto"
 	"github.com/gogo/protobuf/types"
 
+	"istio.io/istio/pkg/test/envoy"
 	"istio.io/istio/pkg/test/framework/components/echo"
 	"istio.io/istio/pkg/test/framework/components/echo/common"
 	"istio.io/istio/pkg/test/kube"
@@ -47,6 +48,16 @@ type sidecar struct {
 	accessor     *kube.Accessor
 }
 
+// hasProxyContainer indicates whether the sidecar was injected into the pod.
+func hasProxyContainer(pod kubeCore.Pod) bool {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == proxyContainerName {
+			return true
+		}
+	}
+	return false
+}
+
 func newSidecar(pod kubeCore.Pod, accessor *kube.Accessor) (*sidecar, error) {
 	sidecar := &sidecar{
 		podNamespace: pod.Namespace,
@@ -79,6 +90,18 @@ func (s *sidecar) NodeID() string {
 	return s.nodeID
 }
 
+func (s *sidecar) Logs() (string, error) {
+	return s.accessor.Logs(s.podNamespace, s.podName, proxyContainerName)
+}
+
+func (s *sidecar) ProxyVersion() (string, error) {
+	out, err := s.accessor.Exec(s.podNamespace, s.podName, proxyContainerName, "pilot-agent version -s")
+	if err != nil {
+		return "", fmt.Errorf("failed getting proxy version of pod %s/%s: %v", s.podNamespace, s.podName, err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
 func (s *sidecar) Info() (*envoyAdmin.ServerInfo, error) {
 	msg := &envoyAdmin.ServerInfo{}
 	if err := s.adminRequest("server_info", msg); err != nil {
@@ -113,10 +136,47 @@ func (s *sidecar) ConfigOrFail(t testing.TB) *envoyAdmin.ConfigDump {
 	return cfg
 }
 
+func (s *sidecar) Clusters() (*envoyAdmin.Clusters, error) {
+	msg := &envoyAdmin.Clusters{}
+	if err := s.adminRequest("clusters?format=json", msg); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func (s *sidecar) ClustersOrFail(t testing.TB) *envoyAdmin.Clusters {
+	clusters, err := s.Clusters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clusters
+}
+
+func (s *sidecar) Stats() (map[string]int64, error) {
+	response, err := s.adminExec("stats?format=json")
+	if err != nil {
+		return nil, err
+	}
+	return envoy.ParseStats(strings.NewReader(response))
+}
+
+func (s *sidecar) StatsOrFail(t testing.TB) map[string]int64 {
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats
+}
+
 func (s *sidecar) WaitForConfig(accept func(*envoyAdmin.ConfigDump) (bool, error), options ...retry.Option) error {
 	return common.WaitForConfig(s.Config, accept, options...)
 }
 
+func (s *sidecar) CheckConfig(accept func(*envoyAdmin.ConfigDump) (bool, error)) (bool, error) {
+	return common.CheckConfig(s.Config, accept)
+}
+
 func (s *sidecar) WaitForConfigOrFail(t testing.TB, accept func(*envoyAdmin.ConfigDump) (bool, error), options ...retry.Option) {
 	if err := s.WaitForConfig(accept, options...); err != nil {
 		t.Fatal(err)
@@ -124,12 +184,9 @@ func (s *sidecar) WaitForConfigOrFail(t testing.TB, accept func(*envoyAdmin.Conf
 }
 
 func (s *sidecar) adminRequest(path string, out proto.Message) error {
-	// Exec onto the pod and make a curl request to the admin port, writing
-	command := fmt.Sprintf("curl http://127.0.0.1:%d/%s", proxyAdminPort, path)
-	response, err := s.accessor.Exec(s.podNamespace, s.podName, proxyContainerName, command)
+	response, err := s.adminExec(path)
 	if err != nil {
-		return fmt.Errorf("failed exec on pod %s/%s: %v. Command: %s. Output:\n%s",
-			s.podNamespace, s.podName, err, command, response)
+		return err
 	}
 
 	if err := jsonpb.Unmarshal(strings.NewReader(response), out); err != nil {
@@ -137,3 +194,14 @@ func (s *sidecar) adminRequest(path string, out proto.Message) error {
 	}
 	return nil
 }
+
+// adminExec execs onto the pod and makes a curl request to the admin port, returning the output.
+func (s *sidecar) adminExec(path string) (string, error) {
+	command := fmt.Sprintf("curl http://127.0.0.1:%d/%s", proxyAdminPort, path)
+	response, err := s.accessor.Exec(s.podNamespace, s.podName, proxyContainerName, command)
+	if err != nil {
+		return "", fmt.Errorf("failed exec on pod %s/%s: %v. Command: %s. Output:\n%s",
+			s.podNamespace, s.podName, err, command, response)
+	}
+	return response, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"istio.io/istio/pkg/test/framework/components/echo"
+)
+
+// validateDeploymentKind verifies the DeploymentKind in the configuration. StatefulSets require a
+// headless Service, so the configuration is updated accordingly.
+func validateDeploymentKind(cfg *echo.Config) error {
+	switch cfg.DeploymentKind {
+	case echo.DeploymentKindDeployment:
+	case echo.DeploymentKindStatefulSet:
+		if cfg.Gateway {
+			return fmt.Errorf("gateway %s can't be deployed as a StatefulSet", cfg.Service)
+		}
+		switch cfg.ServiceType {
+		case echo.ServiceTypeClusterIP, echo.ServiceTypeHeadless:
+			cfg.ServiceType = echo.ServiceTypeHeadless
+			cfg.Headless = true
+		default:
+			return fmt.Errorf("StatefulSet for service %s requires a headless service, not %s",
+				cfg.Service, cfg.ServiceType)
+		}
+	default:
+		return fmt.Errorf("unsupported deployment kind %q for service %s", cfg.DeploymentKind, cfg.Service)
+	}
+	return nil
+}
+
+// podOrdinal returns the ordinal of a StatefulSet pod, i.e. the suffix of its name, or -1 if the name
+// has none.
+func podOrdinal(podName string) int {
+	ordinal, err := strconv.Atoi(podName[strings.LastIndex(podName, "-")+1:])
+	if err != nil {
+		return -1
+	}
+	return ordinal
+}
+
+// checkDeploymentKind returns an error if the operation isn't supported for the DeploymentKind.
+func (c *instance) checkDeploymentKind(operation string) error {
+	if c.cfg.DeploymentKind != echo.DeploymentKindDeployment {
+		return fmt.Errorf("%s of service %s is not supported for %s", operation, c.cfg.Service, c.cfg.DeploymentKind)
+	}
+	return nil
+}
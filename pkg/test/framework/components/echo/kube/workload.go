@@ -15,7 +15,10 @@
 package kube
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/hashicorp/go-multierror"
 
@@ -34,13 +37,36 @@ type workload struct {
 	*client.Instance
 
 	addr      kubeCore.EndpointAddress
+	podFQDN   string
+	secondary []string
 	pod       kubeCore.Pod
+	// podName and nodeName are cached when the workload is created, since the pod may be updated.
+	podName   string
+	nodeName  string
+	version   string
 	forwarder kube.PortForwarder
 	sidecar   *sidecar
+	accessor  *kube.Accessor
+	// container is the main container of the pod, i.e. the echo app or, for gateways, the proxy.
+	container string
+
+	// forwards created by ForwardPort that are still open.
+	forwardsMutex sync.Mutex
+	forwards      map[kube.PortForwarder]struct{}
 }
 
-func newWorkload(addr kubeCore.EndpointAddress, useSidecar bool, grpcPort uint16, accessor *kube.Accessor) (*workload, error) {
-	if addr.TargetRef == nil || addr.TargetRef.Kind != "Pod" {
+func newWorkload(addr kubeCore.EndpointAddress, cfg echo.Config, grpcPort uint16, accessor *kube.Accessor) (*workload, error) {
+	if addr.TargetRef == nil {
+		// E.g. a manually managed endpoint. There is no pod to control, so the workload only has an
+		// address, and empty pod and node names.
+		return &workload{
+			addr:      addr,
+			podFQDN:   podFQDN(addr, cfg),
+			accessor:  accessor,
+			container: appContainerName,
+		}, nil
+	}
+	if addr.TargetRef.Kind != "Pod" {
 		return nil, fmt.Errorf("invalid TargetRef for endpoint %s: %v", addr.IP, addr.TargetRef)
 	}
 
@@ -49,6 +75,26 @@ func newWorkload(addr kubeCore.EndpointAddress, useSidecar bool, grpcPort uint16
 		return nil, err
 	}
 
+	if cfg.Gateway {
+		// There is no app to control, only the proxy.
+		s, err := newSidecar(pod, accessor)
+		if err != nil {
+			return nil, err
+		}
+		return &workload{
+			addr:      addr,
+			podFQDN:   podFQDN(addr, cfg),
+			secondary: secondaryAddresses(pod),
+			pod:       pod,
+			podName:   pod.Name,
+			nodeName:  pod.Spec.NodeName,
+			version:   pod.Labels["version"],
+			sidecar:   s,
+			accessor:  accessor,
+			container: proxyContainerName,
+		}, nil
+	}
+
 	// Create a forwarder to the command port of the app.
 	forwarder, err := accessor.NewPortForwarder(pod, 0, grpcPort)
 	if err != nil {
@@ -66,7 +112,12 @@ func newWorkload(addr kubeCore.EndpointAddress, useSidecar bool, grpcPort uint16
 	}
 
 	var s *sidecar
-	if useSidecar {
+	if cfg.Sidecar {
+		if !hasProxyContainer(pod) {
+			// Fail early, rather than waiting for the config of a proxy that doesn't exist.
+			return nil, fmt.Errorf("pod %s/%s has no %s container, check that injection is enabled",
+				pod.Namespace, pod.Name, proxyContainerName)
+		}
 		if s, err = newSidecar(pod, accessor); err != nil {
 			return nil, err
 		}
@@ -74,10 +125,17 @@ func newWorkload(addr kubeCore.EndpointAddress, useSidecar bool, grpcPort uint16
 
 	return &workload{
 		addr:      addr,
+		podFQDN:   podFQDN(addr, cfg),
+		secondary: secondaryAddresses(pod),
 		pod:       pod,
+		podName:   pod.Name,
+		nodeName:  pod.Spec.NodeName,
+		version:   pod.Labels["version"],
 		forwarder: forwarder,
 		Instance:  c,
 		sidecar:   s,
+		accessor:  accessor,
+		container: appContainerName,
 	}, nil
 }
 
@@ -88,6 +146,7 @@ func (w *workload) Close() (err error) {
 	if w.forwarder != nil {
 		err = multierror.Append(err, w.forwarder.Close()).ErrorOrNil()
 	}
+	err = multierror.Append(err, w.closeForwards()).ErrorOrNil()
 	return
 }
 
@@ -95,6 +154,69 @@ func (w *workload) Address() string {
 	return w.addr.IP
 }
 
+func (w *workload) Hostname() string {
+	if w.pod.Spec.Hostname != "" {
+		return w.pod.Spec.Hostname
+	}
+	return w.pod.Name
+}
+
+func (w *workload) PodName() string {
+	return w.podName
+}
+
+func (w *workload) NodeName() string {
+	return w.nodeName
+}
+
+func (w *workload) Version() string {
+	return w.version
+}
+
+func (w *workload) SecondaryAddresses() []string {
+	return w.secondary
+}
+
+func (w *workload) PodFQDN() string {
+	return w.podFQDN
+}
+
+// podFQDN returns the per-pod DNS name of the endpoint within a headless service. Endpoints with a
+// hostname (e.g. StatefulSet pods) are named after it, all others after their (dashed) IP address.
+func podFQDN(addr kubeCore.EndpointAddress, cfg echo.Config) string {
+	if !cfg.Headless {
+		return ""
+	}
+	label := addr.Hostname
+	if label == "" {
+		label = strings.NewReplacer(".", "-", ":", "-").Replace(addr.IP)
+	}
+	return label + "." + cfg.FQDN()
+}
+
+func (w *workload) RequestCount(key string) (int, error) {
+	if w.Instance == nil {
+		return 0, fmt.Errorf("request counts are not available for workload %s without an echo app", w.addr.IP)
+	}
+	return w.Instance.RequestCount(context.Background(), key)
+}
+
+func (w *workload) Exec(command []string) (string, string, error) {
+	if w.podName == "" {
+		return "", "", fmt.Errorf("failed exec on workload %s: no pod", w.addr.IP)
+	}
+	stdout, stderr, err := w.accessor.ExecArgs(w.pod.Namespace, w.pod.Name, w.container, command)
+	if err != nil {
+		return stdout, stderr, fmt.Errorf("failed exec on pod %s/%s: %v. Command: %v. Stderr:\n%s",
+			w.pod.Namespace, w.pod.Name, err, command, stderr)
+	}
+	return stdout, stderr, nil
+}
+
 func (w *workload) Sidecar() echo.Sidecar {
+	if w.sidecar == nil {
+		// Not a nil *sidecar, which wouldn't compare equal to nil.
+		return nil
+	}
 	return w.sidecar
 }
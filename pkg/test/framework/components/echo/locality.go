@@ -0,0 +1,121 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// AssertLocalityFailover verifies that traffic fails over from the workloads in localZone to those
+// in expectedFailoverZone. The targets are the Instances backing the service identified by
+// opts.Target, each deployed with a Locality (i.e. region/zone/subzone). A target is in a zone if its
+// Locality equals the zone or is nested within it (e.g. "region/zone" contains "region/zone/subzone").
+//
+// The workloads of the local zone are disabled via the echo app's fault directive, causing them to
+// respond with 503 to every call. Calls are then repeatedly made from source until a batch of calls
+// succeeds and is served entirely by the failover zone. This relies on outlier detection being
+// configured for the service, as required by Envoy for locality failover. An error is returned if
+// traffic has not failed over within the given duration. opts.Count is the number of calls per batch.
+func AssertLocalityFailover(source Instance, opts CallOptions, targets []Instance,
+	localZone, expectedFailoverZone string, within time.Duration) error {
+	if opts.Target == nil {
+		return errors.New("assertLocalityFailover: missing Target")
+	}
+	if opts.Count <= 0 {
+		opts.Count = outlierCallsPerBatch
+	}
+
+	// Attribute the hostname of every workload to its zone.
+	var localHosts []string
+	failoverHosts := make(map[string]bool)
+	for _, target := range targets {
+		locality := target.Config().Locality
+		inLocal := inZone(locality, localZone)
+		inFailover := inZone(locality, expectedFailoverZone)
+		if !inLocal && !inFailover {
+			continue
+		}
+
+		workloads, err := target.Workloads()
+		if err != nil {
+			return err
+		}
+		for _, w := range workloads {
+			if inLocal {
+				localHosts = append(localHosts, w.Hostname())
+			} else {
+				failoverHosts[w.Hostname()] = true
+			}
+		}
+	}
+	if len(localHosts) == 0 {
+		return fmt.Errorf("assertLocalityFailover: no workloads found in local zone %s", localZone)
+	}
+	if len(failoverHosts) == 0 {
+		return fmt.Errorf("assertLocalityFailover: no workloads found in failover zone %s", expectedFailoverZone)
+	}
+
+	opts.Retry.Disabled = true
+	opts.Path = withQuery(opts.Path, url.Values{
+		"codes": []string{"503"},
+		"hosts": []string{strings.Join(localHosts, ",")},
+	})
+
+	var lastDist map[string]int
+	deadline := time.Now().Add(within)
+	for {
+		responses, err := source.Call(opts)
+		if err != nil {
+			return err
+		}
+		lastDist = responses.Distribution()
+
+		failedOver := responses.CheckOK() == nil
+		for host := range lastDist {
+			if !failoverHosts[host] {
+				failedOver = false
+			}
+		}
+		if failedOver {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(outlierBatchDelay)
+	}
+
+	return fmt.Errorf("traffic to %s did not fail over from %s to %s within %v. Last distribution: %v",
+		opts.Target.Config().Service, localZone, expectedFailoverZone, within, lastDist)
+}
+
+// AssertLocalityFailoverOrFail calls AssertLocalityFailover and fails t if an error occurs.
+func AssertLocalityFailoverOrFail(t testing.TB, source Instance, opts CallOptions, targets []Instance,
+	localZone, expectedFailoverZone string, within time.Duration) {
+	if err := AssertLocalityFailover(source, opts, targets, localZone, expectedFailoverZone, within); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// inZone indicates whether the locality is within the given zone.
+func inZone(locality, zone string) bool {
+	return zone != "" && (locality == zone || strings.HasPrefix(locality, zone+"/"))
+}
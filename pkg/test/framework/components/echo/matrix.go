@@ -0,0 +1,204 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const (
+	defaultMatrixConcurrency = 10
+	defaultMatrixRetryDelay  = time.Second
+)
+
+// CallMatrixOptions defines the calls made by CallMatrix.
+type CallMatrixOptions struct {
+	// Sources of the calls.
+	Sources []Instance
+
+	// Targets of the calls. Every source calls every target.
+	Targets []Instance
+
+	// Call options used for every cell. The Target is set for each cell.
+	Call CallOptions
+
+	// Attempts is the number of times a cell is attempted before it is considered failed. A cell that
+	// succeeds after more than one attempt is reported as flaky. If <= 0, a single attempt is made.
+	Attempts int
+
+	// RetryDelay between attempts of a cell. If not provided, 1 second is used.
+	RetryDelay time.Duration
+
+	// Concurrency is the maximum number of cells called concurrently. If not provided, 10 is used.
+	Concurrency int
+}
+
+// CallMatrixCell is the outcome of the calls from a single source to a single target.
+type CallMatrixCell struct {
+	Source Instance
+	Target Instance
+
+	// Attempts made for this cell.
+	Attempts int
+
+	// Err from the last attempt, or nil if the cell passed.
+	Err error
+}
+
+// Name of the cell, in the form source->target.
+func (c CallMatrixCell) Name() string {
+	return c.Source.Config().Service + "->" + c.Target.Config().Service
+}
+
+// Passed indicates whether the cell succeeded within the allowed attempts.
+func (c CallMatrixCell) Passed() bool {
+	return c.Err == nil
+}
+
+// Flaky indicates whether the cell passed, but only after being retried.
+func (c CallMatrixCell) Flaky() bool {
+	return c.Passed() && c.Attempts > 1
+}
+
+// CallMatrixResult is the outcome of CallMatrix, with one cell for each source/target pair.
+type CallMatrixResult struct {
+	Cells []CallMatrixCell
+}
+
+// Failed returns the cells that did not pass.
+func (r CallMatrixResult) Failed() []CallMatrixCell {
+	return r.filter(func(c CallMatrixCell) bool { return !c.Passed() })
+}
+
+// Flaky returns the cells that passed only after being retried.
+func (r CallMatrixResult) Flaky() []CallMatrixCell {
+	return r.filter(CallMatrixCell.Flaky)
+}
+
+// Err returns an error describing all failed cells, or nil if all cells passed.
+func (r CallMatrixResult) Err() (err error) {
+	for _, c := range r.Failed() {
+		err = multierror.Append(err, fmt.Errorf("%s failed after %d attempts: %v", c.Name(), c.Attempts, c.Err))
+	}
+	return
+}
+
+// Summary of the result, listing the flaky and failed cells.
+func (r CallMatrixResult) Summary() string {
+	var b strings.Builder
+	flaky := r.Flaky()
+	failed := r.Failed()
+	fmt.Fprintf(&b, "%d cells: %d passed (%d flaky), %d failed", len(r.Cells),
+		len(r.Cells)-len(failed), len(flaky), len(failed))
+	for _, c := range flaky {
+		fmt.Fprintf(&b, "\n  flaky: %s (%d attempts)", c.Name(), c.Attempts)
+	}
+	for _, c := range failed {
+		fmt.Fprintf(&b, "\n  failed: %s: %v", c.Name(), c.Err)
+	}
+	return b.String()
+}
+
+func (r CallMatrixResult) filter(include func(CallMatrixCell) bool) []CallMatrixCell {
+	var out []CallMatrixCell
+	for _, c := range r.Cells {
+		if include(c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// CallMatrix calls every target from every source, in parallel with bounded concurrency. A cell
+// passes if a call with only OK responses is made within the allowed number of attempts.
+func CallMatrix(opts CallMatrixOptions) CallMatrixResult {
+	if opts.Attempts <= 0 {
+		opts.Attempts = 1
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = defaultMatrixRetryDelay
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultMatrixConcurrency
+	}
+
+	result := CallMatrixResult{
+		Cells: make([]CallMatrixCell, 0, len(opts.Sources)*len(opts.Targets)),
+	}
+	for _, source := range opts.Sources {
+		for _, target := range opts.Targets {
+			result.Cells = append(result.Cells, CallMatrixCell{Source: source, Target: target})
+		}
+	}
+
+	sem := make(chan struct{}, opts.Concurrency)
+	wg := sync.WaitGroup{}
+	for i := range result.Cells {
+		cell := &result.Cells[i]
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			callCell(cell, opts)
+		}()
+	}
+	wg.Wait()
+
+	return result
+}
+
+// CallMatrixOrFail calls CallMatrix, logging the summary if any cells were flaky, and fails t if any
+// cell failed.
+func CallMatrixOrFail(t testing.TB, opts CallMatrixOptions) CallMatrixResult {
+	result := CallMatrix(opts)
+	if err := result.Err(); err != nil {
+		t.Fatalf("call matrix failed: %s", result.Summary())
+	}
+	if len(result.Flaky()) > 0 {
+		t.Logf("call matrix passed with flaky cells: %s", result.Summary())
+	}
+	return result
+}
+
+func callCell(cell *CallMatrixCell, opts CallMatrixOptions) {
+	callOpts := opts.Call
+	callOpts.Target = cell.Target
+	// The cell counts its own attempts.
+	callOpts.Retry.Disabled = true
+
+	for cell.Attempts < opts.Attempts {
+		if cell.Attempts > 0 {
+			time.Sleep(opts.RetryDelay)
+		}
+		cell.Attempts++
+
+		responses, err := cell.Source.Call(callOpts)
+		if err == nil {
+			err = responses.CheckOK()
+		}
+		if cell.Err = err; err == nil {
+			return
+		}
+	}
+}
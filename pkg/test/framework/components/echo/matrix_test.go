@@ -0,0 +1,231 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/echo/client"
+)
+
+// matrixSource calls targets with the given results of its successive calls to each target: a
+// status code, or "" for a failed call. The last result is repeated, and calls to targets without
+// results succeed.
+type matrixSource struct {
+	Instance
+	service string
+	results map[string][]string
+
+	mutex     sync.Mutex
+	calls     map[string]int
+	active    int
+	maxActive int
+}
+
+func newMatrixSource(service string, results map[string][]string) *matrixSource {
+	return &matrixSource{service: service, results: results, calls: make(map[string]int)}
+}
+
+func (s *matrixSource) Config() Config {
+	return Config{Service: s.service}
+}
+
+func (s *matrixSource) Call(opts CallOptions) (client.ParsedResponses, error) {
+	if !opts.Retry.Disabled {
+		return nil, errors.New("expected the retries of the call to be disabled")
+	}
+	target := opts.Target.Config().Service
+
+	s.mutex.Lock()
+	attempt := s.calls[target]
+	s.calls[target]++
+	if s.active++; s.active > s.maxActive {
+		s.maxActive = s.active
+	}
+	s.mutex.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	s.mutex.Lock()
+	s.active--
+	s.mutex.Unlock()
+
+	results := s.results[target]
+	if len(results) == 0 {
+		return client.ParsedResponses{{Code: "200"}}, nil
+	}
+	if attempt >= len(results) {
+		attempt = len(results) - 1
+	}
+	if results[attempt] == "" {
+		return nil, errors.New("connection refused")
+	}
+	return client.ParsedResponses{{Code: results[attempt]}}, nil
+}
+
+func TestCallMatrix(t *testing.T) {
+	targets := []Instance{
+		&fakeInstance{cfg: Config{Service: "b"}},
+		&fakeInstance{cfg: Config{Service: "c"}},
+	}
+
+	cases := []struct {
+		name     string
+		results  map[string][]string
+		attempts int
+
+		// expected attempts of the cells to b and c, and the names of the flaky and failed cells.
+		expectedAttempts []int
+		flaky            []string
+		failed           []string
+		err              string
+	}{
+		{
+			name:             "all passed",
+			expectedAttempts: []int{1, 1},
+		},
+		{
+			name:             "failed without retries",
+			results:          map[string][]string{"c": {"503", "200"}},
+			expectedAttempts: []int{1, 1},
+			failed:           []string{"a->c"},
+			err:              "a->c failed after 1 attempts: ",
+		},
+		{
+			name:             "flaky",
+			results:          map[string][]string{"b": {"", "503", "200"}},
+			attempts:         3,
+			expectedAttempts: []int{3, 1},
+			flaky:            []string{"a->b"},
+		},
+		{
+			name:             "failed after retries",
+			results:          map[string][]string{"b": {""}, "c": {"503", "200"}},
+			attempts:         2,
+			expectedAttempts: []int{2, 2},
+			flaky:            []string{"a->c"},
+			failed:           []string{"a->b"},
+			err:              "a->b failed after 2 attempts: connection refused",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			source := newMatrixSource("a", c.results)
+			result := CallMatrix(CallMatrixOptions{
+				Sources:    []Instance{source},
+				Targets:    targets,
+				Attempts:   c.attempts,
+				RetryDelay: time.Millisecond,
+			})
+
+			if len(result.Cells) != len(targets) {
+				t.Fatalf("expected %d cells, got %d", len(targets), len(result.Cells))
+			}
+			for i, cell := range result.Cells {
+				if cell.Attempts != c.expectedAttempts[i] {
+					t.Fatalf("expected %d attempts for %s, got %d", c.expectedAttempts[i], cell.Name(), cell.Attempts)
+				}
+			}
+			checkCellNames(t, "flaky", result.Flaky(), c.flaky)
+			checkCellNames(t, "failed", result.Failed(), c.failed)
+
+			err := result.Err()
+			if c.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("expected error containing %q, got: %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestCallMatrixCells(t *testing.T) {
+	sources := []Instance{newMatrixSource("a", nil), newMatrixSource("b", nil)}
+	targets := []Instance{
+		&fakeInstance{cfg: Config{Service: "c"}},
+		&fakeInstance{cfg: Config{Service: "d"}},
+		&fakeInstance{cfg: Config{Service: "e"}},
+	}
+
+	result := CallMatrix(CallMatrixOptions{Sources: sources, Targets: targets, Concurrency: 2})
+
+	// Every source calls every target once, in source-major order.
+	var names []string
+	for _, cell := range result.Cells {
+		names = append(names, cell.Name())
+	}
+	if expected := "a->c a->d a->e b->c b->d b->e"; strings.Join(names, " ") != expected {
+		t.Fatalf("expected cells %s, got %s", expected, strings.Join(names, " "))
+	}
+	for _, s := range sources {
+		source := s.(*matrixSource)
+		for _, target := range []string{"c", "d", "e"} {
+			if calls := source.calls[target]; calls != 1 {
+				t.Fatalf("expected 1 call from %s to %s, got %d", source.service, target, calls)
+			}
+		}
+		// Each source is limited by the overall concurrency.
+		if source.maxActive > 2 {
+			t.Fatalf("expected at most 2 concurrent calls from %s, got %d", source.service, source.maxActive)
+		}
+	}
+
+	if summary := result.Summary(); summary != "6 cells: 6 passed (0 flaky), 0 failed" {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+}
+
+func TestCallMatrixSummary(t *testing.T) {
+	source := newMatrixSource("a", map[string][]string{"b": {"503", "200"}, "c": {"503"}})
+	result := CallMatrix(CallMatrixOptions{
+		Sources: []Instance{source},
+		Targets: []Instance{
+			&fakeInstance{cfg: Config{Service: "b"}},
+			&fakeInstance{cfg: Config{Service: "c"}},
+		},
+		Attempts:   2,
+		RetryDelay: time.Millisecond,
+	})
+
+	summary := result.Summary()
+	for _, expected := range []string{
+		"2 cells: 1 passed (1 flaky), 1 failed",
+		"flaky: a->b (2 attempts)",
+		"failed: a->c: ",
+	} {
+		if !strings.Contains(summary, expected) {
+			t.Fatalf("expected %q in summary:\n%s", expected, summary)
+		}
+	}
+}
+
+func checkCellNames(t *testing.T, kind string, cells []CallMatrixCell, expected []string) {
+	t.Helper()
+	names := make([]string, 0, len(cells))
+	for _, cell := range cells {
+		names = append(names, cell.Name())
+	}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected %s cells %v, got %v", kind, expected, names)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const (
+	// mirrorTimeout bounds the wait for mirrored requests, which Envoy sends asynchronously, to
+	// reach the mirror target.
+	mirrorTimeout = 10 * time.Second
+	mirrorDelay   = 500 * time.Millisecond
+)
+
+// CheckMirrorPercentage verifies that the percentage of the opts.Count requests from source that
+// were mirrored to mirror (e.g. by a VirtualService with a mirrorPercentage) is within tolerance
+// (in percentage points) of expectedPct. The requests are tagged with a unique value for the
+// common.RequestCountHeader header, which Envoy preserves for mirrored requests, so that only
+// requests made by this call are counted by the workloads of mirror. All requests must succeed.
+func CheckMirrorPercentage(source Instance, opts CallOptions, mirror Instance, expectedPct, tolerance float64) error {
+	if mirror == nil {
+		return errors.New("checkMirrorPercentage: missing mirror")
+	}
+	if opts.Count <= 0 {
+		return errors.New("checkMirrorPercentage: Count must be > 0")
+	}
+
+	key := tagRequests(&opts)
+	opts.Retry.Disabled = true
+	responses, err := source.Call(opts)
+	if err != nil {
+		return err
+	}
+	if err := responses.CheckOK(); err != nil {
+		return err
+	}
+
+	// Mirrored requests may still be in flight, so wait for the percentage to settle.
+	return retry.UntilSuccess(func() error {
+		mirrored, err := requestCount(mirror, key)
+		if err != nil {
+			return err
+		}
+		observed := 100 * float64(mirrored) / float64(opts.Count)
+		if math.Abs(observed-expectedPct) > tolerance {
+			return fmt.Errorf("%d of %d requests (%.1f%%) mirrored to %s, expected %.1f%% (tolerance %.1f)",
+				mirrored, opts.Count, observed, mirror.Config().Service, expectedPct, tolerance)
+		}
+		return nil
+	}, retry.Timeout(mirrorTimeout), retry.Delay(mirrorDelay))
+}
+
+// CheckMirrorPercentageOrFail calls CheckMirrorPercentage and fails t if an error occurs.
+func CheckMirrorPercentageOrFail(t testing.TB, source Instance, opts CallOptions, mirror Instance, expectedPct, tolerance float64) {
+	if err := CheckMirrorPercentage(source, opts, mirror, expectedPct, tolerance); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -15,10 +15,11 @@
 package native
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 
@@ -28,6 +29,7 @@ import (
 	"istio.io/istio/pkg/test/framework/components/environment/native"
 	"istio.io/istio/pkg/test/framework/resource"
 	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/retry"
 )
 
 var (
@@ -37,6 +39,7 @@ var (
 
 type instance struct {
 	id       resource.ID
+	env      *native.Environment
 	config   echo.Config
 	workload *workload
 }
@@ -44,6 +47,9 @@ type instance struct {
 // New creates a new native echo instance.
 func New(ctx resource.Context, cfg echo.Config) (out echo.Instance, err error) {
 	env := ctx.Environment().(*native.Environment)
+	if cfg.Gateway {
+		return nil, resource.UnsupportedEnvironment(env)
+	}
 
 	// Fill in defaults for any missing values.
 	if err = common.FillInDefaults(ctx, env.Domain, &cfg); err != nil {
@@ -51,6 +57,7 @@ func New(ctx resource.Context, cfg echo.Config) (out echo.Instance, err error) {
 	}
 
 	c := &instance{
+		env:    env,
 		config: cfg,
 	}
 	c.id = ctx.TrackResource(c)
@@ -68,10 +75,60 @@ func (c *instance) ID() resource.ID {
 	return c.id
 }
 
+func (c *instance) String() string {
+	return fmt.Sprintf("%s@%s", c.config.NamespacedName(), c.config.Version)
+}
+
+func (c *instance) Equals(other echo.Instance) bool {
+	return other != nil && c.ID().String() == other.ID().String()
+}
+
 func (c *instance) WaitUntilReady(outboundInstances ...echo.Instance) error {
+	return c.waitUntilReady(time.Time{}, outboundInstances...)
+}
+
+func (c *instance) WaitUntilReadyWithin(timeout time.Duration, outboundInstances ...echo.Instance) error {
+	err := c.waitUntilReady(time.Now().Add(timeout), outboundInstances...)
+	if err != nil {
+		return fmt.Errorf("service %s not ready within %v: %v", c.config.Service, timeout, err)
+	}
+	return nil
+}
+
+func (c *instance) WaitUntilNotReady(time.Duration) error {
+	return resource.UnsupportedEnvironment(c.env)
+}
+
+func (c *instance) IsReady(outboundInstances ...echo.Instance) (bool, error) {
+	deps, err := common.ReadinessDependencies(c)
+	if err != nil {
+		return false, err
+	}
+	outboundInstances = append(append([]echo.Instance{}, outboundInstances...), deps...)
+	for _, outbound := range outboundInstances {
+		if ready, err := outbound.IsReady(); !ready || err != nil {
+			return false, err
+		}
+	}
+
+	if c.workload.sidecar == nil {
+		return true, nil
+	}
+	return c.workload.sidecar.CheckConfig(common.OutboundConfigAcceptFunc(outboundInstances...))
+}
+
+// waitUntilReady implements WaitUntilReady, bounding each wait by the deadline, if not zero.
+func (c *instance) waitUntilReady(deadline time.Time, outboundInstances ...echo.Instance) error {
 	// No need to check for inbound readiness, since inbound ports for the native echo instance
 	// are configured by bootstrap.
 
+	// Wait for the dependencies, which also require outbound config.
+	deps, err := common.WaitForReadinessDependencies(c, deadline)
+	if err != nil {
+		return err
+	}
+	outboundInstances = append(append([]echo.Instance{}, outboundInstances...), deps...)
+
 	if c.workload.sidecar == nil {
 		// No sidecar, nothing to do.
 		return nil
@@ -79,12 +136,26 @@ func (c *instance) WaitUntilReady(outboundInstances ...echo.Instance) error {
 
 	// Wait until all of the outbound instances are ready.
 	for _, outbound := range outboundInstances {
-		if err := outbound.WaitUntilReady(); err != nil {
-			return err
+		var err error
+		if deadline.IsZero() {
+			err = outbound.WaitUntilReady()
+		} else {
+			err = outbound.WaitUntilReadyWithin(common.UntilDeadline(deadline))
+		}
+		if err != nil {
+			return fmt.Errorf("failed waiting for outbound service %s: %v", outbound, err)
 		}
 	}
 
-	return c.workload.sidecar.WaitForConfig(common.OutboundConfigAcceptFunc(outboundInstances...))
+	var options []retry.Option
+	if !deadline.IsZero() {
+		options = append(options, retry.Timeout(common.UntilDeadline(deadline)))
+	}
+	if err := c.workload.sidecar.WaitForConfig(common.OutboundConfigAcceptFunc(outboundInstances...),
+		options...); err != nil {
+		return fmt.Errorf("failed waiting for the outbound config: %v", err)
+	}
+	return nil
 }
 
 func (c *instance) WaitUntilReadyOrFail(t testing.TB, outboundInstances ...echo.Instance) {
@@ -97,6 +168,33 @@ func (c *instance) Address() string {
 	return localhost
 }
 
+func (c *instance) Addresses() ([]echo.ServiceAddress, error) {
+	out := make([]echo.ServiceAddress, 0, len(c.config.Ports))
+	for _, p := range c.config.Ports {
+		out = append(out, echo.ServiceAddress{Port: p, Address: c.Address(), PortNumber: p.ServicePort})
+	}
+	return out, nil
+}
+
+func (c *instance) AddressesOrFail(t testing.TB) []echo.ServiceAddress {
+	out, err := c.Addresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func (c *instance) AddressOf(addressType echo.AddressType) string {
+	switch addressType {
+	case echo.AddressTypeCluster:
+		return c.Address()
+	case echo.AddressTypeClusterIPv4, echo.AddressTypeClusterIPv6:
+		return common.AddressOfFamily(c.Address(), addressType)
+	}
+	// The native echo instance is only reachable locally.
+	return ""
+}
+
 func (c *instance) Config() echo.Config {
 	return c.config
 }
@@ -113,21 +211,66 @@ func (c *instance) WorkloadsOrFail(t testing.TB) []echo.Workload {
 	return out
 }
 
-func (c *instance) Call(opts echo.CallOptions) (client.ParsedResponses, error) {
-	out, err := c.workload.Call(&opts)
+func (c *instance) WorkloadsByVersion() (map[string][]echo.Workload, error) {
+	workloads, err := c.Workloads()
 	if err != nil {
-		if opts.Port != nil {
-			err = fmt.Errorf("failed calling %s->'%s://%s:%d/%s': %v",
-				c.Config().Service,
-				strings.ToLower(string(opts.Port.Protocol)),
-				opts.Target.Config().Service,
-				opts.Port.ServicePort,
-				opts.Path,
-				err)
-		}
 		return nil, err
 	}
-	return out, nil
+	return common.WorkloadsByVersion(workloads), nil
+}
+
+func (c *instance) WorkloadsByVersionOrFail(t testing.TB) map[string][]echo.Workload {
+	out, err := c.WorkloadsByVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func (c *instance) Sidecars() ([]echo.Sidecar, error) {
+	if c.workload.sidecar == nil {
+		return nil, nil
+	}
+	return []echo.Sidecar{c.workload.sidecar}, nil
+}
+
+func (c *instance) SidecarsOrFail(t testing.TB) []echo.Sidecar {
+	out, err := c.Sidecars()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func (c *instance) Call(opts echo.CallOptions) (client.ParsedResponses, error) {
+	return c.CallContext(context.Background(), opts)
+}
+
+func (c *instance) CallContext(ctx context.Context, opts echo.CallOptions) (client.ParsedResponses, error) {
+	result, err := c.callWithResult(ctx, opts)
+	return result.Responses, err
+}
+
+func (c *instance) CallWithResult(opts echo.CallOptions) (echo.CallResult, error) {
+	return c.callWithResult(context.Background(), opts)
+}
+
+func (c *instance) callWithResult(ctx context.Context, opts echo.CallOptions) (echo.CallResult, error) {
+	var duration time.Duration
+	out, err := common.RetryCall(ctx, &opts, c.config.Sidecar, func() (client.ParsedResponses, error) {
+		start := time.Now()
+		defer func() { duration = time.Since(start) }()
+		return c.workload.Call(ctx, &opts)
+	})
+	err = common.CheckMTLSMode(c.config.Sidecar, opts.Port, out, err)
+	if err != nil {
+		return echo.CallResult{}, common.CallError(c, &opts, err)
+	}
+	return echo.CallResult{
+		Responses: out,
+		Duration:  duration,
+		Source:    c.workload,
+	}, nil
 }
 
 func (c *instance) CallOrFail(t testing.TB, opts echo.CallOptions) client.ParsedResponses {
@@ -138,6 +281,54 @@ func (c *instance) CallOrFail(t testing.TB, opts echo.CallOptions) client.Parsed
 	return r
 }
 
+func (c *instance) Restart() error {
+	return resource.UnsupportedEnvironment(c.env)
+}
+
+func (c *instance) Scale(int) error {
+	return resource.UnsupportedEnvironment(c.env)
+}
+
+func (c *instance) Delete() error {
+	return resource.UnsupportedEnvironment(c.env)
+}
+
+func (c *instance) HealthSummary() (echo.HealthSummary, error) {
+	// The native environment runs a single, in-process workload.
+	return echo.HealthSummary{
+		Replicas: 1,
+		Ready:    1,
+	}, nil
+}
+
+func (c *instance) LabelWorkload(int, string, string) error {
+	return resource.UnsupportedEnvironment(c.env)
+}
+
+func (c *instance) WorkloadLabels(int) (map[string]string, error) {
+	return nil, resource.UnsupportedEnvironment(c.env)
+}
+
+func (c *instance) ExecInWorkload(int, []string) (string, string, error) {
+	return "", "", resource.UnsupportedEnvironment(c.env)
+}
+
+func (c *instance) Logs(string) (string, error) {
+	return "", resource.UnsupportedEnvironment(c.env)
+}
+
+func (c *instance) LogsOrFail(t testing.TB, container string) string {
+	out, err := c.Logs(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func (c *instance) DiagnosticBundle(string) error {
+	return resource.UnsupportedEnvironment(c.env)
+}
+
 func (c *instance) Close() (err error) {
 	if c.workload != nil {
 		scopes.Framework.Debugf("%s closing Echo workload", c.id)
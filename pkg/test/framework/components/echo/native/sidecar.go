@@ -17,6 +17,7 @@ package native
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -32,6 +33,7 @@ import (
 	"istio.io/istio/pkg/test/framework/components/echo/common"
 	"istio.io/istio/pkg/test/util/reserveport"
 	"istio.io/istio/pkg/test/util/retry"
+	"istio.io/istio/pkg/version"
 )
 
 const (
@@ -222,6 +224,16 @@ func (s *sidecar) NodeID() string {
 	return s.nodeID
 }
 
+func (s *sidecar) Logs() (string, error) {
+	// The native Envoy logs to the stdout of this process.
+	return "", errors.New("logs are not available for native sidecars")
+}
+
+func (s *sidecar) ProxyVersion() (string, error) {
+	// The native Envoy is run directly (without pilot-agent) as part of this build.
+	return version.Info.Version, nil
+}
+
 func (s *sidecar) Info() (*envoyAdmin.ServerInfo, error) {
 	return envoy.GetServerInfo(s.adminPort)
 }
@@ -246,10 +258,38 @@ func (s *sidecar) ConfigOrFail(t testing.TB) *envoyAdmin.ConfigDump {
 	return cfg
 }
 
+func (s *sidecar) Clusters() (*envoyAdmin.Clusters, error) {
+	return envoy.GetClusters(s.adminPort)
+}
+
+func (s *sidecar) ClustersOrFail(t testing.TB) *envoyAdmin.Clusters {
+	clusters, err := s.Clusters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clusters
+}
+
+func (s *sidecar) Stats() (map[string]int64, error) {
+	return envoy.GetStats(s.adminPort)
+}
+
+func (s *sidecar) StatsOrFail(t testing.TB) map[string]int64 {
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats
+}
+
 func (s *sidecar) WaitForConfig(accept func(*envoyAdmin.ConfigDump) (bool, error), options ...retry.Option) error {
 	return common.WaitForConfig(s.Config, accept, options...)
 }
 
+func (s *sidecar) CheckConfig(accept func(*envoyAdmin.ConfigDump) (bool, error)) (bool, error) {
+	return common.CheckConfig(s.Config, accept)
+}
+
 func (s *sidecar) WaitForConfigOrFail(t testing.TB, accept func(*envoyAdmin.ConfigDump) (bool, error), options ...retry.Option) {
 	if err := s.WaitForConfig(accept, options...); err != nil {
 		t.Fatal(err)
@@ -15,8 +15,10 @@
 package native
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"os"
 
 	"github.com/hashicorp/go-multierror"
 
@@ -43,12 +45,17 @@ type workload struct {
 	discoveryFilter discoveryFilter
 	echoServer      *server.Instance
 	sidecar         *sidecar
+	env             *native.Environment
+	version         string
 }
 
 func newWorkload(ctx resource.Context, cfg *echo.Config) (w *workload, err error) {
 	env := ctx.Environment().(*native.Environment)
 
-	out := &workload{}
+	out := &workload{
+		env:     env,
+		version: cfg.Version,
+	}
 
 	defer func() {
 		if err != nil {
@@ -58,7 +65,13 @@ func newWorkload(ctx resource.Context, cfg *echo.Config) (w *workload, err error
 
 	// Convert the configured ports for the echo application. Ignore any specified port numbers.
 	appPorts := make(model.PortList, 0, len(cfg.Ports))
+	mtlsModes := make(map[string]echo.MTLSMode, len(cfg.Ports))
+	controlPort := ""
 	for _, p := range cfg.Ports {
+		mtlsModes[p.Name] = p.MTLSMode
+		if p.ControlPort {
+			controlPort = p.Name
+		}
 		appPorts = append(appPorts, &model.Port{
 			Name:     p.Name,
 			Protocol: p.Protocol,
@@ -66,8 +79,11 @@ func newWorkload(ctx resource.Context, cfg *echo.Config) (w *workload, err error
 	}
 
 	out.echoServer = server.New(server.Config{
-		Ports:   appPorts,
-		Version: cfg.Version,
+		Ports:        appPorts,
+		Version:      cfg.Version,
+		Cluster:      cfg.Cluster,
+		StartupDelay: cfg.StartupDelay,
+		PathStatuses: cfg.PathStatuses,
 	})
 
 	// Create and start the Echo application
@@ -145,13 +161,17 @@ func newWorkload(ctx resource.Context, cfg *echo.Config) (w *workload, err error
 		}
 	}
 
+	// Restore the expected mTLS modes and the control port, which aren't known to the application or
+	// sidecar.
+	for i := range cfg.Ports {
+		cfg.Ports[i].MTLSMode = mtlsModes[cfg.Ports[i].Name]
+		cfg.Ports[i].ControlPort = cfg.Ports[i].Name == controlPort
+	}
+
 	// Get the GRPC port.
 	var grpcPort uint16
-	for _, p := range cfg.Ports {
-		if p.Protocol == model.ProtocolGRPC {
-			grpcPort = uint16(p.InstancePort)
-			break
-		}
+	if p := common.GetGRPCPort(cfg); p != nil {
+		grpcPort = uint16(p.InstancePort)
 	}
 
 	// Create the client for sending forward requests.
@@ -171,13 +191,58 @@ func (w *workload) Address() string {
 	return localhost
 }
 
+func (w *workload) Hostname() string {
+	// The native echo server runs in this process.
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+func (w *workload) PodName() string {
+	return ""
+}
+
+func (w *workload) NodeName() string {
+	return ""
+}
+
+func (w *workload) Version() string {
+	return w.version
+}
+
+func (w *workload) SecondaryAddresses() []string {
+	return nil
+}
+
+func (w *workload) PodFQDN() string {
+	// Native workloads are not addressable individually via DNS.
+	return ""
+}
+
+func (w *workload) RequestCount(key string) (int, error) {
+	return w.Instance.RequestCount(context.Background(), key)
+}
+
+func (w *workload) Exec([]string) (string, string, error) {
+	return "", "", resource.UnsupportedEnvironment(w.env)
+}
+
+func (w *workload) ForwardPort(uint16) (uint16, func(), error) {
+	return 0, nil, resource.UnsupportedEnvironment(w.env)
+}
+
+func (w *workload) Logs(string) (string, error) {
+	return "", resource.UnsupportedEnvironment(w.env)
+}
+
 func (w *workload) Sidecar() echo.Sidecar {
 	return w.sidecar
 }
 
-func (w *workload) Call(opts *echo.CallOptions) (client.ParsedResponses, error) {
-	// Override the Host.
-	opts.Host = localhost
+func (w *workload) Call(ctx context.Context, opts *echo.CallOptions) (client.ParsedResponses, error) {
+	// Override the Host, unless the call targets a (local) workload directly.
+	if opts.TargetWorkload == nil {
+		opts.Host = localhost
+	}
 
 	portSelector := common.IdentityOutboundPortSelector
 	if w.discoveryFilter != nil {
@@ -186,7 +251,11 @@ func (w *workload) Call(opts *echo.CallOptions) (client.ParsedResponses, error)
 		portSelector = w.discoveryFilter.GetBoundOutboundListenerPort
 	}
 
-	return common.CallEcho(w.Instance, opts, portSelector)
+	out, err := common.CallEcho(ctx, w.Instance, opts, portSelector)
+	for _, r := range out {
+		r.Source = w.Hostname()
+	}
+	return out, err
 }
 
 func (w *workload) Close() (err error) {
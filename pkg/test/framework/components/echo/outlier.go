@@ -0,0 +1,89 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/echo/client"
+)
+
+const (
+	outlierCallsPerBatch = 10
+	outlierBatchDelay    = 500 * time.Millisecond
+)
+
+// AssertOutlierEjection verifies that Envoy's outlier detection ejects a failing workload of the
+// target. The failing workload is instructed (via the echo app's fault directive) to respond with
+// 503 to every call, while calls are repeatedly made from source until a batch of calls is served
+// entirely by the remaining (healthy) workloads. An error is returned if the failing workload is still
+// receiving traffic after the given duration. opts.Count is the number of calls per batch.
+func AssertOutlierEjection(source Instance, opts CallOptions, failing Workload, within time.Duration) error {
+	if opts.Target == nil {
+		return errors.New("assertOutlierEjection: missing Target")
+	}
+	if opts.Count <= 0 {
+		opts.Count = outlierCallsPerBatch
+	}
+	opts.Retry.Disabled = true
+	opts.Path = withQuery(opts.Path, url.Values{
+		"codes": []string{"503"},
+		"hosts": []string{failing.Hostname()},
+	})
+
+	var last client.ParsedResponses
+	deadline := time.Now().Add(within)
+	for {
+		responses, err := source.Call(opts)
+		if err != nil {
+			return err
+		}
+		last = responses
+
+		dist := responses.Distribution()
+		if dist[failing.Hostname()] == 0 && responses.CheckOK() == nil {
+			// The failing workload has been ejected.
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(outlierBatchDelay)
+	}
+
+	return fmt.Errorf("workload %s of %s was not ejected within %v. Last distribution: %v",
+		failing.Hostname(), opts.Target.Config().Service, within, last.Distribution())
+}
+
+// AssertOutlierEjectionOrFail calls AssertOutlierEjection and fails t if an error occurs.
+func AssertOutlierEjectionOrFail(t testing.TB, source Instance, opts CallOptions, failing Workload, within time.Duration) {
+	if err := AssertOutlierEjection(source, opts, failing, within); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// withQuery appends the given query parameters to the path.
+func withQuery(path string, query url.Values) string {
+	if strings.Contains(path, "?") {
+		return path + "&" + query.Encode()
+	}
+	return path + "?" + query.Encode()
+}
@@ -0,0 +1,251 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/test/echo/client"
+)
+
+// CheckPeerAuthMode verifies that the port of opts.Target enforces the expected mTLS mode, as
+// configured by a PeerAuthentication. The same call is made as plaintext, from the plaintext source
+// (without a sidecar), and as mTLS, from the mtls source (with a sidecar):
+//   - STRICT: only the mTLS call is accepted.
+//   - PERMISSIVE: both calls are accepted, each with the mTLS (or not) it was made with.
+//   - DISABLE: both calls are accepted as plaintext.
+//
+// For HTTP-based ports, the use of mTLS is verified via the X-Forwarded-Client-Cert header received
+// by the target.
+func CheckPeerAuthMode(plaintext, mtls Instance, opts CallOptions, expectedMode MTLSMode) error {
+	if opts.Target == nil {
+		return errors.New("checkPeerAuthMode: missing Target")
+	}
+	if err := checkPeerAuthSources(plaintext, mtls); err != nil {
+		return err
+	}
+
+	expectPlaintext, expectMTLS, err := peerAuthExpectations(expectedMode)
+	if err != nil {
+		return err
+	}
+	_, _, err = checkPeerAuthCalls(plaintext, mtls, opts, expectPlaintext, expectMTLS)
+	return err
+}
+
+// PortMTLSResult is the outcome of the calls to a single port, for CheckPortLevelMTLS.
+type PortMTLSResult struct {
+	Port Port
+
+	// PlaintextAccepted and MTLSAccepted indicate whether the plaintext and mTLS calls were accepted.
+	PlaintextAccepted bool
+	MTLSAccepted      bool
+
+	// Err describing how the port did not enforce its MTLSMode, or nil if it did.
+	Err error
+}
+
+// PortMTLSResults of CheckPortLevelMTLS, with one result for each port.
+type PortMTLSResults []PortMTLSResult
+
+// Err returns an error describing all failed ports, or nil if all ports enforced their MTLSMode.
+func (r PortMTLSResults) Err() (err error) {
+	for _, p := range r {
+		if p.Err != nil {
+			err = multierror.Append(err, fmt.Errorf("port %s (%s): %v", p.Port.Name, p.Port.MTLSMode, p.Err))
+		}
+	}
+	return
+}
+
+// String returns a table of the results, with a row for each port.
+func (r PortMTLSResults) String() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "PORT\tMODE\tPLAINTEXT\tMTLS\tRESULT")
+	for _, p := range r {
+		result := "ok"
+		if p.Err != nil {
+			result = "FAILED"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.Port.Name, p.Port.MTLSMode,
+			acceptedString(p.PlaintextAccepted), acceptedString(p.MTLSAccepted), result)
+	}
+	_ = w.Flush()
+	return b.String()
+}
+
+func acceptedString(accepted bool) string {
+	if accepted {
+		return "accepted"
+	}
+	return "rejected"
+}
+
+// CheckPortLevelMTLS verifies that each port of opts.Target with an MTLSMode enforces that mode, as
+// configured by the portLevelMtls of a PeerAuthentication, by calling each port as plaintext and as
+// mTLS (see CheckPeerAuthMode). The ports are checked in parallel. The Port and PortName of opts are
+// ignored.
+func CheckPortLevelMTLS(plaintext, mtls Instance, opts CallOptions) (PortMTLSResults, error) {
+	if opts.Target == nil {
+		return nil, errors.New("checkPortLevelMTLS: missing Target")
+	}
+	if err := checkPeerAuthSources(plaintext, mtls); err != nil {
+		return nil, err
+	}
+
+	var ports []Port
+	for _, p := range opts.Target.Config().Ports {
+		if p.MTLSMode != "" && !p.Unexposed {
+			ports = append(ports, p)
+		}
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("checkPortLevelMTLS: target %s has no ports with an MTLSMode",
+			opts.Target.Config().Service)
+	}
+
+	results := make(PortMTLSResults, len(ports))
+	wg := sync.WaitGroup{}
+	for i := range ports {
+		result := &results[i]
+		result.Port = ports[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			expectPlaintext, expectMTLS, err := peerAuthExpectations(result.Port.MTLSMode)
+			if err != nil {
+				result.Err = err
+				return
+			}
+			portOpts := opts
+			portOpts.Port = &result.Port
+			portOpts.PortName = ""
+			result.PlaintextAccepted, result.MTLSAccepted, result.Err = checkPeerAuthCalls(plaintext, mtls, portOpts,
+				expectPlaintext, expectMTLS)
+		}()
+	}
+	wg.Wait()
+
+	return results, results.Err()
+}
+
+// CheckPortLevelMTLSOrFail calls CheckPortLevelMTLS and fails t with the table of results if an
+// error occurs.
+func CheckPortLevelMTLSOrFail(t testing.TB, plaintext, mtls Instance, opts CallOptions) PortMTLSResults {
+	results, err := CheckPortLevelMTLS(plaintext, mtls, opts)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, results)
+	}
+	return results
+}
+
+// checkPeerAuthSources verifies that the plaintext source has no sidecar, and the mTLS source has one.
+func checkPeerAuthSources(plaintext, mtls Instance) error {
+	if plaintext.Config().Sidecar {
+		return fmt.Errorf("checkPeerAuthMode: plaintext source %s has a sidecar", plaintext.Config().Service)
+	}
+	if !mtls.Config().Sidecar {
+		return fmt.Errorf("checkPeerAuthMode: mTLS source %s has no sidecar", mtls.Config().Service)
+	}
+	return nil
+}
+
+// peerAuthExpectations returns whether plaintext calls are expected to be accepted in the mode, and
+// whether the (accepted) calls from a sidecar are expected to use mTLS.
+func peerAuthExpectations(mode MTLSMode) (expectPlaintext, expectMTLS bool, err error) {
+	switch mode {
+	case MTLSModeStrict:
+		return false, true, nil
+	case MTLSModePermissive:
+		return true, true, nil
+	case MTLSModeDisable:
+		return true, false, nil
+	default:
+		return false, false, fmt.Errorf("checkPeerAuthMode: unsupported mode %q", mode)
+	}
+}
+
+// checkPeerAuthCalls makes the plaintext and mTLS calls, and reports whether each was accepted.
+func checkPeerAuthCalls(plaintext, mtls Instance, opts CallOptions, expectPlaintext, expectMTLS bool) (
+	plaintextAccepted, mtlsAccepted bool, err error) {
+	plaintextAccepted, e := checkPeerAuthCall(plaintext, opts, expectPlaintext, false)
+	if e != nil {
+		err = multierror.Append(err, fmt.Errorf("plaintext call from %s: %v", plaintext.Config().Service, e))
+	}
+	mtlsAccepted, e = checkPeerAuthCall(mtls, opts, true, expectMTLS)
+	if e != nil {
+		err = multierror.Append(err, fmt.Errorf("mTLS call from %s: %v", mtls.Config().Service, e))
+	}
+	return
+}
+
+// checkPeerAuthCall makes the call from the source, and verifies whether it was accepted and made
+// with mTLS.
+func checkPeerAuthCall(source Instance, opts CallOptions, expectAccepted, expectMTLS bool) (bool, error) {
+	opts.Retry.Disabled = true
+	if port := targetPort(opts); port != nil && port.MTLSMode != "" {
+		// Call the port without its MTLSMode, so that the outcome isn't already checked (and turned
+		// into an error) by Call.
+		p := *port
+		p.MTLSMode = ""
+		opts.Port = &p
+		opts.PortName = ""
+	}
+	responses, err := source.Call(opts)
+	accepted := err == nil && responses.CheckOK() == nil
+	switch {
+	case accepted && !expectAccepted:
+		return accepted, errors.New("call was accepted, but expected it to be rejected")
+	case !accepted && expectAccepted:
+		if err == nil {
+			err = responses.CheckOK()
+		}
+		return accepted, fmt.Errorf("call was rejected, but expected it to be accepted: %v", err)
+	case !accepted:
+		return accepted, nil
+	}
+
+	if port := targetPort(opts); port != nil {
+		switch port.Protocol {
+		case model.ProtocolTCP, model.ProtocolHTTPS, model.ProtocolTLS:
+			// The target's sidecar doesn't forward the client certificate details.
+			return accepted, nil
+		}
+	}
+
+	return accepted, responses.Check(func(i int, response *client.ParsedResponse) error {
+		if response.IsMTLS() != expectMTLS {
+			return fmt.Errorf("response[%d] mTLS: expected %v, received %v", i, expectMTLS, response.IsMTLS())
+		}
+		return nil
+	})
+}
+
+// CheckPeerAuthModeOrFail calls CheckPeerAuthMode and fails t if an error occurs.
+func CheckPeerAuthModeOrFail(t testing.TB, plaintext, mtls Instance, opts CallOptions, expectedMode MTLSMode) {
+	if err := CheckPeerAuthMode(plaintext, mtls, opts, expectedMode); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/test/echo/client"
+)
+
+// peerAuthSource calls a target whose ports enforce the given (actual) modes, which may differ from
+// the MTLSMode configured for the ports.
+type peerAuthSource struct {
+	Instance
+	service string
+	sidecar bool
+	modes   map[string]MTLSMode
+}
+
+func (s *peerAuthSource) Config() Config {
+	return Config{Service: s.service, Sidecar: s.sidecar}
+}
+
+func (s *peerAuthSource) Call(opts CallOptions) (client.ParsedResponses, error) {
+	port := targetPort(opts)
+	accepted := s.sidecar || s.modes[port.Name] != MTLSModeStrict
+	mtls := s.sidecar && s.modes[port.Name] != MTLSModeDisable
+
+	// Like the Call of the kube and native instances, check the outcome against the MTLSMode of the
+	// port (see common.CheckMTLSMode).
+	if port.MTLSMode == MTLSModeStrict && !s.sidecar {
+		if accepted {
+			return nil, errors.New("plaintext call was accepted, but the port is STRICT")
+		}
+		return nil, nil
+	}
+
+	if !accepted {
+		return nil, errors.New("connection reset by peer")
+	}
+	response := &client.ParsedResponse{Code: "200"}
+	if mtls {
+		response.ForwardedClientCert = "By=spiffe://cluster.local/ns/ns/sa/b"
+	}
+	return client.ParsedResponses{response}, nil
+}
+
+func newPeerAuthTest(modes map[string]MTLSMode) (plaintext, mtls Instance) {
+	return &peerAuthSource{service: "a", modes: modes}, &peerAuthSource{service: "c", sidecar: true, modes: modes}
+}
+
+func TestCheckPeerAuthMode(t *testing.T) {
+	cases := []struct {
+		name     string
+		actual   MTLSMode
+		port     MTLSMode
+		expected MTLSMode
+		err      string
+	}{
+		{name: "strict", actual: MTLSModeStrict, expected: MTLSModeStrict},
+		{name: "permissive", actual: MTLSModePermissive, expected: MTLSModePermissive},
+		{name: "disable", actual: MTLSModeDisable, expected: MTLSModeDisable},
+		{name: "plaintext accepted when strict", actual: MTLSModePermissive, expected: MTLSModeStrict,
+			err: "plaintext call from a: call was accepted, but expected it to be rejected"},
+		// The outcome of the calls isn't turned into an error by Call for the MTLSMode of the port.
+		{name: "plaintext accepted by strict port", actual: MTLSModePermissive, port: MTLSModeStrict, expected: MTLSModeStrict,
+			err: "plaintext call from a: call was accepted, but expected it to be rejected"},
+		{name: "plaintext rejected when permissive", actual: MTLSModeStrict, expected: MTLSModePermissive,
+			err: "plaintext call from a: call was rejected, but expected it to be accepted"},
+		{name: "mTLS when disabled", actual: MTLSModePermissive, expected: MTLSModeDisable,
+			err: "response[0] mTLS: expected false, received true"},
+		{name: "no mTLS when permissive", actual: MTLSModeDisable, expected: MTLSModePermissive,
+			err: "response[0] mTLS: expected true, received false"},
+		{name: "unsupported mode", actual: MTLSModeStrict, expected: "UNSET", err: `unsupported mode "UNSET"`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			target := &fakeInstance{cfg: Config{
+				Service: "b",
+				Ports:   []Port{{Name: "http", Protocol: model.ProtocolHTTP, ServicePort: 80, MTLSMode: c.port}},
+			}}
+			plaintext, mtls := newPeerAuthTest(map[string]MTLSMode{"http": c.actual})
+
+			err := CheckPeerAuthMode(plaintext, mtls, CallOptions{Target: target, PortName: "http"}, c.expected)
+			if c.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("expected error containing %q, got: %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestCheckPortLevelMTLS(t *testing.T) {
+	target := &fakeInstance{cfg: Config{
+		Service: "b",
+		Ports: []Port{
+			{Name: "strict", Protocol: model.ProtocolHTTP, ServicePort: 80, MTLSMode: MTLSModeStrict},
+			{Name: "permissive", Protocol: model.ProtocolHTTP, ServicePort: 81, MTLSMode: MTLSModePermissive},
+			{Name: "disable", Protocol: model.ProtocolHTTP, ServicePort: 82, MTLSMode: MTLSModeDisable},
+			{Name: "unset", Protocol: model.ProtocolHTTP, ServicePort: 83},
+		},
+	}}
+
+	t.Run("enforced", func(t *testing.T) {
+		plaintext, mtls := newPeerAuthTest(map[string]MTLSMode{
+			"strict":     MTLSModeStrict,
+			"permissive": MTLSModePermissive,
+			"disable":    MTLSModeDisable,
+		})
+		results, err := CheckPortLevelMTLS(plaintext, mtls, CallOptions{Target: target})
+		if err != nil {
+			t.Fatalf("%v\n%s", err, results)
+		}
+		// The port without an MTLSMode isn't checked.
+		if len(results) != 3 {
+			t.Fatalf("expected results for 3 ports, got:\n%s", results)
+		}
+		if r := results[0]; r.Port.Name != "strict" || r.PlaintextAccepted || !r.MTLSAccepted {
+			t.Fatalf("expected only the mTLS call to the strict port to be accepted, got:\n%s", results)
+		}
+	})
+
+	t.Run("plaintext accepted by strict port", func(t *testing.T) {
+		plaintext, mtls := newPeerAuthTest(map[string]MTLSMode{
+			"strict":     MTLSModePermissive,
+			"permissive": MTLSModePermissive,
+			"disable":    MTLSModeDisable,
+		})
+		results, err := CheckPortLevelMTLS(plaintext, mtls, CallOptions{Target: target})
+		if err == nil || !strings.Contains(err.Error(), "plaintext call from a: call was accepted") {
+			t.Fatalf("expected the plaintext call to the strict port to fail the check, got: %v\n%s", err, results)
+		}
+		if r := results[0]; !r.PlaintextAccepted || r.Err == nil {
+			t.Fatalf("expected the plaintext call to the strict port to be reported as accepted, got:\n%s", results)
+		}
+		if results[1].Err != nil || results[2].Err != nil {
+			t.Fatalf("expected the other ports to pass, got:\n%s", results)
+		}
+	})
+}
@@ -0,0 +1,201 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const (
+	// configUpdateCounter is the Envoy counter incremented for every cluster (CDS) update accepted
+	// from the control plane. Since Pilot pushes the full config on (re)connect, it resumes counting
+	// once the sidecar has reconnected.
+	configUpdateCounter = "cluster_manager.cds.update_success"
+
+	defaultResilienceCallInterval     = 500 * time.Millisecond
+	defaultResilienceOutage           = 10 * time.Second
+	defaultResilienceReconnectTimeout = 2 * time.Minute
+)
+
+// ResilienceOptions for AssertDataPlaneResilientToControlPlane.
+type ResilienceOptions struct {
+	// Disrupt makes the control plane unavailable to the source workloads, for example by killing
+	// the Pilot pod(s) or blocking the sidecars' connections to Pilot. Required.
+	Disrupt func() error
+
+	// Restore makes the control plane available again, if this doesn't happen on its own (e.g.
+	// the Pilot deployment recreating a killed pod). Optional.
+	Restore func() error
+
+	// CallInterval between the background calls. Defaults to 500ms.
+	CallInterval time.Duration
+
+	// Outage is the time to keep the control plane unavailable, while calls continue to be made,
+	// before calling Restore. Defaults to 10s.
+	Outage time.Duration
+
+	// ReconnectTimeout is the maximum time to wait for every sidecar to reconnect after the
+	// outage. Defaults to 2m.
+	ReconnectTimeout time.Duration
+}
+
+func (o *ResilienceOptions) fillInDefaults() {
+	if o.CallInterval <= 0 {
+		o.CallInterval = defaultResilienceCallInterval
+	}
+	if o.Outage <= 0 {
+		o.Outage = defaultResilienceOutage
+	}
+	if o.ReconnectTimeout <= 0 {
+		o.ReconnectTimeout = defaultResilienceReconnectTimeout
+	}
+}
+
+// AssertDataPlaneResilientToControlPlane verifies that calls from source keep succeeding, using the
+// last-known config, while the control plane is unavailable, and that the source sidecars reconnect
+// to the control plane afterwards.
+//
+// Calls are made in the background throughout the disruption. Reconnection is detected by the
+// sidecars' config update counter resuming after the outage. Calls continue until every sidecar has
+// reconnected, and an error is returned if any of them failed.
+func AssertDataPlaneResilientToControlPlane(source Instance, opts CallOptions, ropts ResilienceOptions) error {
+	if ropts.Disrupt == nil {
+		return errors.New("assertDataPlaneResilientToControlPlane: missing Disrupt")
+	}
+	ropts.fillInDefaults()
+
+	workloads, err := source.Workloads()
+	if err != nil {
+		return err
+	}
+	sidecars := make([]Sidecar, 0, len(workloads))
+	for _, w := range workloads {
+		if w.Sidecar() == nil {
+			return fmt.Errorf("assertDataPlaneResilientToControlPlane: source %s has no sidecar",
+				source.Config().Service)
+		}
+		sidecars = append(sidecars, w.Sidecar())
+	}
+
+	// Make sure calls work before disrupting anything.
+	if err := callOK(source, opts); err != nil {
+		return fmt.Errorf("call failed before the control plane disruption: %v", err)
+	}
+
+	// Snapshot the counters before the disruption: the sidecars may already have reconnected by the
+	// time Disrupt returns (e.g. to a quickly recreated Pilot pod), so later values could miss it.
+	disrupted := make([]int64, len(sidecars))
+	for i, s := range sidecars {
+		stats, err := s.Stats()
+		if err != nil {
+			return err
+		}
+		disrupted[i] = stats[configUpdateCounter]
+	}
+
+	// Make calls in the background until stopped.
+	var (
+		callErrs error
+		calls    int
+		mutex    sync.Mutex
+		wg       sync.WaitGroup
+	)
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(ropts.CallInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				err := callOK(source, opts)
+				mutex.Lock()
+				calls++
+				if err != nil {
+					callErrs = multierror.Append(callErrs, fmt.Errorf("call %d: %v", calls, err))
+				}
+				mutex.Unlock()
+			}
+		}
+	}()
+	stopCalls := func() error {
+		close(stop)
+		wg.Wait()
+		if callErrs != nil {
+			return fmt.Errorf("calls failed while the control plane was disrupted: %v", callErrs)
+		}
+		return nil
+	}
+
+	if err := ropts.Disrupt(); err != nil {
+		_ = stopCalls()
+		return fmt.Errorf("failed disrupting the control plane: %v", err)
+	}
+
+	time.Sleep(ropts.Outage)
+
+	if ropts.Restore != nil {
+		if err := ropts.Restore(); err != nil {
+			_ = stopCalls()
+			return fmt.Errorf("failed restoring the control plane: %v", err)
+		}
+	}
+
+	reconnectErr := retry.UntilSuccess(func() error {
+		for i, s := range sidecars {
+			stats, err := s.Stats()
+			if err != nil {
+				return err
+			}
+			if stats[configUpdateCounter] <= disrupted[i] {
+				return fmt.Errorf("sidecar %s has not reconnected to the control plane: %s=%d",
+					s.NodeID(), configUpdateCounter, stats[configUpdateCounter])
+			}
+		}
+		return nil
+	}, retry.Timeout(ropts.ReconnectTimeout), retry.Delay(time.Second))
+
+	return multierror.Append(stopCalls(), reconnectErr).ErrorOrNil()
+}
+
+// AssertDataPlaneResilientToControlPlaneOrFail calls AssertDataPlaneResilientToControlPlane and
+// fails t if an error occurs.
+func AssertDataPlaneResilientToControlPlaneOrFail(t testing.TB, source Instance, opts CallOptions, ropts ResilienceOptions) {
+	if err := AssertDataPlaneResilientToControlPlane(source, opts, ropts); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// callOK makes a call from source and verifies that all responses are OK.
+func callOK(source Instance, opts CallOptions) error {
+	// A retry would hide a failure during the outage.
+	opts.Retry.Disabled = true
+	responses, err := source.Call(opts)
+	if err != nil {
+		return err
+	}
+	return responses.CheckOK()
+}
@@ -0,0 +1,160 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/echo/client"
+)
+
+// fakeSidecar counts the config updates received from the control plane.
+type fakeSidecar struct {
+	Sidecar
+
+	mutex   sync.Mutex
+	updates int64
+}
+
+func (s *fakeSidecar) NodeID() string {
+	return "sidecar~10.0.0.1~a-v1-0.ns~ns.svc.cluster.local"
+}
+
+func (s *fakeSidecar) Stats() (map[string]int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return map[string]int64{configUpdateCounter: s.updates}, nil
+}
+
+// reconnect simulates the full config push of the control plane on reconnect.
+func (s *fakeSidecar) reconnect() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.updates++
+}
+
+type fakeWorkload struct {
+	Workload
+	sidecar Sidecar
+}
+
+func (w *fakeWorkload) Sidecar() Sidecar {
+	return w.sidecar
+}
+
+// resilienceSource is a source Instance with a single workload, whose calls fail while failing is set.
+type resilienceSource struct {
+	Instance
+	sidecar *fakeSidecar
+
+	mutex   sync.Mutex
+	failing bool
+}
+
+func (s *resilienceSource) Config() Config {
+	return Config{Service: "a"}
+}
+
+func (s *resilienceSource) Workloads() ([]Workload, error) {
+	return []Workload{&fakeWorkload{sidecar: s.sidecar}}, nil
+}
+
+func (s *resilienceSource) Call(CallOptions) (client.ParsedResponses, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.failing {
+		return client.ParsedResponses{{Code: "503"}}, nil
+	}
+	return client.ParsedResponses{{Code: "200"}}, nil
+}
+
+func (s *resilienceSource) setFailing(failing bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failing = failing
+}
+
+func TestAssertDataPlaneResilientToControlPlane(t *testing.T) {
+	newOptions := func(disrupt, restore func() error) ResilienceOptions {
+		return ResilienceOptions{
+			Disrupt:          disrupt,
+			Restore:          restore,
+			CallInterval:     time.Millisecond,
+			Outage:           50 * time.Millisecond,
+			ReconnectTimeout: 50 * time.Millisecond,
+		}
+	}
+
+	t.Run("reconnected after restore", func(t *testing.T) {
+		source := &resilienceSource{sidecar: &fakeSidecar{updates: 3}}
+		ropts := newOptions(func() error { return nil }, func() error {
+			source.sidecar.reconnect()
+			return nil
+		})
+		if err := AssertDataPlaneResilientToControlPlane(source, CallOptions{}, ropts); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("reconnected before Disrupt returns", func(t *testing.T) {
+		// E.g. the Pilot pod is recreated quickly, and the sidecar reconnects to it right away.
+		source := &resilienceSource{sidecar: &fakeSidecar{updates: 3}}
+		ropts := newOptions(func() error {
+			source.sidecar.reconnect()
+			return nil
+		}, nil)
+		if err := AssertDataPlaneResilientToControlPlane(source, CallOptions{}, ropts); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("not reconnected", func(t *testing.T) {
+		source := &resilienceSource{sidecar: &fakeSidecar{updates: 3}}
+		ropts := newOptions(func() error { return nil }, nil)
+		err := AssertDataPlaneResilientToControlPlane(source, CallOptions{}, ropts)
+		if err == nil || !strings.Contains(err.Error(), "has not reconnected") {
+			t.Fatalf("expected error for a sidecar that did not reconnect, got: %v", err)
+		}
+	})
+
+	t.Run("calls failed during the outage", func(t *testing.T) {
+		source := &resilienceSource{sidecar: &fakeSidecar{updates: 3}}
+		ropts := newOptions(func() error {
+			source.setFailing(true)
+			return nil
+		}, func() error {
+			source.setFailing(false)
+			source.sidecar.reconnect()
+			return nil
+		})
+		err := AssertDataPlaneResilientToControlPlane(source, CallOptions{}, ropts)
+		if err == nil || !strings.Contains(err.Error(), "calls failed while the control plane was disrupted") {
+			t.Fatalf("expected error for calls failed during the outage, got: %v", err)
+		}
+	})
+
+	t.Run("disrupt failed", func(t *testing.T) {
+		source := &resilienceSource{sidecar: &fakeSidecar{}}
+		ropts := newOptions(func() error { return errors.New("pilot not found") }, nil)
+		err := AssertDataPlaneResilientToControlPlane(source, CallOptions{}, ropts)
+		if err == nil || !strings.Contains(err.Error(), "pilot not found") {
+			t.Fatalf("expected error for the failed disruption, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pkg/test/echo/common"
+)
+
+// CheckRetries verifies the number of attempts that reached the target for a single call. The call
+// is tagged with a unique value for the common.RequestCountHeader header, so that every attempt
+// (i.e. the original request and any retries made by Envoy) is counted by the target workloads. The
+// total across all workloads of opts.Target must equal expected. The outcome of the call itself is
+// not checked, since the final attempt may legitimately fail when retries are exhausted (e.g. with
+// an injected 5xx), but its error is included if the attempts don't match.
+func CheckRetries(source Instance, opts CallOptions, expected int) error {
+	if opts.Target == nil {
+		return errors.New("checkRetries: missing Target")
+	}
+
+	key := tagRequests(&opts)
+	opts.Count = 1
+	opts.Retry.Disabled = true
+
+	_, callErr := source.Call(opts)
+
+	attempts, err := requestCount(opts.Target, key)
+	if err != nil {
+		return err
+	}
+
+	if attempts != expected {
+		err := fmt.Errorf("expected %d attempts to reach %s, but received %d",
+			expected, opts.Target.Config().Service, attempts)
+		if callErr != nil {
+			return multierror.Append(err, callErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// CheckRetriesOrFail calls CheckRetries and fails t if an error occurs.
+func CheckRetriesOrFail(t testing.TB, source Instance, opts CallOptions, expected int) {
+	if err := CheckRetries(source, opts, expected); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tagRequests tags the requests made with opts with a unique value for the common.RequestCountHeader
+// header, which is returned.
+func tagRequests(opts *CallOptions) string {
+	key := uuid.New().String()
+	headers := make(http.Header)
+	for k, v := range opts.Headers {
+		headers[k] = v
+	}
+	headers.Set(common.RequestCountHeader, key)
+	opts.Headers = headers
+	return key
+}
+
+// requestCount returns the total number of requests tagged with key that were received by the
+// workloads of target.
+func requestCount(target Instance, key string) (int, error) {
+	workloads, err := target.Workloads()
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, w := range workloads {
+		count, err := w.RequestCount(key)
+		if err != nil {
+			return 0, fmt.Errorf("failed retrieving request count from workload %s: %v", w.Hostname(), err)
+		}
+		total += count
+	}
+	return total, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/echo/common"
+)
+
+// requestCountWorkload counts the tagged requests received by a workload of the target.
+type requestCountWorkload struct {
+	Workload
+	counts map[string]int
+}
+
+func (w *requestCountWorkload) Hostname() string {
+	return "b-v1-0"
+}
+
+func (w *requestCountWorkload) RequestCount(key string) (int, error) {
+	return w.counts[key], nil
+}
+
+type requestCountTarget struct {
+	Instance
+	workload *requestCountWorkload
+}
+
+func (t *requestCountTarget) Config() Config {
+	return Config{Service: "b"}
+}
+
+func (t *requestCountTarget) Workloads() ([]Workload, error) {
+	return []Workload{t.workload}, nil
+}
+
+// retriesSource makes the given number of attempts for a call, all of which fail with a 503 fault.
+type retriesSource struct {
+	Instance
+	attempts int
+}
+
+func (s *retriesSource) Call(opts CallOptions) (client.ParsedResponses, error) {
+	if !opts.Retry.Disabled {
+		return nil, errors.New("the attempts must not be multiplied by retried calls")
+	}
+	target := opts.Target.(*requestCountTarget)
+	target.workload.counts[opts.Headers.Get(common.RequestCountHeader)] += s.attempts
+	return client.ParsedResponses{{Code: "503"}}, errors.New("response[0] Status Code: expected 200, received 503")
+}
+
+func TestCheckRetries(t *testing.T) {
+	newTarget := func() *requestCountTarget {
+		return &requestCountTarget{workload: &requestCountWorkload{counts: make(map[string]int)}}
+	}
+
+	// The failure of the final attempt doesn't prevent counting the attempts.
+	if err := CheckRetries(&retriesSource{attempts: 3}, CallOptions{Target: newTarget()}, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	err := CheckRetries(&retriesSource{attempts: 2}, CallOptions{Target: newTarget()}, 3)
+	if err == nil || !strings.Contains(err.Error(), "expected 3 attempts to reach b, but received 2") ||
+		!strings.Contains(err.Error(), "received 503") {
+		t.Fatalf("expected error for the mismatched attempts, including the call error, got: %v", err)
+	}
+
+	if err := CheckRetries(&retriesSource{}, CallOptions{}, 1); err == nil {
+		t.Fatal("expected error for missing Target")
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const (
+	// noHealthyUpstream is the body of the 503 returned by Envoy for a cluster without endpoints.
+	noHealthyUpstream = "no healthy upstream"
+
+	defaultScaleToZeroCallTimeout = 5 * time.Second
+	defaultScaleToZeroPrompt      = time.Second
+	defaultScaleToZeroTimeout     = 2 * time.Minute
+	scaleToZeroCalls              = 5
+)
+
+// ScaleToZeroOptions for AssertScaleToZeroBehavior.
+type ScaleToZeroOptions struct {
+	// Scale sets the number of replicas of the target Instance, e.g. via its Instance.Scale.
+	// Required.
+	Scale func(replicas int) error
+
+	// Replicas to restore after scaling to zero. Defaults to 1.
+	Replicas int
+
+	// Prompt is the maximum latency of a call while the target has no endpoints. Defaults to 1s.
+	Prompt time.Duration
+
+	// Timeout for the endpoints of the target to be removed from, and then restored to, the source
+	// sidecars. Defaults to 2m.
+	Timeout time.Duration
+}
+
+func (o *ScaleToZeroOptions) fillInDefaults() {
+	if o.Replicas <= 0 {
+		o.Replicas = 1
+	}
+	if o.Prompt <= 0 {
+		o.Prompt = defaultScaleToZeroPrompt
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultScaleToZeroTimeout
+	}
+}
+
+// AssertScaleToZeroBehavior verifies that calls from source to opts.Target fail cleanly while the
+// target is scaled to zero replicas, and that connectivity is restored after scaling back up.
+//
+// Once the source sidecars are aware that the target has no endpoints, every call must promptly
+// return a 503 with Envoy's "no healthy upstream" response, rather than hang. The target is always
+// scaled back to sopts.Replicas, even if the check fails, after which calls must succeed again.
+func AssertScaleToZeroBehavior(source Instance, opts CallOptions, sopts ScaleToZeroOptions) error {
+	if opts.Target == nil {
+		return errors.New("assertScaleToZeroBehavior: missing Target")
+	}
+	if sopts.Scale == nil {
+		return errors.New("assertScaleToZeroBehavior: missing Scale")
+	}
+	sopts.fillInDefaults()
+	if opts.Timeout <= 0 {
+		// Fail calls that hang, rather than waiting for the default timeout.
+		opts.Timeout = defaultScaleToZeroCallTimeout
+	}
+	opts.Count = scaleToZeroCalls
+	opts.Retry.Disabled = true
+
+	if err := sopts.Scale(0); err != nil {
+		return fmt.Errorf("failed scaling %s to zero: %v", opts.Target.Config().Service, err)
+	}
+
+	err := retry.UntilSuccess(func() error {
+		return checkNoHealthyUpstream(source, opts, sopts.Prompt)
+	}, retry.Timeout(sopts.Timeout), retry.Delay(time.Second))
+	if err != nil {
+		err = fmt.Errorf("calls to %s scaled to zero did not fail cleanly: %v", opts.Target.Config().Service, err)
+	}
+
+	if scaleErr := sopts.Scale(sopts.Replicas); scaleErr != nil {
+		return multierror.Append(err, fmt.Errorf("failed scaling %s to %d: %v",
+			opts.Target.Config().Service, sopts.Replicas, scaleErr)).ErrorOrNil()
+	}
+
+	okErr := retry.UntilSuccess(func() error {
+		return callOK(source, opts)
+	}, retry.Timeout(sopts.Timeout), retry.Delay(time.Second))
+	if okErr != nil {
+		err = multierror.Append(err, fmt.Errorf("connectivity to %s not restored after scaling to %d: %v",
+			opts.Target.Config().Service, sopts.Replicas, okErr))
+	}
+	return err
+}
+
+// AssertScaleToZeroBehaviorOrFail calls AssertScaleToZeroBehavior and fails t if an error occurs.
+func AssertScaleToZeroBehaviorOrFail(t testing.TB, source Instance, opts CallOptions, sopts ScaleToZeroOptions) {
+	if err := AssertScaleToZeroBehavior(source, opts, sopts); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkNoHealthyUpstream(source Instance, opts CallOptions, prompt time.Duration) error {
+	responses, err := source.Call(opts)
+	if err != nil {
+		return err
+	}
+	return responses.Check(func(i int, response *client.ParsedResponse) error {
+		if response.Code != strconv.Itoa(http.StatusServiceUnavailable) || response.Count(noHealthyUpstream) == 0 {
+			return fmt.Errorf("response[%d] expected a 503 with %q, received Status Code %s",
+				i, noHealthyUpstream, response.Code)
+		}
+		if response.Latency > prompt {
+			return fmt.Errorf("response[%d] took %v, expected at most %v", i, response.Latency, prompt)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,98 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	envoyAdmin "github.com/envoyproxy/go-control-plane/envoy/admin/v2alpha"
+)
+
+// ServiceEntryEndpoints returns the endpoints (as sorted, unique "address:port" strings) that the
+// sidecar has resolved for the given host, across all of the outbound clusters for the host (one per
+// port). For a ServiceEntry, these are the STATIC endpoints or the addresses resolved via DNS.
+func ServiceEntryEndpoints(sidecar Sidecar, host string) ([]string, error) {
+	clusters, err := sidecar.Clusters()
+	if err != nil {
+		return nil, err
+	}
+	return outboundEndpoints(clusters, host), nil
+}
+
+// CheckServiceEntryEndpoints verifies that the sidecar of every source workload has resolved
+// exactly the expected endpoints ("address:port") for the given ServiceEntry host. Resolution is
+// asynchronous, so callers may need to retry until it has completed.
+func CheckServiceEntryEndpoints(source Instance, host string, expected []string) error {
+	workloads, err := source.Workloads()
+	if err != nil {
+		return err
+	}
+
+	want := append([]string{}, expected...)
+	sort.Strings(want)
+	for _, w := range workloads {
+		if w.Sidecar() == nil {
+			return fmt.Errorf("checkServiceEntryEndpoints: source %s has no sidecar", source.Config().Service)
+		}
+		got, err := ServiceEntryEndpoints(w.Sidecar(), host)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(got, want) {
+			return fmt.Errorf("sidecar %s resolved endpoints %v for host %s, expected %v",
+				w.Sidecar().NodeID(), got, host, want)
+		}
+	}
+	return nil
+}
+
+// CheckServiceEntryEndpointsOrFail calls CheckServiceEntryEndpoints and fails t if an error occurs.
+func CheckServiceEntryEndpointsOrFail(t testing.TB, source Instance, host string, expected []string) {
+	if err := CheckServiceEntryEndpoints(source, host, expected); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// outboundEndpoints returns the sorted, unique endpoints of the outbound clusters for the host.
+// Outbound cluster names have the form "outbound|<port>|<subset>|<host>".
+func outboundEndpoints(clusters *envoyAdmin.Clusters, host string) []string {
+	endpoints := make(map[string]bool)
+	for _, c := range clusters.ClusterStatuses {
+		parts := strings.Split(c.Name, "|")
+		if len(parts) != 4 || parts[0] != "outbound" || parts[3] != host {
+			continue
+		}
+		for _, h := range c.HostStatuses {
+			addr := h.GetAddress().GetSocketAddress()
+			if addr == nil {
+				continue
+			}
+			endpoints[net.JoinHostPort(addr.Address, strconv.Itoa(int(addr.GetPortValue())))] = true
+		}
+	}
+
+	out := make([]string, 0, len(endpoints))
+	for e := range endpoints {
+		out = append(out, e)
+	}
+	sort.Strings(out)
+	return out
+}
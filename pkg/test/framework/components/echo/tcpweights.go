@@ -0,0 +1,106 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// tcpWeightsDefaultCount is the number of connections opened by CheckTCPWeights when no count is
+// specified.
+const tcpWeightsDefaultCount = 100
+
+// CheckTCPWeights opens opts.Count connections (100 by default) from source to a TCP port of
+// opts.Target and verifies that they are split across the subsets by the given weights (e.g. of a
+// weighted TCP route), within tolerance percentage points. Since a TCP route selects the subset once
+// per connection, each call is made on a new connection, and is attributed to the subset via the
+// version reported by the server over that connection. Weights are keyed by version (i.e. the
+// "version" label selected by the subset) and should add up to 100. An error is returned for ports
+// of other protocols, as HTTP routes select the subset per request instead.
+func CheckTCPWeights(source Instance, opts CallOptions, weights map[string]int, tolerance float64) error {
+	if opts.Target == nil {
+		return errors.New("checkTCPWeights: missing Target")
+	}
+	if len(weights) == 0 {
+		return errors.New("checkTCPWeights: missing weights")
+	}
+	port := targetPort(opts)
+	if port == nil {
+		return errors.New("checkTCPWeights: PortName or Port must match a Target port")
+	}
+	if port.Protocol != model.ProtocolTCP {
+		return fmt.Errorf("checkTCPWeights: port %s of %s is %s, not TCP", port.Name, opts.Target.Config().Service,
+			port.Protocol)
+	}
+	if opts.Count <= 0 {
+		opts.Count = tcpWeightsDefaultCount
+	}
+
+	// Prevent the client from reusing connections, which would all be routed to the same subset.
+	headers := make(http.Header)
+	for k, v := range opts.Headers {
+		headers[k] = v
+	}
+	headers.Set("Connection", "close")
+	opts.Headers = headers
+
+	responses, err := source.Call(opts)
+	if err != nil {
+		return err
+	}
+	if err := responses.CheckOK(); err != nil {
+		return err
+	}
+
+	connections := make(map[string]int)
+	for _, r := range responses {
+		connections[r.Version]++
+	}
+	for version := range connections {
+		if _, ok := weights[version]; !ok {
+			return fmt.Errorf("connections from %s to %s routed to unexpected subset version %q: %v",
+				source.Config().Service, opts.Target.Config().Service, version, connections)
+		}
+	}
+	// Check the versions in order, so that the same version is reported on failure.
+	versions := make([]string, 0, len(weights))
+	for version := range weights {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	for _, version := range versions {
+		weight := weights[version]
+		observed := 100 * float64(connections[version]) / float64(len(responses))
+		if math.Abs(observed-float64(weight)) > tolerance {
+			return fmt.Errorf("%.1f%% of connections from %s to %s routed to version %s, expected %d%% (tolerance %.1f): %v",
+				observed, source.Config().Service, opts.Target.Config().Service, version, weight, tolerance, connections)
+		}
+	}
+	return nil
+}
+
+// CheckTCPWeightsOrFail calls CheckTCPWeights and fails t if an error occurs.
+func CheckTCPWeightsOrFail(t testing.TB, source Instance, opts CallOptions, weights map[string]int, tolerance float64) {
+	if err := CheckTCPWeights(source, opts, weights, tolerance); err != nil {
+		t.Fatal(err)
+	}
+}
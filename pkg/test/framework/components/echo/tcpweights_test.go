@@ -0,0 +1,85 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"strings"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/test/echo/client"
+)
+
+// tcpWeightsSource routes its calls to the versions in turn, as a weighted TCP route would over
+// new connections.
+type tcpWeightsSource struct {
+	Instance
+	versions []string
+}
+
+func (s *tcpWeightsSource) Config() Config {
+	return Config{Service: "a"}
+}
+
+func (s *tcpWeightsSource) Call(opts CallOptions) (client.ParsedResponses, error) {
+	if opts.Headers.Get("Connection") != "close" {
+		return client.ParsedResponses{{Code: "400"}}, nil
+	}
+	responses := make(client.ParsedResponses, 0, opts.Count)
+	for i := 0; i < opts.Count; i++ {
+		responses = append(responses, &client.ParsedResponse{Code: "200", Version: s.versions[i%len(s.versions)]})
+	}
+	return responses, nil
+}
+
+func TestCheckTCPWeights(t *testing.T) {
+	target := &fakeInstance{cfg: Config{
+		Service: "b",
+		Ports: []Port{
+			{Name: "tcp", Protocol: model.ProtocolTCP, ServicePort: 90},
+			{Name: "http", Protocol: model.ProtocolHTTP, ServicePort: 80},
+		},
+	}}
+	// 75% of the connections are routed to v1.
+	source := &tcpWeightsSource{versions: []string{"v1", "v1", "v1", "v2"}}
+
+	cases := []struct {
+		name     string
+		portName string
+		weights  map[string]int
+		err      string
+	}{
+		{name: "matching weights", portName: "tcp", weights: map[string]int{"v1": 75, "v2": 25}},
+		{name: "within tolerance", portName: "tcp", weights: map[string]int{"v1": 80, "v2": 20}},
+		{name: "mismatched weights", portName: "tcp", weights: map[string]int{"v1": 50, "v2": 50}, err: "75.0% of connections"},
+		{name: "unexpected subset", portName: "tcp", weights: map[string]int{"v1": 100}, err: `unexpected subset version "v2"`},
+		{name: "http port", portName: "http", weights: map[string]int{"v1": 75, "v2": 25}, err: "port http of b is HTTP, not TCP"},
+		{name: "unknown port", portName: "grpc", weights: map[string]int{"v1": 75, "v2": 25}, err: "must match a Target port"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := CheckTCPWeights(source, CallOptions{Target: target, PortName: c.portName}, c.weights, 5)
+			if c.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("expected error containing %q, got: %v", c.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	prom "github.com/prometheus/common/model"
+
+	"istio.io/istio/pkg/test/framework/components/prometheus"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const (
+	defaultTelemetryTimeout = 2 * time.Minute
+	defaultTelemetryDelay   = 5 * time.Second
+)
+
+// TelemetryOptions defines how the metrics backend is queried by CallAndWaitForMetrics.
+type TelemetryOptions struct {
+	// Prometheus instance used as the metrics backend. Required.
+	Prometheus prometheus.Instance
+
+	// Query evaluating to the counter that is expected to increase with every call. All samples in
+	// the result are summed. If not provided, the istio_requests_total requests received by the target
+	// service are used, as reported by the destination proxy.
+	Query string
+
+	// Tolerance is the maximum allowed difference between the observed delta and the number of calls.
+	Tolerance int
+
+	// Timeout for the metrics to reflect the calls. If not provided, 2 minutes is used.
+	Timeout time.Duration
+
+	// Delay between queries. If not provided, 5 seconds is used.
+	Delay time.Duration
+}
+
+// CallAndWaitForMetrics makes opts.Count calls from source and waits until the counter returned
+// by the telemetry query has increased by the same amount (within the configured tolerance). The
+// observed delta is returned, along with an error if it did not match before the timeout.
+func CallAndWaitForMetrics(source Instance, opts CallOptions, topts TelemetryOptions) (int, error) {
+	if opts.Target == nil {
+		return 0, errors.New("callAndWaitForMetrics: missing Target")
+	}
+	if topts.Prometheus == nil {
+		return 0, errors.New("callAndWaitForMetrics: missing Prometheus")
+	}
+	if opts.Count <= 0 {
+		opts.Count = 1
+	}
+	if topts.Query == "" {
+		topts.Query = requestsTotalQuery(opts.Target.Config())
+	}
+	if topts.Timeout <= 0 {
+		topts.Timeout = defaultTelemetryTimeout
+	}
+	if topts.Delay <= 0 {
+		topts.Delay = defaultTelemetryDelay
+	}
+
+	initial, err := queryCounter(topts.Prometheus, topts.Query)
+	if err != nil {
+		return 0, err
+	}
+
+	// Each attempt would be counted.
+	opts.Retry.Disabled = true
+	if _, err := source.Call(opts); err != nil {
+		return 0, err
+	}
+
+	delta := 0
+	err = retry.UntilSuccess(func() error {
+		current, err := queryCounter(topts.Prometheus, topts.Query)
+		if err != nil {
+			return err
+		}
+		delta = int(current - initial)
+		if diff := delta - opts.Count; diff < -topts.Tolerance || diff > topts.Tolerance {
+			return fmt.Errorf("metrics for query %q increased by %d, expected %d (tolerance %d)",
+				topts.Query, delta, opts.Count, topts.Tolerance)
+		}
+		return nil
+	}, retry.Timeout(topts.Timeout), retry.Delay(topts.Delay))
+	return delta, err
+}
+
+// CallAndWaitForMetricsOrFail calls CallAndWaitForMetrics and fails t if an error occurs.
+func CallAndWaitForMetricsOrFail(t testing.TB, source Instance, opts CallOptions, topts TelemetryOptions) int {
+	delta, err := CallAndWaitForMetrics(source, opts, topts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return delta
+}
+
+// CheckMetricDimensions makes opts.Count calls from source, and verifies that the istio_requests_total
+// requests received by the target service are reported with the expected labels (e.g.
+// "response_code": "200", "request_protocol": "http", "source_workload": "a-v1"), by waiting for the
+// series with those labels to increase accordingly (see CallAndWaitForMetrics). Unless a "reporter"
+// label is expected, only the requests reported by the destination proxy are counted. The Query of
+// topts is ignored. On failure, the series found for the target service are included in the error,
+// to help identify the mismatched dimensions.
+func CheckMetricDimensions(source Instance, opts CallOptions, topts TelemetryOptions, expectedLabels map[string]string) error {
+	if opts.Target == nil {
+		return errors.New("checkMetricDimensions: missing Target")
+	}
+	if topts.Prometheus == nil {
+		return errors.New("checkMetricDimensions: missing Prometheus")
+	}
+	if len(expectedLabels) == 0 {
+		return errors.New("checkMetricDimensions: missing expectedLabels")
+	}
+
+	cfg := opts.Target.Config()
+	targetSelector := fmt.Sprintf(`destination_service_name=%q,destination_service_namespace=%q`,
+		cfg.Service, cfg.Namespace.Name())
+	topts.Query = fmt.Sprintf("sum(istio_requests_total{%s})", dimensionsSelector(targetSelector, expectedLabels))
+
+	if _, err := CallAndWaitForMetrics(source, opts, topts); err != nil {
+		series, qerr := querySeries(topts.Prometheus, fmt.Sprintf("istio_requests_total{%s}", targetSelector))
+		if qerr != nil {
+			return multierror.Append(err, qerr)
+		}
+		return fmt.Errorf("%v\nseries for service %s:\n%s", err, cfg.Service, strings.Join(series, "\n"))
+	}
+	return nil
+}
+
+// CheckMetricDimensionsOrFail calls CheckMetricDimensions and fails t if an error occurs.
+func CheckMetricDimensionsOrFail(t testing.TB, source Instance, opts CallOptions, topts TelemetryOptions,
+	expectedLabels map[string]string) {
+	if err := CheckMetricDimensions(source, opts, topts, expectedLabels); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// dimensionsSelector returns the label selector matching targetSelector and the expected labels, in
+// sorted order. Each request is reported by both the source and the destination proxy, so unless a
+// reporter is expected, only the latter is selected.
+func dimensionsSelector(targetSelector string, expectedLabels map[string]string) string {
+	names := make([]string, 0, len(expectedLabels))
+	for name := range expectedLabels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	selector := targetSelector
+	if _, ok := expectedLabels["reporter"]; !ok {
+		selector += `,reporter="destination"`
+	}
+	for _, name := range names {
+		selector += fmt.Sprintf(",%s=%q", name, expectedLabels[name])
+	}
+	return selector
+}
+
+// requestsTotalQuery returns the query for the istio_requests_total requests received by the target
+// service. Each request is reported by both the source and the destination proxy, so only the
+// latter is counted.
+func requestsTotalQuery(target Config) string {
+	return fmt.Sprintf(`sum(istio_requests_total{reporter="destination",destination_service_name=%q,destination_service_namespace=%q})`,
+		target.Service, target.Namespace.Name())
+}
+
+// querySeries runs the query, returning the labels and value of each sample.
+func querySeries(p prometheus.Instance, query string) ([]string, error) {
+	v, err := p.API().Query(context.Background(), query, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error querying Prometheus: %v", err)
+	}
+
+	vector, ok := v.(prom.Vector)
+	if !ok {
+		return nil, fmt.Errorf("unhandled value type %v for query %q", v.Type(), query)
+	}
+	out := make([]string, 0, len(vector))
+	for _, sample := range vector {
+		out = append(out, fmt.Sprintf("%s = %v", sample.Metric, sample.Value))
+	}
+	return out, nil
+}
+
+// queryCounter runs the query, returning the sum of all samples. A missing counter is treated as 0.
+func queryCounter(p prometheus.Instance, query string) (float64, error) {
+	v, err := p.API().Query(context.Background(), query, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("error querying Prometheus: %v", err)
+	}
+
+	switch value := v.(type) {
+	case *prom.Scalar:
+		return float64(value.Value), nil
+	case prom.Vector:
+		total := 0.0
+		for _, sample := range value {
+			total += float64(sample.Value)
+		}
+		return total, nil
+	default:
+		return 0, fmt.Errorf("unhandled value type %v for query %q", v.Type(), query)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/api/prometheus/v1"
+	prom "github.com/prometheus/common/model"
+
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/framework/components/prometheus"
+)
+
+type fakeNamespace string
+
+func (n fakeNamespace) Name() string {
+	return string(n)
+}
+
+// fakeInstance is the source or target of calls. Only Config and Call are implemented.
+type fakeInstance struct {
+	Instance
+	cfg Config
+
+	// calls made by this instance are recorded as requests by the metrics backend.
+	metrics *fakeMetrics
+}
+
+func (i *fakeInstance) Config() Config {
+	return i.cfg
+}
+
+func (i *fakeInstance) Call(opts CallOptions) (client.ParsedResponses, error) {
+	i.metrics.requests += opts.Count
+	return nil, nil
+}
+
+// fakeMetrics is a Prometheus backend for the istio_requests_total of a single service, for which
+// each request is reported by both the source and the destination proxy. Only queries are supported.
+type fakeMetrics struct {
+	prometheus.Instance
+
+	requests int
+	queries  []string
+}
+
+func (m *fakeMetrics) API() v1.API {
+	return fakeMetricsAPI{fakeMetrics: m}
+}
+
+type fakeMetricsAPI struct {
+	v1.API
+	*fakeMetrics
+}
+
+func (m fakeMetricsAPI) Query(_ context.Context, query string, _ time.Time) (prom.Value, error) {
+	m.queries = append(m.queries, query)
+	value := 2 * m.requests
+	if strings.Contains(query, `reporter="destination"`) || strings.Contains(query, `reporter="source"`) {
+		value = m.requests
+	}
+	return prom.Vector{{Metric: prom.Metric{"reporter": "destination"}, Value: prom.SampleValue(value)}}, nil
+}
+
+func newTelemetryTest() (*fakeInstance, CallOptions, TelemetryOptions, *fakeMetrics) {
+	metrics := &fakeMetrics{}
+	source := &fakeInstance{cfg: Config{Service: "a", Namespace: fakeNamespace("ns")}, metrics: metrics}
+	target := &fakeInstance{cfg: Config{Service: "b", Namespace: fakeNamespace("ns")}}
+	opts := CallOptions{Target: target, Count: 5}
+	topts := TelemetryOptions{Prometheus: metrics, Timeout: 50 * time.Millisecond, Delay: time.Millisecond}
+	return source, opts, topts, metrics
+}
+
+func TestCallAndWaitForMetrics(t *testing.T) {
+	source, opts, topts, metrics := newTelemetryTest()
+
+	// The requests are counted once, as reported by the destination.
+	delta, err := CallAndWaitForMetrics(source, opts, topts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delta != 5 {
+		t.Fatalf("expected a delta of 5, got %d", delta)
+	}
+	if !strings.Contains(metrics.queries[0], `destination_service_name="b",destination_service_namespace="ns"`) {
+		t.Fatalf("unexpected query: %s", metrics.queries[0])
+	}
+
+	// The delta of a custom query must match the calls.
+	topts.Query = "sum(istio_requests_total)"
+	if _, err := CallAndWaitForMetrics(source, opts, topts); err == nil {
+		t.Fatal("expected error for a query counting each request twice")
+	}
+}
+
+func TestCheckMetricDimensions(t *testing.T) {
+	cases := []struct {
+		name     string
+		labels   map[string]string
+		selector string
+	}{
+		{
+			name:     "destination by default",
+			labels:   map[string]string{"response_code": "200", "request_protocol": "http"},
+			selector: `reporter="destination",request_protocol="http",response_code="200"`,
+		},
+		{
+			name:     "expected reporter",
+			labels:   map[string]string{"response_code": "200", "reporter": "source"},
+			selector: `reporter="source",response_code="200"`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			source, opts, topts, metrics := newTelemetryTest()
+			if err := CheckMetricDimensions(source, opts, topts, c.labels); err != nil {
+				t.Fatal(err)
+			}
+			expected := `sum(istio_requests_total{destination_service_name="b",destination_service_namespace="ns",` + c.selector + "})"
+			if metrics.queries[0] != expected {
+				t.Fatalf("expected query %s, got %s", expected, metrics.queries[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pkg/test/framework/components/pilot"
+)
+
+// ExactVersion is the maxSkew for AssertVersionSkew requiring the versions to match exactly.
+const ExactVersion = -1
+
+var minorVersionRegex = regexp.MustCompile(`^(\d+)\.(\d+)`)
+
+// AssertVersionSkew verifies that the proxy of every workload of the given instances runs a version
+// of Istio compatible with that of the control plane. The versions are compatible if they share the
+// major version and their minor versions differ by at most maxSkew (e.g. 1.3.x and 1.4.x for a
+// maxSkew of 1). If maxSkew is ExactVersion, the versions must be identical. The observed versions
+// are reported on failure.
+func AssertVersionSkew(controlPlane pilot.Instance, maxSkew int, instances ...Instance) error {
+	cpVersion, err := controlPlane.Version()
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	for _, inst := range instances {
+		workloads, err := inst.Workloads()
+		if err != nil {
+			return err
+		}
+		for _, w := range workloads {
+			if w.Sidecar() == nil {
+				continue
+			}
+			proxyVersion, err := w.Sidecar().ProxyVersion()
+			if err != nil {
+				return err
+			}
+			if err := checkVersionSkew(proxyVersion, cpVersion, maxSkew); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("proxy %s of %s: %v", w.Sidecar().NodeID(), inst.Config().Service, err))
+			}
+		}
+	}
+	return errs
+}
+
+// AssertVersionSkewOrFail calls AssertVersionSkew and fails t if an error occurs.
+func AssertVersionSkewOrFail(t testing.TB, controlPlane pilot.Instance, maxSkew int, instances ...Instance) {
+	if err := AssertVersionSkew(controlPlane, maxSkew, instances...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkVersionSkew(proxyVersion, cpVersion string, maxSkew int) error {
+	if proxyVersion == cpVersion {
+		return nil
+	}
+	if maxSkew == ExactVersion {
+		return fmt.Errorf("proxy version %q does not match control plane version %q", proxyVersion, cpVersion)
+	}
+
+	proxyMajor, proxyMinor, err := parseMinorVersion(proxyVersion)
+	if err != nil {
+		return err
+	}
+	cpMajor, cpMinor, err := parseMinorVersion(cpVersion)
+	if err != nil {
+		return err
+	}
+
+	skew := proxyMinor - cpMinor
+	if skew < 0 {
+		skew = -skew
+	}
+	if proxyMajor != cpMajor || skew > maxSkew {
+		return fmt.Errorf("proxy version %q is incompatible with control plane version %q (max skew: %d minor versions)",
+			proxyVersion, cpVersion, maxSkew)
+	}
+	return nil
+}
+
+// parseMinorVersion returns the major and minor version of a version string (e.g. "1.3.0-beta.1").
+func parseMinorVersion(version string) (int, int, error) {
+	match := minorVersionRegex.FindStringSubmatch(version)
+	if match == nil {
+		return 0, 0, fmt.Errorf("unable to parse version %q", version)
+	}
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+	return major, minor, nil
+}
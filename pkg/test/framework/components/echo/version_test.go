@@ -0,0 +1,49 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"testing"
+)
+
+func TestCheckVersionSkew(t *testing.T) {
+	cases := []struct {
+		name    string
+		proxy   string
+		cp      string
+		maxSkew int
+		valid   bool
+	}{
+		{name: "identical", proxy: "1.3.0", cp: "1.3.0", maxSkew: ExactVersion, valid: true},
+		{name: "exact patch mismatch", proxy: "1.3.1", cp: "1.3.0", maxSkew: ExactVersion, valid: false},
+		{name: "same minor", proxy: "1.3.1", cp: "1.3.0", maxSkew: 0, valid: true},
+		{name: "minor skew allowed", proxy: "1.3.0", cp: "1.4.0-beta.1", maxSkew: 1, valid: true},
+		{name: "minor skew too large", proxy: "1.2.5", cp: "1.4.0", maxSkew: 1, valid: false},
+		{name: "major mismatch", proxy: "2.0.0", cp: "1.9.0", maxSkew: 10, valid: false},
+		{name: "unparseable", proxy: "unknown", cp: "1.3.0", maxSkew: 1, valid: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := checkVersionSkew(c.proxy, c.cp, c.maxSkew)
+			if c.valid && err != nil {
+				t.Fatalf("expected compatible, got: %v", err)
+			}
+			if !c.valid && err == nil {
+				t.Fatal("expected incompatible")
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const (
+	// warmingClustersGauge is the Envoy gauge of the clusters that are warming, i.e. waiting for
+	// their endpoints (EDS) before being activated.
+	warmingClustersGauge = "cluster_manager.warming_clusters"
+
+	warmupCallInterval = 200 * time.Millisecond
+	warmupTimeout      = time.Minute
+	// warmupSettle is the time for which calls continue after the clusters have warmed.
+	warmupSettle = 2 * time.Second
+)
+
+// AssertNoWarmupFailures verifies that no calls from source fail while the sidecars of source
+// receive and warm the clusters of a config change, which is made by calling during.
+//
+// Calls are made in the background from the start of the config change. The config push is
+// detected by the sidecars' CDS update counter, and warmup by their warming clusters gauge
+// returning to zero. Calls continue briefly after every sidecar has warmed, and an error is returned
+// if any of them failed, along with the time at which it failed relative to the config change.
+func AssertNoWarmupFailures(source Instance, opts CallOptions, during func() error) error {
+	if during == nil {
+		return errors.New("assertNoWarmupFailures: missing during")
+	}
+
+	workloads, err := source.Workloads()
+	if err != nil {
+		return err
+	}
+	sidecars := make([]Sidecar, 0, len(workloads))
+	for _, w := range workloads {
+		if w.Sidecar() == nil {
+			return fmt.Errorf("assertNoWarmupFailures: source %s has no sidecar", source.Config().Service)
+		}
+		sidecars = append(sidecars, w.Sidecar())
+	}
+
+	// Make sure calls work before changing anything.
+	if err := callOK(source, opts); err != nil {
+		return fmt.Errorf("call failed before the config change: %v", err)
+	}
+
+	updates := make([]int64, len(sidecars))
+	for i, s := range sidecars {
+		stats, err := s.Stats()
+		if err != nil {
+			return err
+		}
+		updates[i] = stats[configUpdateCounter]
+	}
+
+	// Make calls in the background until stopped, recording the time of any failures relative to
+	// the start of the config change.
+	var (
+		callErrs error
+		mutex    sync.Mutex
+		wg       sync.WaitGroup
+	)
+	start := time.Now()
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(warmupCallInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := callOK(source, opts); err != nil {
+					mutex.Lock()
+					callErrs = multierror.Append(callErrs, fmt.Errorf("call at %v: %v",
+						time.Since(start).Round(time.Millisecond), err))
+					mutex.Unlock()
+				}
+			}
+		}
+	}()
+	stopCalls := func() error {
+		close(stop)
+		wg.Wait()
+		if callErrs != nil {
+			return fmt.Errorf("calls failed during the config change (made at 0s): %v", callErrs)
+		}
+		return nil
+	}
+
+	if err := during(); err != nil {
+		_ = stopCalls()
+		return fmt.Errorf("failed changing the config: %v", err)
+	}
+
+	warmupErr := retry.UntilSuccess(func() error {
+		for i, s := range sidecars {
+			stats, err := s.Stats()
+			if err != nil {
+				return err
+			}
+			if stats[configUpdateCounter] <= updates[i] {
+				return fmt.Errorf("sidecar %s has not received the config change: %s=%d",
+					s.NodeID(), configUpdateCounter, stats[configUpdateCounter])
+			}
+			if stats[warmingClustersGauge] > 0 {
+				return fmt.Errorf("sidecar %s has not warmed the clusters: %s=%d",
+					s.NodeID(), warmingClustersGauge, stats[warmingClustersGauge])
+			}
+		}
+		return nil
+	}, retry.Timeout(warmupTimeout), retry.Delay(warmupCallInterval))
+	if warmupErr == nil {
+		time.Sleep(warmupSettle)
+	}
+
+	return multierror.Append(stopCalls(), warmupErr).ErrorOrNil()
+}
+
+// AssertNoWarmupFailuresOrFail calls AssertNoWarmupFailures and fails t if an error occurs.
+func AssertNoWarmupFailuresOrFail(t testing.TB, source Instance, opts CallOptions, during func() error) {
+	if err := AssertNoWarmupFailures(source, opts, during); err != nil {
+		t.Fatal(err)
+	}
+}
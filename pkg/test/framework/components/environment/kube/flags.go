@@ -26,7 +26,8 @@ import (
 var (
 	// Settings we will collect from the command-line.
 	settingsFromCommandLine = &Settings{
-		KubeConfig: env.ISTIO_TEST_KUBE_CONFIG.Value(),
+		KubeConfig:              env.ISTIO_TEST_KUBE_CONFIG.Value(),
+		EndpointWaitConcurrency: 10,
 	}
 )
 
@@ -71,4 +72,7 @@ func init() {
 		"The path to the kube config file for cluster environments")
 	flag.BoolVar(&settingsFromCommandLine.Minikube, "istio.test.kube.minikube", settingsFromCommandLine.Minikube,
 		"Indicates that the target environment is Minikube. Used by Ingress component to obtain the right IP address..")
+	flag.IntVar(&settingsFromCommandLine.EndpointWaitConcurrency, "istio.test.kube.endpointWaitConcurrency",
+		settingsFromCommandLine.EndpointWaitConcurrency,
+		"The maximum number of services whose endpoints are waited for concurrently, or <= 0 for no limit.")
 }
@@ -26,6 +26,11 @@ type Settings struct {
 	// Indicates that the Ingress Gateway is not available. This typically happens in Minikube. The Ingress
 	// component will fall back to node-port in this case.
 	Minikube bool
+
+	// EndpointWaitConcurrency is the maximum number of services whose endpoints are waited for
+	// concurrently (e.g. when echo instances become ready), to avoid throttling by the API server.
+	// If <= 0, there is no limit.
+	EndpointWaitConcurrency int
 }
 
 func (s *Settings) clone() *Settings {
@@ -39,6 +44,7 @@ func (s *Settings) String() string {
 
 	result += fmt.Sprintf("KubeConfig:      %s\n", s.KubeConfig)
 	result += fmt.Sprintf("MiniKubeIngress: %v\n", s.Minikube)
+	result += fmt.Sprintf("EndpointWaitConcurrency: %d\n", s.EndpointWaitConcurrency)
 
 	return result
 }
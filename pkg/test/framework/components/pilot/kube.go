@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"strings"
 
 	"github.com/hashicorp/go-multierror"
 
@@ -25,11 +26,15 @@ import (
 	"istio.io/istio/pkg/test/framework/components/istio"
 	"istio.io/istio/pkg/test/framework/resource"
 	testKube "istio.io/istio/pkg/test/kube"
+
+	kubeApiCore "k8s.io/api/core/v1"
 )
 
 const (
 	pilotService = "istio-pilot"
 	grpcPortName = "grpc-xds"
+
+	discoveryContainer = "discovery"
 )
 
 var (
@@ -56,6 +61,8 @@ func newKube(ctx resource.Context, _ Config) (Instance, error) {
 		return nil, err
 	}
 	pod := pods[0]
+	c.env = env
+	c.pod = pod
 
 	port, err := getGrpcPort(env, ns)
 	if err != nil {
@@ -97,12 +104,23 @@ type kubeComponent struct {
 	*client
 
 	forwarder testKube.PortForwarder
+	env       *kube.Environment
+	pod       kubeApiCore.Pod
 }
 
 func (c *kubeComponent) ID() resource.ID {
 	return c.id
 }
 
+// Version returns the version reported by the discovery container of the Pilot pod.
+func (c *kubeComponent) Version() (string, error) {
+	out, err := c.env.Exec(c.pod.Namespace, c.pod.Name, discoveryContainer, "pilot-discovery version -s")
+	if err != nil {
+		return "", fmt.Errorf("failed getting version of pilot pod %s/%s: %v", c.pod.Namespace, c.pod.Name, err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
 //func (c *kubeComponent) Start(ctx resource.Context) (err error) {
 //
 //
@@ -29,6 +29,7 @@ import (
 	"istio.io/istio/pkg/test/framework/components/environment/native"
 	"istio.io/istio/pkg/test/framework/resource"
 	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/version"
 )
 
 var _ Instance = &nativeComponent{}
@@ -126,6 +127,11 @@ func (c *nativeComponent) ID() resource.ID {
 	return c.id
 }
 
+// Version returns the version of this build, since the native Pilot runs in-process.
+func (c *nativeComponent) Version() (string, error) {
+	return version.Info.Version, nil
+}
+
 func (c *nativeComponent) Close() (err error) {
 	if c.client != nil {
 		scopes.Framework.Debugf("%s closing client", c.id)
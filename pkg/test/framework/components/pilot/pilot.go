@@ -51,6 +51,9 @@ type Instance interface {
 	StartDiscoveryOrFail(t testing.TB, req *xdsapi.DiscoveryRequest)
 	WatchDiscovery(duration time.Duration, accept func(*xdsapi.DiscoveryResponse) (bool, error)) error
 	WatchDiscoveryOrFail(t testing.TB, duration time.Duration, accept func(*xdsapi.DiscoveryResponse) (bool, error))
+
+	// Version of the Istio build running as the control plane (e.g. "1.3.0").
+	Version() (string, error)
 }
 
 // Structured config for the Pilot component
@@ -15,6 +15,7 @@
 package kube
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -25,12 +26,15 @@ import (
 	"istio.io/istio/pkg/test/scopes"
 	"istio.io/istio/pkg/test/util/retry"
 
+	kubeApiApps "k8s.io/api/apps/v1"
 	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiStorage "k8s.io/api/storage/v1"
 	kubeApiExt "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	kubeExtClient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	"k8s.io/apimachinery/pkg/api/errors"
 	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
 	kubeClient "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	kubeClientCore "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -40,6 +44,10 @@ import (
 
 const (
 	workDirPrefix = "istio-kube-accessor-"
+
+	// restartedAtAnnotation is set on the pod template to restart a deployment, as done by
+	// `kubectl rollout restart`.
+	restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
 )
 
 var (
@@ -132,6 +140,26 @@ func (a *Accessor) DeletePod(namespace, name string) error {
 	return a.set.CoreV1().Pods(namespace).Delete(name, &kubeApiMeta.DeleteOptions{})
 }
 
+// SetPodLabel sets the label with the given key and value on the pod, returning the updated pod.
+func (a *Accessor) SetPodLabel(namespace, name, key, value string) (kubeApiCore.Pod, error) {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]string{
+				key: value,
+			},
+		},
+	})
+	if err != nil {
+		return kubeApiCore.Pod{}, err
+	}
+
+	v, err := a.set.CoreV1().Pods(namespace).Patch(name, types.MergePatchType, patch)
+	if err != nil {
+		return kubeApiCore.Pod{}, err
+	}
+	return *v, nil
+}
+
 // FindPodBySelectors returns the first matching pod, given a namespace and a set of selectors.
 func (a *Accessor) FindPodBySelectors(namespace string, selectors ...string) (kubeApiCore.Pod, error) {
 	list, err := a.GetPods(namespace, selectors...)
@@ -243,6 +271,86 @@ func (a *Accessor) WaitUntilDeploymentIsReady(ns string, name string, opts ...re
 	return err
 }
 
+// RestartDeployment triggers a rollout restart of the deployment with the name/namespace, replacing
+// all of its pods.
+func (a *Accessor) RestartDeployment(ns string, name string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{
+						restartedAtAnnotation: time.Now().Format(time.RFC3339Nano),
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = a.set.AppsV1().Deployments(ns).Patch(name, types.StrategicMergePatchType, patch)
+	return err
+}
+
+// ScaleDeployment sets the number of replicas of the deployment with the name/namespace.
+func (a *Accessor) ScaleDeployment(ns string, name string, replicas int) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = a.set.AppsV1().Deployments(ns).Patch(name, types.StrategicMergePatchType, patch)
+	return err
+}
+
+// WaitUntilDeploymentIsRolledOut waits until all replicas of the deployment with the name/namespace
+// are of the latest revision and available, and no replicas of previous revisions remain.
+func (a *Accessor) WaitUntilDeploymentIsRolledOut(ns string, name string, opts ...retry.Option) error {
+	_, err := retry.Do(func() (interface{}, bool, error) {
+		deployment, err := a.set.AppsV1().Deployments(ns).Get(name, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return nil, true, err
+		}
+
+		replicas := int32(1)
+		if deployment.Spec.Replicas != nil {
+			replicas = *deployment.Spec.Replicas
+		}
+		status := deployment.Status
+		rolledOut := status.ObservedGeneration >= deployment.Generation &&
+			status.UpdatedReplicas == replicas &&
+			status.Replicas == replicas &&
+			status.AvailableReplicas == replicas
+
+		return nil, rolledOut, nil
+	}, newRetryOptions(opts...)...)
+
+	return err
+}
+
+// GetDeployments returns the deployments in the given namespace.
+func (a *Accessor) GetDeployments(ns string) ([]kubeApiApps.Deployment, error) {
+	list, err := a.set.AppsV1().Deployments(ns).List(kubeApiMeta.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// GetStatefulSets returns the stateful sets in the given namespace.
+func (a *Accessor) GetStatefulSets(ns string) ([]kubeApiApps.StatefulSet, error) {
+	list, err := a.set.AppsV1().StatefulSets(ns).List(kubeApiMeta.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
 // WaitUntilDaemonSetIsReady waits until the deployment with the name/namespace is in ready state.
 func (a *Accessor) WaitUntilDaemonSetIsReady(ns string, name string, opts ...retry.Option) error {
 	_, err := retry.Do(func() (interface{}, bool, error) {
@@ -348,6 +456,21 @@ func (a *Accessor) GetSecret(ns string) kubeClientCore.SecretInterface {
 	return a.set.CoreV1().Secrets(ns)
 }
 
+// GetConfigMap returns the config map with the given namespace and name.
+func (a *Accessor) GetConfigMap(ns, name string) (*kubeApiCore.ConfigMap, error) {
+	return a.set.CoreV1().ConfigMaps(ns).Get(name, kubeApiMeta.GetOptions{})
+}
+
+// GetStorageClass returns the storage class with the given name.
+func (a *Accessor) GetStorageClass(name string) (*kubeApiStorage.StorageClass, error) {
+	return a.set.StorageV1().StorageClasses().Get(name, kubeApiMeta.GetOptions{})
+}
+
+// DeletePersistentVolumeClaim deletes the persistent volume claim with the given namespace and name.
+func (a *Accessor) DeletePersistentVolumeClaim(ns, name string) error {
+	return a.set.CoreV1().PersistentVolumeClaims(ns).Delete(name, deleteOptionsForeground())
+}
+
 // GetEndpoints returns the endpoints for the given service.
 func (a *Accessor) GetEndpoints(ns, service string, options kubeApiMeta.GetOptions) (*kubeApiCore.Endpoints, error) {
 	return a.set.CoreV1().Endpoints(ns).Get(service, options)
@@ -466,6 +589,12 @@ func (a *Accessor) Exec(namespace, pod, container, command string) (string, erro
 	return a.ctl.exec(namespace, pod, container, command)
 }
 
+// ExecArgs executes the provided command (without a shell, so arguments may contain spaces) on the
+// specified pod/container, returning stdout and stderr separately.
+func (a *Accessor) ExecArgs(namespace, pod, container string, command []string) (stdout, stderr string, err error) {
+	return a.ctl.execArgs(namespace, pod, container, command)
+}
+
 // CheckPodReady returns nil if the given pod and all of its containers are ready.
 func CheckPodReady(pod *kubeApiCore.Pod) error {
 	switch pod.Status.Phase {
@@ -15,9 +15,11 @@
 package kube
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -145,6 +147,27 @@ func (c *kubectl) exec(namespace, pod, container, command string) (string, error
 		pod, namespaceArg(namespace), containerArg(container), c.configArg(), command)
 }
 
+// execArgs executes the given command (without a shell) on the specified pod/container, returning
+// the stdout and stderr streams separately.
+func (c *kubectl) execArgs(namespace, pod, container string, command []string) (string, string, error) {
+	args := []string{"exec", pod, "-n", namespace}
+	if container != "" {
+		args = append(args, "-c", container)
+	}
+	if c.kubeConfig != "" {
+		args = append(args, configArg(c.kubeConfig))
+	}
+	args = append(args, "--")
+	args = append(args, command...)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("kubectl", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stdout.String(), stderr.String(), err
+}
+
 func (c *kubectl) configArg() string {
 	return configArg(c.kubeConfig)
 }
@@ -129,6 +129,14 @@ func (h *pilotTestHandler) ForwardEcho(ctx context.Context, in *echopb.ForwardEc
 	return nil, fmt.Errorf("unsupported operation")
 }
 
+func (h *pilotTestHandler) EchoStream(echopb.EchoTestService_EchoStreamServer) error {
+	return fmt.Errorf("unsupported operation")
+}
+
+func (h *pilotTestHandler) RequestCount(ctx context.Context, in *echopb.RequestCountRequest) (*echopb.RequestCountResponse, error) {
+	return nil, fmt.Errorf("unsupported operation")
+}
+
 func (h *pilotTestHandler) WebSocketEcho(w http.ResponseWriter, r *http.Request) {
 	body := bytes.Buffer{}
 	h.addResponsePayload(r, &body) // create resp payload apriori